@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"tw/pkg"
+)
+
+// backfill stores transactions of the address from the blocks in the
+// given range to the storage of the config, it doesn't run beside the daemon.
+func backfill(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := configFlag(flags, defaultConfigPath)
+	network := networkFlag(flags)
+	from := flags.Int64("from", -1, "first block (required)")
+	to := flags.Int64("to", -1, "last block, inclusive (required)")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	address, ok := addressArg(flags.Args(), stderr)
+	if !ok {
+		return 2
+	}
+
+	if *from < 0 || *to < *from {
+		fmt.Fprintln(stderr, "-from and -to are required, -to can't be lower than -from")
+		return 2
+	}
+
+	cfg, ok := loadConfig(*path, stderr)
+	if !ok {
+		return 1
+	}
+
+	opts, err := pkg.ConfigOptions(cfg, *network)
+	if err != nil {
+		fmt.Fprintf(stderr, "network %s: %s\n", *network, err.Error())
+		return 1
+	}
+
+	storage, closeStorage, ok := openStorage(cfg, stderr)
+	if !ok {
+		return 1
+	}
+	defer closeStorage()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	progress := func(p pkg.BackfillProgress) {
+		fmt.Fprintf(stderr, "\rblock %d (%d/%d)", p.CurrentBlock, p.CurrentBlock-p.FromBlock+1, p.ToBlock-p.FromBlock+1)
+	}
+
+	count, err := pkg.Backfill(ctx, address, *from, *to, progress, append(opts, pkg.WithStorage(storage))...)
+	fmt.Fprintln(stderr)
+
+	fmt.Fprintf(stdout, "stored %d transaction(s)\n", count)
+
+	if errors.Is(err, pkg.ErrStorageLocked) {
+		fmt.Fprintf(stderr, "backfill: %s, stop the daemon first\n", err.Error())
+		return 1
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "backfill: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
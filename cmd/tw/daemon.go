@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"tw/internal/ethereum"
+	"tw/pkg"
+)
+
+// runDaemon runs the parsers of all the configured networks until SIGINT or SIGTERM.
+func runDaemon(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := configFlag(flags, defaultConfigPath)
+	shutdownTimeout := flags.Duration("shutdown-timeout", ethereum.DefaultShutdownTimeout, "how long to wait for the graceful shutdown")
+	record := flags.String("record", "", "append the JSON-RPC requests and responses to the fixture file")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, err := pkg.LoadConfig(*path)
+	if err != nil {
+		printProblems(stderr, *path, err)
+		return 1
+	}
+
+	var opts []pkg.Option
+	if *record != "" {
+		recorder, err := pkg.NewRecordingTransport(*record, nil)
+		if err != nil {
+			fmt.Fprintf(stderr, "record: %s\n", err.Error())
+			return 1
+		}
+		defer recorder.Close()
+
+		opts = append(opts, pkg.WithHTTPClient(&http.Client{Transport: recorder}))
+	}
+
+	deployment, err := pkg.NewDeployment(cfg, opts...)
+	if err != nil {
+		fmt.Fprintf(stderr, "create parsers: %s\n", err.Error())
+		return 1
+	}
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	if err := deployment.Start(); err != nil {
+		fmt.Fprintf(stderr, "start: %s\n", err.Error())
+		_ = deployment.Shutdown(context.Background())
+
+		return 1
+	}
+
+	server, serverErr, err := startHTTP(cfg.HTTP.Listen, deployment)
+	if err != nil {
+		fmt.Fprintf(stderr, "http api: %s\n", err.Error())
+		_ = deployment.Shutdown(context.Background())
+
+		return 1
+	}
+
+	if server != nil {
+		fmt.Fprintf(stdout, "http api listening on %s\n", cfg.HTTP.Listen)
+	}
+
+	grpcServer, grpcErr, err := startGRPC(cfg.GRPC.Listen, deployment)
+	if err != nil {
+		fmt.Fprintf(stderr, "grpc api: %s\n", err.Error())
+		if server != nil {
+			_ = server.Close()
+		}
+		_ = deployment.Shutdown(context.Background())
+
+		return 1
+	}
+
+	if grpcServer != nil {
+		fmt.Fprintf(stdout, "grpc api listening on %s\n", cfg.GRPC.Listen)
+	}
+
+	fmt.Fprintf(stdout, "watching %d network(s), interrupt to stop\n", len(deployment.Parsers))
+
+	code := 0
+	select {
+	case <-ctx.Done():
+	case err := <-serverErr:
+		fmt.Fprintf(stderr, "http api: %s\n", err.Error())
+		code = 1
+	case err := <-grpcErr:
+		fmt.Fprintf(stderr, "grpc api: %s\n", err.Error())
+		code = 1
+	}
+
+	// next signal kills the process
+	stop()
+
+	fmt.Fprintf(stdout, "shutting down, waiting at most %s\n", *shutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), *shutdownTimeout)
+	defer cancel()
+
+	// api goes first, so that it doesn't serve the parsers that are shutting down
+	if server != nil {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			fmt.Fprintf(stderr, "http api shutdown: %s\n", err.Error())
+			code = 1
+		}
+	}
+
+	if grpcServer != nil {
+		stopGRPC(shutdownCtx, grpcServer)
+	}
+
+	if err := deployment.Shutdown(shutdownCtx); err != nil {
+		fmt.Fprintf(stderr, "shutdown: %s\n", err.Error())
+		return 1
+	}
+
+	return code
+}
+
+// startHTTP serves the deployment api on the address, empty address means no api.
+// Errors of the running server are sent to the returned channel.
+func startHTTP(address string, deployment *pkg.Deployment) (*http.Server, <-chan error, error) {
+	if address == "" {
+		return nil, nil, nil
+	}
+
+	handler, err := deployment.HTTPHandler()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Shutdown doesn't wait for the feed streams, they end with the base context
+	ctx, cancel := context.WithCancel(context.Background())
+
+	server := &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+	server.RegisterOnShutdown(cancel)
+
+	errs := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+			errs <- err
+		}
+	}()
+
+	return server, errs, nil
+}
+
+// startGRPC serves the deployment grpc api on the address, empty address means
+// no api. Errors of the running server are sent to the returned channel.
+func startGRPC(address string, deployment *pkg.Deployment) (*grpc.Server, <-chan error, error) {
+	if address == "" {
+		return nil, nil, nil
+	}
+
+	server, err := deployment.GRPCServer()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		if err := server.Serve(listener); err != nil {
+			errs <- err
+		}
+	}()
+
+	return server, errs, nil
+}
+
+// stopGRPC stops the server gracefully, the streams that are still open
+// when the context is done are cancelled.
+func stopGRPC(ctx context.Context, server *grpc.Server) {
+	stopped := make(chan struct{})
+	go func() {
+		server.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		server.Stop()
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"tw/internal/config"
+	"tw/internal/notify"
+)
+
+// deliveries prints webhook deliveries from the outboxes of the config.
+func deliveries(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("deliveries", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := configFlag(flags, defaultConfigPath)
+	status := flags.String("status", "", "print only deliveries with the status: pending, delivered or failed")
+	id := flags.String("id", "", "print the delivery with its attempts as json")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, ok := loadConfig(*path, stderr)
+	if !ok {
+		return 1
+	}
+
+	var outboxes []*notify.Outbox
+	for i, notification := range cfg.Notifications {
+		if notification.Type != config.NotificationWebhook || notification.OutboxDir == "" {
+			continue
+		}
+
+		outbox, err := notify.OpenOutbox(notification.OutboxDir)
+		if err != nil {
+			fmt.Fprintf(stderr, "notifications[%d]: %s\n", i, err.Error())
+			return 1
+		}
+
+		outboxes = append(outboxes, outbox)
+	}
+
+	if len(outboxes) == 0 {
+		fmt.Fprintln(stderr, "no webhook notification has outbox_dir, deliveries are kept only in memory")
+		return 1
+	}
+
+	if *id != "" {
+		for _, outbox := range outboxes {
+			if d, ok := outbox.Delivery(*id); ok {
+				encoder := json.NewEncoder(stdout)
+				encoder.SetIndent("", "  ")
+				_ = encoder.Encode(d)
+
+				return 0
+			}
+		}
+
+		fmt.Fprintf(stderr, "delivery %s not found\n", *id)
+		return 1
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CREATED\tID\tWEBHOOK\tSTATUS\tATTEMPTS\tLAST ERROR")
+	for _, outbox := range outboxes {
+		for _, d := range outbox.Deliveries(notify.DeliveryStatus(*status)) {
+			var lastError string
+			if len(d.Attempts) > 0 {
+				lastError = d.Attempts[len(d.Attempts)-1].Error
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", d.CreatedAt.Format(time.RFC3339), d.ID, d.Webhook, d.Status, len(d.Attempts), lastError)
+		}
+	}
+
+	_ = w.Flush()
+
+	return 0
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"tw/internal/ethereum"
+	"tw/pkg"
+)
+
+// exportTransactions writes stored transactions of the addresses, or all
+// of them, to the file or stdout as csv, json lines or parquet.
+func exportTransactions(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := configFlag(flags, defaultConfigPath)
+	network := networkFlag(flags)
+	format := flags.String("format", "", "csv, jsonl or parquet, default is the extension of -output, or csv")
+	output := flags.String("output", "", "file to write, default is stdout")
+	columns := flags.String("columns", "", "comma separated columns, default are all of them: "+strings.Join(pkg.ExportColumns(), ","))
+	from := flags.Int64("from", 0, "first block")
+	to := flags.Int64("to", 0, "last block, inclusive, 0 means no limit")
+	since := flags.String("since", "", "first time, RFC 3339 or date, inclusive")
+	until := flags.String("until", "", "last time, RFC 3339 or date, exclusive")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	var addresses []string
+	for _, arg := range flags.Args() {
+		address, err := ethereum.NormalizeAddress(arg)
+		if err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return 2
+		}
+
+		addresses = append(addresses, address)
+	}
+
+	filter := pkg.ExportFilter{FromBlock: *from, ToBlock: *to}
+
+	var err error
+	if filter.Since, err = parseTime(*since); err != nil {
+		fmt.Fprintf(stderr, "-since: %s\n", err.Error())
+		return 2
+	}
+
+	if filter.Until, err = parseTime(*until); err != nil {
+		fmt.Fprintf(stderr, "-until: %s\n", err.Error())
+		return 2
+	}
+
+	exportFormat := pkg.ExportFormat(*format)
+	if exportFormat == "" {
+		exportFormat = formatOf(*output)
+	}
+
+	var selected []string
+	if *columns != "" {
+		for _, column := range strings.Split(*columns, ",") {
+			selected = append(selected, strings.TrimSpace(column))
+		}
+	}
+
+	cfg, ok := loadConfig(*path, stderr)
+	if !ok {
+		return 1
+	}
+
+	ethNetwork, _, err := resolveNetwork(cfg, *network)
+	if err != nil {
+		fmt.Fprintf(stderr, "network %s: %s\n", *network, err.Error())
+		return 1
+	}
+
+	storage, closeStorage, ok := openStorage(cfg, stderr)
+	if !ok {
+		return 1
+	}
+	defer closeStorage()
+
+	out := stdout
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(stderr, err.Error())
+			return 1
+		}
+		defer file.Close()
+
+		out = file
+	}
+
+	w := bufio.NewWriter(out)
+
+	stats, err := pkg.Export(w, exportFormat, selected, addresses, filter, pkg.WithNetwork(ethNetwork), pkg.WithStorage(storage))
+	err = errors.Join(err, w.Flush())
+
+	if err != nil {
+		fmt.Fprintf(stderr, "export: %s\n", err.Error())
+
+		if *output != "" {
+			_ = os.Remove(*output)
+		}
+
+		if errors.Is(err, pkg.ErrUnknownColumn) || errors.Is(err, pkg.ErrUnknownFormat) {
+			return 2
+		}
+
+		return 1
+	}
+
+	if stats.WithoutTime > 0 {
+		fmt.Fprintf(stderr, "warning: skipped %d transaction(s) without the block time, they were stored before it was kept\n", stats.WithoutTime)
+	}
+
+	fmt.Fprintf(stderr, "exported %d transaction(s)\n", stats.Rows)
+
+	return 0
+}
+
+// formatOf returns the format of the file extension, csv is the default.
+func formatOf(path string) pkg.ExportFormat {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".jsonl", ".parquet":
+		return pkg.ExportFormat(ext[1:])
+	default:
+		return pkg.ExportCSV
+	}
+}
+
+// parseTime parses RFC 3339 time or the date, which is midnight UTC.
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	if t, err := time.Parse(time.DateOnly, value); err == nil {
+		return t, nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
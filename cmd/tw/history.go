@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"tw/internal/config"
+	"tw/internal/ethereum"
+	"tw/pkg"
+)
+
+// history prints transactions of the address from the storage of the config.
+func history(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := configFlag(flags, defaultConfigPath)
+	network := networkFlag(flags)
+	asJSON := flags.Bool("json", false, "print transactions as json lines")
+	limit := flags.Int("limit", 0, "print only the last n transactions, 0 means all")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	address, ok := addressArg(flags.Args(), stderr)
+	if !ok {
+		return 2
+	}
+
+	cfg, ok := loadConfig(*path, stderr)
+	if !ok {
+		return 1
+	}
+
+	ethNetwork, _, err := resolveNetwork(cfg, *network)
+	if err != nil {
+		fmt.Fprintf(stderr, "network %s: %s\n", *network, err.Error())
+		return 1
+	}
+
+	storage, closeStorage, ok := openStorage(cfg, stderr)
+	if !ok {
+		return 1
+	}
+	defer closeStorage()
+
+	transactions := ethereum.NewNetworkScopedStorage(storage, ethNetwork).GetTransactionsForAddress(address)
+	if *limit > 0 && len(transactions) > *limit {
+		transactions = transactions[len(transactions)-*limit:]
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		for _, transaction := range transactions {
+			_ = encoder.Encode(transaction)
+		}
+
+		return 0
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "BLOCK\tHASH\tFROM\tVALUE")
+	for _, transaction := range transactions {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", transaction.BlockNumber, transaction.Hash, transaction.From, transaction.Value)
+	}
+
+	_ = w.Flush()
+
+	return 0
+}
+
+// openStorage opens the file storage of the config. Memory
+// storage is empty in the new process, so it's an error.
+func openStorage(cfg config.Config, stderr io.Writer) (ethereum.TransactionsStorage, func(), bool) {
+	if cfg.Storage.Backend != config.StorageFile {
+		fmt.Fprintf(stderr, "storage backend is %q, history is kept only by the %q backend\n", cfg.Storage.Backend, config.StorageFile)
+		return nil, nil, false
+	}
+
+	storage, err := pkg.OpenConfigStorage(cfg)
+	if err != nil {
+		fmt.Fprintf(stderr, "open storage: %s\n", err.Error())
+		return nil, nil, false
+	}
+
+	closeStorage := func() {
+		if closer, ok := storage.(io.Closer); ok {
+			if err := closer.Close(); err != nil {
+				fmt.Fprintf(stderr, "close storage: %s\n", err.Error())
+			}
+		}
+	}
+
+	return storage, closeStorage, true
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+
+	"tw/pkg"
+)
+
+// importArchives stores transactions of the watched addresses from
+// the archive files to the storage of the config. It is offline, the
+// handlers aren't called, and it doesn't run beside the daemon.
+func importArchives(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := configFlag(flags, defaultConfigPath)
+	network := networkFlag(flags)
+	address := flags.String("address", "", "import only this address, default are the addresses of the network in the config")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	files := flags.Args()
+	if len(files) == 0 {
+		fmt.Fprintln(stderr, "expected archive files, flags go before them")
+		return 2
+	}
+
+	cfg, ok := loadConfig(*path, stderr)
+	if !ok {
+		return 1
+	}
+
+	ethNetwork, configNetwork, err := resolveNetwork(cfg, *network)
+	if err != nil {
+		fmt.Fprintf(stderr, "network %s: %s\n", *network, err.Error())
+		return 1
+	}
+
+	addresses := configNetwork.Addresses
+	if *address != "" {
+		addresses = []string{*address}
+	}
+
+	if len(addresses) == 0 {
+		fmt.Fprintf(stderr, "network %s has no addresses in the config, use -address\n", *network)
+		return 2
+	}
+
+	storage, closeStorage, ok := openStorage(cfg, stderr)
+	if !ok {
+		return 1
+	}
+	defer closeStorage()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	progress := func(p pkg.ImportProgress) {
+		if p.Blocks%1000 == 0 {
+			fmt.Fprintf(stderr, "\rblock %d (%d blocks, %d stored)", p.CurrentBlock, p.Blocks, p.Stored)
+		}
+	}
+
+	count, err := pkg.Import(ctx, files, addresses, progress, pkg.WithNetwork(ethNetwork), pkg.WithStorage(storage))
+	fmt.Fprintln(stderr)
+
+	fmt.Fprintf(stdout, "stored %d transaction(s)\n", count)
+
+	if errors.Is(err, pkg.ErrStorageLocked) {
+		fmt.Fprintf(stderr, "import: %s, stop the daemon first\n", err.Error())
+		return 1
+	}
+
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
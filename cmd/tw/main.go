@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"tw/internal/config"
+	"tw/internal/ethereum"
+)
+
+const usage = `usage: tw <command> [flags] [address]
+
+commands:
+  run         run the daemon described by the config
+  watch       print events of the address as json lines, until interrupted
+  history     print stored transactions of the address
+  backfill    store transactions of the address from the given blocks
+  import      store transactions of the watched addresses from archive files, offline
+  export      write stored transactions as csv, json lines or parquet
+  status      check the endpoints and the storage
+  deliveries  print webhook deliveries and their attempts
+  validate    check the config file and report all the errors
+
+flags go before the address, run "tw <command> -h" to list them.
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run executes the command and returns the exit code.
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+
+	commands := map[string]func(args []string, stdout, stderr io.Writer) int{
+		"run":        runDaemon,
+		"watch":      watch,
+		"history":    history,
+		"backfill":   backfill,
+		"import":     importArchives,
+		"export":     exportTransactions,
+		"status":     status,
+		"deliveries": deliveries,
+		"validate":   validate,
+	}
+
+	if command, ok := commands[args[0]]; ok {
+		return command(args[1:], stdout, stderr)
+	}
+
+	switch args[0] {
+	case "help", "-h", "--help":
+		fmt.Fprint(stdout, usage)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// signalContext is done on SIGINT or SIGTERM. After stop is called
+// the next signal kills the process, so stuck shutdown can be interrupted.
+func signalContext() (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+}
+
+// networkFlag adds -network flag of the commands working with one network.
+func networkFlag(flags *flag.FlagSet) *string {
+	return flags.String("network", ethereum.Mainnet.Name, "name of the network")
+}
+
+// loadConfig loads the config without requiring any networks, commands
+// working with one network use mainnet when it is not configured.
+func loadConfig(path string, stderr io.Writer) (config.Config, bool) {
+	cfg, err := config.Load(path, os.Environ())
+	if err != nil {
+		printProblems(stderr, path, err)
+		return cfg, false
+	}
+
+	return cfg, true
+}
+
+// resolveNetwork returns the network by name, it doesn't
+// have to be in the config if it is one of the known ones.
+func resolveNetwork(cfg config.Config, name string) (ethereum.Network, config.Network, error) {
+	configNetwork := config.Network{Name: name}
+	for _, n := range cfg.Networks {
+		if n.Name == name {
+			configNetwork = n
+		}
+	}
+
+	resolved, err := configNetwork.Resolve()
+	if err != nil {
+		return ethereum.Network{}, resolved, err
+	}
+
+	network, ok := ethereum.NetworkByName(resolved.Name)
+	if !ok {
+		network = ethereum.Network{Name: resolved.Name, ChainID: resolved.ChainID}
+	}
+
+	return network, resolved, nil
+}
+
+// addressArg returns the only positional argument, normalized.
+func addressArg(args []string, stderr io.Writer) (string, bool) {
+	if len(args) != 1 {
+		fmt.Fprintln(stderr, "expected exactly one address, flags go before it")
+		return "", false
+	}
+
+	address, err := ethereum.NormalizeAddress(args[0])
+	if err != nil {
+		fmt.Fprintln(stderr, err.Error())
+		return "", false
+	}
+
+	return address, true
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"tw/internal/file"
+)
+
+const testAddress = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+
+// fakeNode serves chain 1337, every block has one transaction to testAddress.
+func fakeNode(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params []any  `json:"params"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&req)
+
+		switch req.Method {
+		case "eth_chainId":
+			fmt.Fprint(w, `{"jsonrpc":"2.0","result":"0x539"}`)
+		case "eth_getBlockByNumber":
+			fmt.Fprintf(w, `{"jsonrpc":"2.0","result":{"transactions":[{"hash":"%[1]s","blockNumber":"%[1]s","to":"%[2]s"}]}}`, req.Params[0], testAddress)
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "tw.toml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func TestRun_BackfillThenHistory(t *testing.T) {
+	node := fakeNode(t)
+	path := writeConfig(t, fmt.Sprintf(`
+[storage]
+backend = "file"
+path = %q
+
+[[networks]]
+name = "devnet"
+chain_id = 1337
+endpoints = [%q]
+`, filepath.Join(t.TempDir(), "transactions.jsonl"), node.URL))
+
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"backfill", "-config", path, "-network", "devnet", "-from", "1", "-to", "3", testAddress}, &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), "stored 3 transaction(s)") {
+		t.Fatalf("backfill exit = %v, stdout = %q, stderr = %q", code, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+
+	code = run([]string{"history", "-config", path, "-network", "devnet", "-json", "-limit", "2", testAddress}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("history exit = %v, stderr = %q", code, stderr.String())
+	}
+
+	if lines := strings.Split(strings.TrimSpace(stdout.String()), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"hash":"0x3"`) {
+		t.Errorf("history = %q, want last 2 transactions", stdout.String())
+	}
+}
+
+func TestRun_ImportThenHistoryAndExport(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "blocks.jsonl")
+	blocks := fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"result":{"number":"0x1","timestamp":"0x65920080","transactions":[{"hash":"0xa1","to":"%[1]s","chainId":"0x539"}]}}
+{"number":"0x2","transactions":[{"hash":"0xa2","to":"%[1]s","chainId":"0x1"},{"hash":"0xa3","to":"%[1]s"}]}
+`, testAddress)
+	if err := os.WriteFile(archive, []byte(blocks), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := writeConfig(t, fmt.Sprintf(`
+[storage]
+backend = "file"
+path = %q
+
+[[networks]]
+name = "devnet"
+chain_id = 1337
+endpoints = ["http://127.0.0.1:1"]
+addresses = [%q]
+`, filepath.Join(t.TempDir(), "transactions.jsonl"), testAddress))
+
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"import", "-config", path, "-network", "devnet", archive}, &stdout, &stderr)
+	if code != 0 || !strings.Contains(stdout.String(), "stored 2 transaction(s)") {
+		t.Fatalf("import exit = %v, stdout = %q, stderr = %q", code, stdout.String(), stderr.String())
+	}
+
+	stdout.Reset()
+
+	code = run([]string{"history", "-config", path, "-network", "devnet", "-json", testAddress}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("history exit = %v, stderr = %q", code, stderr.String())
+	}
+
+	if strings.Contains(stdout.String(), `"hash":"0xa2"`) || !strings.Contains(stdout.String(), `"hash":"0xa3"`) {
+		t.Errorf("history = %q, want transactions of chain 1337 only", stdout.String())
+	}
+
+	stdout.Reset()
+
+	code = run([]string{"export", "-config", path, "-network", "devnet", "-columns", "hash,time", "-since", "2024-01-01"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("export exit = %v, stderr = %q", code, stderr.String())
+	}
+
+	if want := "hash,time\n0xa1,2024-01-01T00:00:00Z\n"; stdout.String() != want {
+		t.Errorf("export = %q, want %q", stdout.String(), want)
+	}
+
+	if want := "skipped 1 transaction(s) without the block time"; !strings.Contains(stderr.String(), want) {
+		t.Errorf("export stderr = %q, want %q", stderr.String(), want)
+	}
+
+	output := filepath.Join(t.TempDir(), "transactions.parquet")
+
+	code = run([]string{"export", "-config", path, "-network", "devnet", "-output", output, testAddress}, &stdout, &stderr)
+	if data, _ := os.ReadFile(output); code != 0 || !bytes.HasPrefix(data, []byte("PAR1")) {
+		t.Errorf("export exit = %v, stderr = %q, want parquet file", code, stderr.String())
+	}
+}
+
+// lockedConfig writes the config of the storage file which is held by the daemon.
+func lockedConfig(t *testing.T, endpoint string) string {
+	storagePath := filepath.Join(t.TempDir(), "transactions.jsonl")
+
+	daemon, err := file.NewFileTransactionStorage(storagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = daemon.Close() })
+
+	if err := daemon.Lock(); err != nil {
+		t.Fatal(err)
+	}
+
+	return writeConfig(t, fmt.Sprintf(`
+[storage]
+backend = "file"
+path = %q
+
+[[networks]]
+name = "devnet"
+chain_id = 1337
+endpoints = [%q]
+addresses = [%q]
+`, storagePath, endpoint, testAddress))
+}
+
+func TestRun_ImportBesideDaemon(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("storage file isn't locked on windows")
+	}
+
+	path := lockedConfig(t, "http://127.0.0.1:1")
+
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"import", "-config", path, "-network", "devnet", filepath.Join(t.TempDir(), "blocks.jsonl")}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "stop the daemon first") {
+		t.Errorf("import exit = %v, stderr = %q, want the locked storage", code, stderr.String())
+	}
+}
+
+func TestRun_BackfillBesideDaemon(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("storage file isn't locked on windows")
+	}
+
+	path := lockedConfig(t, fakeNode(t).URL)
+
+	var stdout, stderr bytes.Buffer
+
+	code := run([]string{"backfill", "-config", path, "-network", "devnet", "-from", "1", "-to", "3", testAddress}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "stop the daemon first") {
+		t.Errorf("backfill exit = %v, stderr = %q, want the locked storage", code, stderr.String())
+	}
+}
+
+func TestRun_ExitCodes(t *testing.T) {
+	invalid := writeConfig(t, "confirmations = -1\n")
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{name: "no command", args: nil, want: 2},
+		{name: "unknown command", args: []string{"fly"}, want: 2},
+		{name: "invalid config", args: []string{"validate", "-config", invalid}, want: 1},
+		{name: "watch without address", args: []string{"watch"}, want: 2},
+		{name: "watch invalid address", args: []string{"watch", "0x123"}, want: 2},
+		{name: "import without files", args: []string{"import"}, want: 2},
+		{name: "export invalid time", args: []string{"export", "-since", "yesterday"}, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := run(tt.args, &bytes.Buffer{}, &bytes.Buffer{}); got != tt.want {
+				t.Errorf("run() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
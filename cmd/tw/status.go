@@ -0,0 +1,122 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"text/tabwriter"
+	"time"
+
+	"tw/internal/config"
+	"tw/internal/ethereum"
+	"tw/pkg"
+)
+
+// status checks that every endpoint of the configured networks serves the
+// right chain and prints its head block, then it prints what is in the storage.
+func status(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("status", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := configFlag(flags, "")
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, ok := loadConfig(*path, stderr)
+	if !ok {
+		return 1
+	}
+
+	networks := cfg.Networks
+	if len(networks) == 0 {
+		networks = []config.Network{{Name: ethereum.Mainnet.Name}}
+	}
+
+	code := 0
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NETWORK\tENDPOINT\tHEAD\tLATENCY\tSTATUS")
+
+	for _, network := range networks {
+		opts, err := pkg.ConfigOptions(cfg, network.Name)
+		if err != nil {
+			fmt.Fprintf(w, "%s\t-\t-\t-\t%s\n", network.Name, err.Error())
+			code = 1
+
+			continue
+		}
+
+		resolved, _ := network.Resolve()
+		for _, endpoint := range resolved.Endpoints {
+			head, latency, err := checkEndpoint(opts, endpoint)
+
+			state := "ok"
+			if err != nil {
+				state = err.Error()
+				code = 1
+			}
+
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", network.Name, redactEndpoint(endpoint), head, latency.Round(time.Millisecond), state)
+		}
+	}
+
+	_ = w.Flush()
+
+	fmt.Fprintf(stdout, "\nstorage: %s", cfg.Storage.Backend)
+	if cfg.Storage.Backend != config.StorageFile {
+		fmt.Fprintln(stdout)
+		return code
+	}
+
+	fmt.Fprintf(stdout, " %s\n", cfg.Storage.Path)
+
+	storage, closeStorage, ok := openStorage(cfg, stderr)
+	if !ok {
+		return 1
+	}
+	defer closeStorage()
+
+	for _, network := range networks {
+		ethNetwork, _, err := resolveNetwork(cfg, network.Name)
+		if err != nil {
+			continue
+		}
+
+		scoped := ethereum.NewNetworkScopedStorage(storage, ethNetwork)
+		for _, address := range network.Addresses {
+			normalized, _ := ethereum.NormalizeAddress(address)
+			fmt.Fprintf(stdout, "  %s %s: %d transaction(s)\n", network.Name, normalized, len(scoped.GetTransactionsForAddress(normalized)))
+		}
+	}
+
+	return code
+}
+
+// checkEndpoint verifies the chain id of the endpoint and returns its head block.
+func checkEndpoint(opts []pkg.Option, endpoint string) (int, time.Duration, error) {
+	start := time.Now()
+
+	parser, err := pkg.NewParser(append(opts, pkg.WithEndpoints(endpoint))...)
+	if err != nil {
+		return 0, time.Since(start), err
+	}
+	defer parser.Close()
+
+	head := parser.GetCurrentBlock()
+	if head == 0 {
+		return 0, time.Since(start), fmt.Errorf("can't get current block")
+	}
+
+	return head, time.Since(start), nil
+}
+
+func redactEndpoint(endpoint string) string {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "invalid url"
+	}
+
+	return ethereum.RedactURL(u)
+}
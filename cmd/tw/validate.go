@@ -0,0 +1,77 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"tw/internal/config"
+)
+
+// defaultConfigPath is used by validate when neither -config nor TW_CONFIG is set.
+const defaultConfigPath = "tw.toml"
+
+// configFlag adds -config flag, default comes from TW_CONFIG.
+func configFlag(flags *flag.FlagSet, defaultPath string) *string {
+	path := os.Getenv(config.EnvPrefix + "CONFIG")
+	if path == "" {
+		path = defaultPath
+	}
+
+	return flags.String("config", path, "path to the config file (TW_CONFIG)")
+}
+
+func validate(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := configFlag(flags, defaultConfigPath)
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	cfg, loadErr := config.Load(*path, os.Environ())
+
+	if err := errors.Join(loadErr, cfg.Validate()); err != nil {
+		printProblems(stderr, *path, err)
+		return 1
+	}
+
+	addresses := 0
+	for _, network := range cfg.Networks {
+		addresses += len(network.Addresses)
+	}
+
+	fmt.Fprintf(stdout, "%s is valid: %d network(s), %d address(es), %d notification sink(s)\n", *path, len(cfg.Networks), addresses, len(cfg.Notifications))
+
+	return 0
+}
+
+// printProblems prints every problem of the joined errors on its own line.
+func printProblems(w io.Writer, path string, err error) {
+	problems := strings.Split(flatten(err), "\n")
+
+	fmt.Fprintf(w, "%s: %d problem(s)\n", path, len(problems))
+	for _, problem := range problems {
+		fmt.Fprintf(w, "  %s\n", problem)
+	}
+}
+
+// flatten returns messages of the joined errors without the prefixes
+// added by wrapping, so every problem is on its own line.
+func flatten(err error) string {
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return err.Error()
+	}
+
+	var lines []string
+	for _, e := range joined.Unwrap() {
+		lines = append(lines, flatten(e))
+	}
+
+	return strings.Join(lines, "\n")
+}
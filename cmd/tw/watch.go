@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"tw/internal/notify"
+	"tw/pkg"
+)
+
+// watch prints events of the address, and the ones not related
+// to any address (errors, lagging etc.), as json lines.
+func watch(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("watch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	path := configFlag(flags, "")
+	network := networkFlag(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	address, ok := addressArg(flags.Args(), stderr)
+	if !ok {
+		return 2
+	}
+
+	cfg, ok := loadConfig(*path, stderr)
+	if !ok {
+		return 1
+	}
+
+	opts, err := pkg.ConfigOptions(cfg, *network)
+	if err != nil {
+		fmt.Fprintf(stderr, "network %s: %s\n", *network, err.Error())
+		return 1
+	}
+
+	// watching doesn't store anything, daemon does
+	parser, err := pkg.NewParser(append(opts, pkg.WithStorage(pkg.NewMemoryStorage()))...)
+	if err != nil {
+		fmt.Fprintf(stderr, "create parser: %s\n", err.Error())
+		return 1
+	}
+
+	if err := parser.SubscribeAddress(address); err != nil {
+		fmt.Fprintf(stderr, "subscribe: %s\n", err.Error())
+		_ = parser.Close()
+
+		return 1
+	}
+
+	printed := make(chan struct{})
+	go func() {
+		defer close(printed)
+
+		encoder := json.NewEncoder(stdout)
+		for event := range parser.Events() {
+			if eventAddress := event.EventAddress(); eventAddress != "" && eventAddress != address {
+				continue
+			}
+
+			_ = encoder.Encode(notify.NewPayload(*network, event))
+		}
+	}()
+
+	ctx, stop := signalContext()
+	defer stop()
+
+	<-ctx.Done()
+	stop()
+
+	err = parser.Close()
+	<-printed
+
+	if err != nil {
+		fmt.Fprintf(stderr, "shutdown: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -1,20 +1,42 @@
 package main
 
 import (
+	"context"
 	"log"
+	"os"
+	"os/signal"
 	"time"
+
 	"tw/pkg"
 )
 
 const testAddress = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
 
 func main() {
-	parser := pkg.NewDefaultParser()
+	parser, err := pkg.NewDefaultParser()
+	if err != nil {
+		log.Fatalf("new parser: %s", err.Error())
+	}
+
+	err = parser.SubscribeFunc(testAddress, func(ctx context.Context, event pkg.Event) error {
+		if observed, ok := event.(pkg.TransactionObserved); ok {
+			log.Printf("new transaction %s, %d in total", observed.Transaction.Hash, len(parser.GetTransactions(testAddress)))
+		}
+
+		return nil
+	}, pkg.WithConcurrency(1))
+	if err != nil {
+		log.Fatalf("subscribe: %s", err.Error())
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
 
-	parser.Subscribe(testAddress)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
 
-	for {
-		log.Println(len(parser.GetTransactions(testAddress)))
-		time.Sleep(time.Second * 3)
+	if err := parser.Shutdown(ctx); err != nil {
+		log.Printf("shutdown: %s", err.Error())
 	}
 }
@@ -0,0 +1,177 @@
+// Package archive reads the exported blocks for ethereum.Import: JSON Lines
+// of eth_getBlockByNumber responses (with full transactions) and era1 files.
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"tw/internal/ethereum"
+	"tw/internal/hexutil"
+)
+
+// ErrHashesOnly is returned for the block which has the transaction hashes
+// instead of the transactions, it has to be exported with the full ones.
+var ErrHashesOnly = errors.New("block has transaction hashes only")
+
+// Source is the block source of the archive file.
+type Source interface {
+	ethereum.BlockSource
+	io.Closer
+}
+
+// Open opens the archive, format is picked by the extension: .era1 is the era1
+// file, everything else is JSON Lines, gzipped if it ends with .gz.
+func Open(path string) (Source, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.EqualFold(filepath.Ext(path), ".era1"):
+		return &fileSource{BlockSource: NewEra1Reader(bufio.NewReader(file)), closers: []io.Closer{file}}, nil
+	case strings.EqualFold(filepath.Ext(path), ".gz"):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			_ = file.Close()
+
+			return nil, fmt.Errorf("gzip: %w", err)
+		}
+
+		return &fileSource{BlockSource: NewJSONLReader(gz), closers: []io.Closer{gz, file}}, nil
+	default:
+		return &fileSource{BlockSource: NewJSONLReader(file), closers: []io.Closer{file}}, nil
+	}
+}
+
+// fileSource closes the file and the readers on top of it.
+type fileSource struct {
+	ethereum.BlockSource
+	closers []io.Closer
+}
+
+func (f *fileSource) Close() error {
+	var errs []error
+	for _, closer := range f.closers {
+		errs = append(errs, closer.Close())
+	}
+
+	return errors.Join(errs...)
+}
+
+// JSONLReader reads the blocks from JSON Lines, every line is either the
+// eth_getBlockByNumber response or its result. Empty lines are skipped.
+type JSONLReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+var _ ethereum.BlockSource = (*JSONLReader)(nil)
+
+// NewJSONLReader reads the blocks from r.
+func NewJSONLReader(r io.Reader) *JSONLReader {
+	scanner := bufio.NewScanner(r)
+	// blocks full of transactions are long lines
+	scanner.Buffer(nil, 256<<20)
+
+	return &JSONLReader{scanner: scanner}
+}
+
+// NextBlock returns the block of the next line.
+func (j *JSONLReader) NextBlock() (ethereum.ArchivedBlock, error) {
+	for j.scanner.Scan() {
+		j.line++
+
+		line := bytes.TrimSpace(j.scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		block, err := decodeBlock(line)
+		if err != nil {
+			return block, fmt.Errorf("line %d: %w", j.line, err)
+		}
+
+		return block, nil
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return ethereum.ArchivedBlock{}, err
+	}
+
+	return ethereum.ArchivedBlock{}, io.EOF
+}
+
+// decodeBlock decodes the response or the block itself.
+func decodeBlock(line []byte) (ethereum.ArchivedBlock, error) {
+	var res struct {
+		Result json.RawMessage    `json:"result"`
+		Error  *ethereum.RPCError `json:"error"`
+	}
+	if err := json.Unmarshal(line, &res); err != nil {
+		return ethereum.ArchivedBlock{}, err
+	}
+
+	if res.Error != nil {
+		return ethereum.ArchivedBlock{}, res.Error
+	}
+
+	raw := line
+	if res.Result != nil {
+		raw = res.Result
+	}
+
+	var block struct {
+		Number       string            `json:"number"`
+		Hash         string            `json:"hash"`
+		Timestamp    string            `json:"timestamp"`
+		Transactions []json.RawMessage `json:"transactions"`
+	}
+	if err := json.Unmarshal(raw, &block); err != nil {
+		return ethereum.ArchivedBlock{}, err
+	}
+
+	// null result is the block which wasn't found
+	if block.Number == "" && bytes.Equal(raw, []byte("null")) {
+		return ethereum.ArchivedBlock{}, errors.New("block not found")
+	}
+
+	number, err := hexutil.DecodeInt64(block.Number)
+	if err != nil {
+		return ethereum.ArchivedBlock{}, fmt.Errorf("block number: %w", err)
+	}
+
+	archived := ethereum.ArchivedBlock{
+		Number:       number,
+		Hash:         block.Hash,
+		Transactions: make([]ethereum.Transaction, 0, len(block.Transactions)),
+	}
+
+	for _, rawTransaction := range block.Transactions {
+		if bytes.HasPrefix(rawTransaction, []byte(`"`)) {
+			return archived, fmt.Errorf("block %d: %w", number, ErrHashesOnly)
+		}
+
+		var transaction ethereum.Transaction
+		if err := json.Unmarshal(rawTransaction, &transaction); err != nil {
+			return archived, fmt.Errorf("block %d transaction: %w", number, err)
+		}
+
+		if transaction.BlockTimestamp == "" {
+			transaction.BlockTimestamp = block.Timestamp
+		}
+
+		archived.Transactions = append(archived.Transactions, transaction)
+	}
+
+	return archived, nil
+}
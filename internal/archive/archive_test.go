@@ -0,0 +1,315 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tw/internal/ethereum"
+	"tw/internal/hexutil"
+	"tw/internal/keccak"
+)
+
+func TestJSONLReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    []int64
+		wantErr error
+	}{
+		{
+			name: "responses and bare blocks",
+			input: `{"jsonrpc":"2.0","id":1,"result":{"number":"0x10","hash":"0xa","transactions":[{"hash":"0x1","to":"0x2"}]}}
+
+{"number":"0x11","hash":"0xb","transactions":[]}
+`,
+			want: []int64{16, 17},
+		},
+		{
+			name:    "transaction hashes only",
+			input:   `{"number":"0x10","transactions":["0x1"]}`,
+			wantErr: ErrHashesOnly,
+		},
+		{
+			name:    "malformed number",
+			input:   `{"number":"0x010","transactions":[]}`,
+			wantErr: hexutil.ErrLeadingZero,
+		},
+		{
+			name:    "error response",
+			input:   `{"jsonrpc":"2.0","id":1,"error":{"code":-32000,"message":"header not found"}}`,
+			wantErr: &ethereum.RPCError{Code: -32000, Message: "header not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readAll(NewJSONLReader(strings.NewReader(tt.input)))
+			if tt.wantErr != nil {
+				var rpcErr *ethereum.RPCError
+				if !errors.Is(err, tt.wantErr) && !(errors.As(err, &rpcErr) && reflect.DeepEqual(rpcErr, tt.wantErr)) {
+					t.Errorf("NextBlock() error = %v, want %v", err, tt.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NextBlock() error = %v", err)
+			}
+
+			if numbers := blockNumbers(got); !reflect.DeepEqual(numbers, tt.want) {
+				t.Errorf("blocks = %v, want %v", numbers, tt.want)
+			}
+		})
+	}
+}
+
+func TestEra1Reader(t *testing.T) {
+	to := bytes.Repeat([]byte{0xaa}, 20)
+
+	// EIP-155 legacy transaction of mainnet, v = 1*2+35
+	legacy := rlpEncodeList(rlpEncode([]byte{1}), rlpEncode([]byte{0x09}), rlpEncode([]byte{0x52, 0x08}), rlpEncode(to), rlpEncode([]byte{0x0d, 0xe0}), rlpEncode(nil), rlpEncode([]byte{37}), rlpEncode([]byte{1}), rlpEncode([]byte{2}))
+	// contract creation before EIP-155
+	creation := rlpEncodeList(rlpEncode(nil), rlpEncode([]byte{1}), rlpEncode([]byte{1}), rlpEncode(nil), rlpEncode(nil), rlpEncode([]byte{0x60, 0x80}), rlpEncode([]byte{27}), rlpEncode([]byte{1}), rlpEncode([]byte{2}))
+	// dynamic fee transaction of polygon with the access list
+	accessList := rlpEncodeList(rlpEncodeList(rlpEncode(to), rlpEncodeList(rlpEncode(bytes.Repeat([]byte{1}, 32)))))
+	dynamic := append([]byte{0x02}, rlpEncodeList(rlpEncode([]byte{0x89}), rlpEncode(nil), rlpEncode([]byte{1}), rlpEncode([]byte{2}), rlpEncode([]byte{0x52, 0x08}), rlpEncode(to), rlpEncode([]byte{5}), rlpEncode(nil), accessList, rlpEncode([]byte{1}), rlpEncode([]byte{1}), rlpEncode([]byte{2}))...)
+
+	header := rlpEncodeList(rlpEncode(make([]byte, 32)), rlpEncode(make([]byte, 32)), rlpEncode(make([]byte, 20)), rlpEncode(make([]byte, 32)), rlpEncode(make([]byte, 32)), rlpEncode(make([]byte, 32)), rlpEncode(make([]byte, 256)), rlpEncode([]byte{1}), rlpEncode([]byte{0x01, 0x00}), rlpEncode([]byte{0x1c, 0x9c, 0x38, 0x00}), rlpEncode([]byte{0x52, 0x08}), rlpEncode([]byte{0x55, 0xba, 0x46, 0x7c}))
+	body := rlpEncodeList(rlpEncodeList(legacy, creation, rlpEncode(dynamic)), rlpEncodeList())
+
+	emptyHeader := rlpEncodeList(rlpEncode(nil), rlpEncode(nil), rlpEncode(nil), rlpEncode(nil), rlpEncode(nil), rlpEncode(nil), rlpEncode(nil), rlpEncode(nil), rlpEncode([]byte{0x01, 0x01}))
+	emptyBody := rlpEncodeList(rlpEncodeList(), rlpEncodeList())
+
+	var file bytes.Buffer
+	writeEntry(&file, era1Version, nil)
+	writeEntry(&file, era1CompressedHeader, snappyFrame(header))
+	writeEntry(&file, era1CompressedBody, snappyFrame(body))
+	writeEntry(&file, era1CompressedReceipts, snappyFrame(rlpEncodeList()))
+	writeEntry(&file, era1TotalDifficulty, make([]byte, 32))
+	writeEntry(&file, era1CompressedHeader, snappyFrame(emptyHeader))
+	writeEntry(&file, era1CompressedBody, snappyFrame(emptyBody))
+	writeEntry(&file, era1Accumulator, make([]byte, 32))
+	writeEntry(&file, era1BlockIndex, make([]byte, 24))
+
+	blocks, err := readAll(NewEra1Reader(&file))
+	if err != nil {
+		t.Fatalf("NextBlock() error = %v", err)
+	}
+
+	if numbers := blockNumbers(blocks); !reflect.DeepEqual(numbers, []int64{256, 257}) {
+		t.Fatalf("blocks = %v, want [256 257]", numbers)
+	}
+
+	headerHash := keccak.Sum256(header)
+	if blocks[0].Hash != hexutil.Encode(headerHash[:]) {
+		t.Errorf("hash = %v, want %x", blocks[0].Hash, headerHash)
+	}
+
+	legacyHash := keccak.Sum256(legacy)
+	dynamicHash := keccak.Sum256(dynamic)
+
+	type summary struct{ Type, Hash, To, Value, ChainId, Index, Timestamp string }
+
+	var got []summary
+	for _, transaction := range blocks[0].Transactions {
+		got = append(got, summary{transaction.Type, transaction.Hash, transaction.To, transaction.Value, transaction.ChainId, transaction.TransactionIndex, transaction.BlockTimestamp})
+	}
+
+	want := []summary{
+		{"0x0", hexutil.Encode(legacyHash[:]), hexutil.Encode(to), "0xde0", "0x1", "0x0", "0x55ba467c"},
+		{"0x0", got[1].Hash, "", "0x0", "", "0x1", "0x55ba467c"},
+		{"0x2", hexutil.Encode(dynamicHash[:]), hexutil.Encode(to), "0x5", "0x89", "0x2", "0x55ba467c"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("transactions = %+v, want %+v", got, want)
+	}
+
+	if accessList := blocks[0].Transactions[2].AccessList; len(accessList) != 1 || len(accessList[0].StorageKeys) != 1 {
+		t.Errorf("access list = %+v, want one entry with one key", accessList)
+	}
+
+	if len(blocks[1].Transactions) != 0 {
+		t.Errorf("empty block transactions = %v", blocks[1].Transactions)
+	}
+}
+
+func TestEra1Reader_Invalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		write func(w *bytes.Buffer)
+	}{
+		{name: "no version", write: func(w *bytes.Buffer) { writeEntry(w, era1CompressedHeader, nil) }},
+		{name: "truncated entry", write: func(w *bytes.Buffer) { writeEntry(w, era1Version, nil); w.Write([]byte{3, 0, 10, 0, 0, 0, 0, 0, 1}) }},
+		{name: "body without header", write: func(w *bytes.Buffer) {
+			writeEntry(w, era1Version, nil)
+			writeEntry(w, era1CompressedBody, snappyFrame(rlpEncodeList()))
+		}},
+		{name: "broken checksum", write: func(w *bytes.Buffer) {
+			writeEntry(w, era1Version, nil)
+			frame := snappyFrame(rlpEncodeList())
+			frame[len(frame)-1] ^= 0xff
+			writeEntry(w, era1CompressedHeader, frame)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var file bytes.Buffer
+			tt.write(&file)
+
+			if _, err := readAll(NewEra1Reader(&file)); err == nil || errors.Is(err, io.EOF) {
+				t.Errorf("NextBlock() error = %v, want invalid era1", err)
+			}
+		})
+	}
+}
+
+func TestSnappyBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   []byte
+		want    string
+		wantErr bool
+	}{
+		{name: "literal", input: []byte{3, 0x08, 'a', 'b', 'c'}, want: "abc"},
+		{name: "overlapping copy with 1 byte offset", input: []byte{12, 0x08, 'a', 'b', 'c', 0x15, 3}, want: "abcabcabcabc"},
+		{name: "copy with 2 bytes offset", input: []byte{12, 0x08, 'a', 'b', 'c', 0x22, 3, 0}, want: "abcabcabcabc"},
+		{name: "long literal", input: append([]byte{61, 60 << 2, 60}, bytes.Repeat([]byte{'x'}, 61)...), want: strings.Repeat("x", 61)},
+		{name: "copy before the start", input: []byte{12, 0x08, 'a', 'b', 'c', 0x15, 4}, wantErr: true},
+		{name: "more than declared", input: []byte{2, 0x08, 'a', 'b', 'c'}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := snappyBlock(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("snappyBlock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if string(got) != tt.want {
+				t.Errorf("snappyBlock() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	block := `{"number":"0x1","transactions":[]}` + "\n"
+
+	var gz bytes.Buffer
+	w := gzip.NewWriter(&gz)
+	_, _ = w.Write([]byte(block))
+	_ = w.Close()
+
+	files := map[string][]byte{"blocks.jsonl": []byte(block), "blocks.jsonl.gz": gz.Bytes()}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		_ = os.WriteFile(path, content, 0o644)
+
+		source, err := Open(path)
+		if err != nil {
+			t.Fatalf("Open(%s) error = %v", name, err)
+		}
+
+		blocks, err := readAll(source)
+		_ = source.Close()
+
+		if err != nil || len(blocks) != 1 {
+			t.Errorf("%s blocks = %v, %v, want one", name, blocks, err)
+		}
+	}
+}
+
+func FuzzEra1Reader(f *testing.F) {
+	var file bytes.Buffer
+	writeEntry(&file, era1Version, nil)
+	writeEntry(&file, era1CompressedHeader, snappyFrame(rlpEncodeList(rlpEncode(nil))))
+	f.Add(file.Bytes())
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// it must not panic or loop
+		_, _ = readAll(NewEra1Reader(bytes.NewReader(data)))
+	})
+}
+
+func readAll(source ethereum.BlockSource) ([]ethereum.ArchivedBlock, error) {
+	var blocks []ethereum.ArchivedBlock
+	for {
+		block, err := source.NextBlock()
+		if errors.Is(err, io.EOF) {
+			return blocks, nil
+		}
+
+		if err != nil {
+			return blocks, err
+		}
+
+		blocks = append(blocks, block)
+	}
+}
+
+func blockNumbers(blocks []ethereum.ArchivedBlock) []int64 {
+	var numbers []int64
+	for _, block := range blocks {
+		numbers = append(numbers, block.Number)
+	}
+
+	return numbers
+}
+
+func writeEntry(w *bytes.Buffer, entryType uint16, data []byte) {
+	var header [8]byte
+	binary.LittleEndian.PutUint16(header[0:], entryType)
+	binary.LittleEndian.PutUint32(header[2:], uint32(len(data)))
+	w.Write(header[:])
+	w.Write(data)
+}
+
+// snappyFrame is the stream of one uncompressed chunk.
+func snappyFrame(data []byte) []byte {
+	frame := []byte{snappyChunkStream, 6, 0, 0}
+	frame = append(frame, snappyStreamID...)
+
+	length := len(data) + 4
+	frame = append(frame, snappyChunkUncompressed, byte(length), byte(length>>8), byte(length>>16))
+	frame = binary.LittleEndian.AppendUint32(frame, maskedCRC(data))
+
+	return append(frame, data...)
+}
+
+func rlpEncode(b []byte) []byte {
+	if len(b) == 1 && b[0] < 0x80 {
+		return b
+	}
+
+	return append(rlpHeader(0x80, len(b)), b...)
+}
+
+func rlpEncodeList(items ...[]byte) []byte {
+	content := bytes.Join(items, nil)
+
+	return append(rlpHeader(0xc0, len(content)), content...)
+}
+
+func rlpHeader(base byte, length int) []byte {
+	if length <= 55 {
+		return []byte{base + byte(length)}
+	}
+
+	var size []byte
+	for n := length; n > 0; n >>= 8 {
+		size = append([]byte{byte(n)}, size...)
+	}
+
+	return append([]byte{base + 55 + byte(len(size))}, size...)
+}
@@ -0,0 +1,334 @@
+package archive
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+
+	"tw/internal/ethereum"
+	"tw/internal/hexutil"
+	"tw/internal/keccak"
+)
+
+// e2store entry types of era1.
+const (
+	era1Version            = 0x3265
+	era1CompressedHeader   = 0x03
+	era1CompressedBody     = 0x04
+	era1CompressedReceipts = 0x05
+	era1TotalDifficulty    = 0x06
+	era1Accumulator        = 0x07
+	era1BlockIndex         = 0x3266
+
+	// maxEra1Entry is the limit of the entry, so broken file isn't read to the memory
+	maxEra1Entry = 1 << 30
+)
+
+// ErrInvalidEra1 is returned when the file isn't valid era1.
+var ErrInvalidEra1 = errors.New("invalid era1")
+
+// Era1Reader reads the blocks from the era1 file: e2store entries of snappy
+// compressed rlp headers and bodies. Receipts, total difficulties and the
+// accumulator are skipped.
+//
+// Transactions in era1 don't have the sender, it is recovered from the signature
+// with secp256k1, which isn't implemented here, so From of the transactions is
+// empty. Transactions are matched by the recipient, so it doesn't matter for the
+// import, but the stored transactions don't have it.
+type Era1Reader struct {
+	r       io.Reader
+	started bool
+	header  []byte
+}
+
+var _ ethereum.BlockSource = (*Era1Reader)(nil)
+
+// NewEra1Reader reads the blocks from r.
+func NewEra1Reader(r io.Reader) *Era1Reader {
+	return &Era1Reader{r: r}
+}
+
+// NextBlock returns the block of the next header and body entries.
+func (e *Era1Reader) NextBlock() (ethereum.ArchivedBlock, error) {
+	for {
+		entryType, data, err := e.readEntry()
+		if err != nil {
+			return ethereum.ArchivedBlock{}, err
+		}
+
+		if !e.started {
+			if entryType != era1Version {
+				return ethereum.ArchivedBlock{}, fmt.Errorf("%w: file doesn't start with the version", ErrInvalidEra1)
+			}
+
+			e.started = true
+
+			continue
+		}
+
+		switch entryType {
+		case era1CompressedHeader:
+			if e.header, err = snappyFramed(data); err != nil {
+				return ethereum.ArchivedBlock{}, fmt.Errorf("header: %w", err)
+			}
+		case era1CompressedBody:
+			if e.header == nil {
+				return ethereum.ArchivedBlock{}, fmt.Errorf("%w: body without header", ErrInvalidEra1)
+			}
+
+			body, err := snappyFramed(data)
+			if err != nil {
+				return ethereum.ArchivedBlock{}, fmt.Errorf("body: %w", err)
+			}
+
+			block, err := decodeEra1Block(e.header, body)
+			e.header = nil
+
+			return block, err
+		case era1BlockIndex:
+			// index is the last entry
+			return ethereum.ArchivedBlock{}, io.EOF
+		}
+	}
+}
+
+// readEntry reads the next e2store entry: type, length and reserved
+// field, then the data. Entries which are not needed are discarded.
+func (e *Era1Reader) readEntry() (uint16, []byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(e.r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return 0, nil, fmt.Errorf("%w: truncated entry header", ErrInvalidEra1)
+		}
+
+		return 0, nil, err
+	}
+
+	entryType := binary.LittleEndian.Uint16(header[0:2])
+	length := binary.LittleEndian.Uint32(header[2:6])
+
+	if binary.LittleEndian.Uint16(header[6:8]) != 0 {
+		return 0, nil, fmt.Errorf("%w: reserved bytes of the entry aren't zero", ErrInvalidEra1)
+	}
+
+	if length > maxEra1Entry {
+		return 0, nil, fmt.Errorf("%w: entry of %d bytes", ErrInvalidEra1, length)
+	}
+
+	switch entryType {
+	case era1CompressedReceipts, era1TotalDifficulty, era1Accumulator:
+		if _, err := io.CopyN(io.Discard, e.r, int64(length)); err != nil {
+			return 0, nil, fmt.Errorf("%w: truncated entry", ErrInvalidEra1)
+		}
+
+		return entryType, nil, nil
+	}
+
+	// length isn't trusted with the allocation, the data has to be there
+	data, err := io.ReadAll(io.LimitReader(e.r, int64(length)))
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(data) != int(length) {
+		return 0, nil, fmt.Errorf("%w: truncated entry", ErrInvalidEra1)
+	}
+
+	return entryType, data, nil
+}
+
+// decodeEra1Block decodes the rlp header and the body of the block.
+func decodeEra1Block(header, body []byte) (ethereum.ArchivedBlock, error) {
+	headerFields, err := rlpList(header)
+	if err != nil {
+		return ethereum.ArchivedBlock{}, fmt.Errorf("header: %w", err)
+	}
+
+	// number is the 9th field, after the parent, uncles, coinbase, roots, bloom and difficulty
+	if len(headerFields) < 9 || len(headerFields[8].content) > 8 {
+		return ethereum.ArchivedBlock{}, fmt.Errorf("%w: header without number", ErrInvalidEra1)
+	}
+
+	number := new(big.Int).SetBytes(headerFields[8].content).Int64()
+	hash := keccak.Sum256(header)
+
+	// timestamp is the 12th field, after the gas limit and used gas
+	var timestamp string
+	if len(headerFields) >= 12 {
+		timestamp = quantity(headerFields[11])
+	}
+
+	block := ethereum.ArchivedBlock{
+		Number: number,
+		Hash:   hexutil.Encode(hash[:]),
+	}
+
+	// body is the list of transactions and uncles
+	bodyFields, err := rlpList(body)
+	if err != nil || len(bodyFields) < 1 || !bodyFields[0].list {
+		return block, fmt.Errorf("%w: block %d body", ErrInvalidEra1, number)
+	}
+
+	encoded, err := rlpItems(bodyFields[0].content)
+	if err != nil {
+		return block, fmt.Errorf("block %d transactions: %w", number, err)
+	}
+
+	for i, item := range encoded {
+		transaction, err := decodeTransaction(item)
+		if err != nil {
+			return block, fmt.Errorf("block %d transaction %d: %w", number, i, err)
+		}
+
+		transaction.BlockHash = block.Hash
+		transaction.BlockNumber = hexutil.EncodeUint64(uint64(number))
+		transaction.BlockTimestamp = timestamp
+		transaction.TransactionIndex = hexutil.EncodeUint64(uint64(i))
+
+		block.Transactions = append(block.Transactions, transaction)
+	}
+
+	return block, nil
+}
+
+// decodeTransaction decodes the legacy transaction, which is the rlp list, or
+// the typed one (EIP-2718), which is the string of the type and the rlp list.
+func decodeTransaction(item rlpItem) (ethereum.Transaction, error) {
+	if item.list {
+		return decodeLegacyTransaction(item)
+	}
+
+	if len(item.content) == 0 {
+		return ethereum.Transaction{}, fmt.Errorf("%w: empty transaction", errRLP)
+	}
+
+	txType, payload := item.content[0], item.content[1:]
+
+	fields, err := rlpList(payload)
+	if err != nil {
+		return ethereum.Transaction{}, err
+	}
+
+	var t ethereum.Transaction
+
+	// fields before to differ, the rest is the same
+	var rest []rlpItem
+	switch txType {
+	case 0x01:
+		// chain id, nonce, gas price, gas, to, value, data, access list, y parity, r, s
+		if len(fields) != 11 {
+			return t, fmt.Errorf("%w: access list transaction of %d fields", errRLP, len(fields))
+		}
+
+		t.GasPrice = quantity(fields[2])
+		t.Gas = quantity(fields[3])
+		rest = fields[4:]
+	case 0x02:
+		// chain id, nonce, max priority fee, max fee, gas, to, value, data, access list, y parity, r, s
+		if len(fields) != 12 {
+			return t, fmt.Errorf("%w: dynamic fee transaction of %d fields", errRLP, len(fields))
+		}
+
+		t.MaxPriorityFeePerGas = quantity(fields[2])
+		t.MaxFeePerGas = quantity(fields[3])
+		t.Gas = quantity(fields[4])
+		rest = fields[5:]
+	default:
+		return t, fmt.Errorf("unsupported transaction type %#x", txType)
+	}
+
+	hash := keccak.Sum256(item.content)
+
+	t.Type = hexutil.EncodeUint64(uint64(txType))
+	t.Hash = hexutil.Encode(hash[:])
+	t.ChainId = quantity(fields[0])
+	t.Nonce = quantity(fields[1])
+	t.To = address(rest[0])
+	t.Value = quantity(rest[1])
+	t.Input = hexutil.Encode(rest[2].content)
+	t.V = quantity(rest[4])
+	t.R = quantity(rest[5])
+	t.S = quantity(rest[6])
+
+	accessList, err := rlpItems(rest[3].content)
+	if err != nil {
+		return t, fmt.Errorf("access list: %w", err)
+	}
+
+	for _, entry := range accessList {
+		entryFields, err := rlpItems(entry.content)
+		if err != nil || len(entryFields) != 2 {
+			return t, fmt.Errorf("%w: access list entry", errRLP)
+		}
+
+		keys, err := rlpItems(entryFields[1].content)
+		if err != nil {
+			return t, fmt.Errorf("access list keys: %w", err)
+		}
+
+		storageKeys := make([]string, 0, len(keys))
+		for _, key := range keys {
+			storageKeys = append(storageKeys, hexutil.Encode(key.content))
+		}
+
+		t.AccessList = append(t.AccessList, struct {
+			Address     string   `json:"address"`
+			StorageKeys []string `json:"storageKeys"`
+		}{Address: address(entryFields[0]), StorageKeys: storageKeys})
+	}
+
+	return t, nil
+}
+
+// decodeLegacyTransaction decodes nonce, gas price, gas, to, value, data,
+// v, r and s. Chain id is in v since EIP-155, older ones don't have it.
+func decodeLegacyTransaction(item rlpItem) (ethereum.Transaction, error) {
+	fields, err := rlpItems(item.content)
+	if err != nil {
+		return ethereum.Transaction{}, err
+	}
+
+	if len(fields) != 9 {
+		return ethereum.Transaction{}, fmt.Errorf("%w: legacy transaction of %d fields", errRLP, len(fields))
+	}
+
+	hash := keccak.Sum256(item.raw)
+
+	t := ethereum.Transaction{
+		Type:     "0x0",
+		Hash:     hexutil.Encode(hash[:]),
+		Nonce:    quantity(fields[0]),
+		GasPrice: quantity(fields[1]),
+		Gas:      quantity(fields[2]),
+		To:       address(fields[3]),
+		Value:    quantity(fields[4]),
+		Input:    hexutil.Encode(fields[5].content),
+		V:        quantity(fields[6]),
+		R:        quantity(fields[7]),
+		S:        quantity(fields[8]),
+	}
+
+	v := new(big.Int).SetBytes(fields[6].content)
+	if v.Cmp(big.NewInt(35)) >= 0 {
+		chainID := v.Sub(v, big.NewInt(35))
+		t.ChainId = hexutil.EncodeBig(chainID.Rsh(chainID, 1))
+	}
+
+	return t, nil
+}
+
+// quantity encodes the rlp integer as hex quantity.
+func quantity(item rlpItem) string {
+	return hexutil.EncodeBig(new(big.Int).SetBytes(item.content))
+}
+
+// address encodes the address like the api does, contract creation has none.
+func address(item rlpItem) string {
+	if len(item.content) == 0 {
+		return ""
+	}
+
+	return hexutil.Encode(item.content)
+}
@@ -0,0 +1,108 @@
+package archive
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errRLP = errors.New("invalid rlp")
+
+// rlpItem is the decoded rlp item, raw is its whole encoding.
+type rlpItem struct {
+	list    bool
+	content []byte
+	raw     []byte
+}
+
+// rlpSplit decodes the first item of b, it returns the rest.
+func rlpSplit(b []byte) (rlpItem, []byte, error) {
+	if len(b) == 0 {
+		return rlpItem{}, nil, fmt.Errorf("%w: unexpected end", errRLP)
+	}
+
+	prefix := b[0]
+
+	var (
+		list         bool
+		offset, size uint64
+	)
+
+	switch {
+	case prefix < 0x80:
+		return rlpItem{content: b[:1], raw: b[:1]}, b[1:], nil
+	case prefix <= 0xb7:
+		offset, size = 1, uint64(prefix-0x80)
+	case prefix <= 0xbf:
+		n := uint64(prefix - 0xb7)
+		length, err := rlpLength(b[1:], n)
+		if err != nil {
+			return rlpItem{}, nil, err
+		}
+
+		offset, size = 1+n, length
+	case prefix <= 0xf7:
+		list = true
+		offset, size = 1, uint64(prefix-0xc0)
+	default:
+		list = true
+		n := uint64(prefix - 0xf7)
+		length, err := rlpLength(b[1:], n)
+		if err != nil {
+			return rlpItem{}, nil, err
+		}
+
+		offset, size = 1+n, length
+	}
+
+	if size > uint64(len(b))-offset {
+		return rlpItem{}, nil, fmt.Errorf("%w: item of %d bytes, %d left", errRLP, size, uint64(len(b))-offset)
+	}
+
+	end := offset + size
+
+	return rlpItem{list: list, content: b[offset:end], raw: b[:end]}, b[end:], nil
+}
+
+// rlpLength decodes the big endian length of n bytes.
+func rlpLength(b []byte, n uint64) (uint64, error) {
+	if uint64(len(b)) < n || n > 8 {
+		return 0, fmt.Errorf("%w: invalid length of length", errRLP)
+	}
+
+	var length uint64
+	for _, c := range b[:n] {
+		length = length<<8 | uint64(c)
+	}
+
+	return length, nil
+}
+
+// rlpList decodes the list item into its items.
+func rlpList(b []byte) ([]rlpItem, error) {
+	item, rest, err := rlpSplit(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if !item.list || len(rest) > 0 {
+		return nil, fmt.Errorf("%w: expected single list", errRLP)
+	}
+
+	return rlpItems(item.content)
+}
+
+// rlpItems decodes the content of the list.
+func rlpItems(content []byte) ([]rlpItem, error) {
+	var items []rlpItem
+	for len(content) > 0 {
+		item, rest, err := rlpSplit(content)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+		content = rest
+	}
+
+	return items, nil
+}
@@ -0,0 +1,187 @@
+package archive
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+)
+
+var errSnappy = errors.New("invalid snappy data")
+
+const (
+	snappyChunkCompressed   = 0x00
+	snappyChunkUncompressed = 0x01
+	snappyChunkPadding      = 0xfe
+	snappyChunkStream       = 0xff
+
+	snappyStreamID = "sNaPpY"
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// snappyFramed decompresses the data of the snappy framing format,
+// which is used by era1 for the headers, bodies and receipts.
+func snappyFramed(data []byte) ([]byte, error) {
+	var out []byte
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("%w: truncated chunk header", errSnappy)
+		}
+
+		chunkType := data[0]
+		length := int(data[1]) | int(data[2])<<8 | int(data[3])<<16
+		data = data[4:]
+
+		if len(data) < length {
+			return nil, fmt.Errorf("%w: truncated chunk", errSnappy)
+		}
+
+		chunk := data[:length]
+		data = data[length:]
+
+		switch {
+		case chunkType == snappyChunkStream:
+			if string(chunk) != snappyStreamID {
+				return nil, fmt.Errorf("%w: invalid stream identifier", errSnappy)
+			}
+		case chunkType == snappyChunkCompressed || chunkType == snappyChunkUncompressed:
+			if len(chunk) < 4 {
+				return nil, fmt.Errorf("%w: chunk without checksum", errSnappy)
+			}
+
+			decoded := chunk[4:]
+			if chunkType == snappyChunkCompressed {
+				var err error
+				if decoded, err = snappyBlock(decoded); err != nil {
+					return nil, err
+				}
+			}
+
+			if maskedCRC(decoded) != binary.LittleEndian.Uint32(chunk) {
+				return nil, fmt.Errorf("%w: checksum mismatch", errSnappy)
+			}
+
+			out = append(out, decoded...)
+		case chunkType == snappyChunkPadding || chunkType >= 0x80:
+			// skippable
+		default:
+			return nil, fmt.Errorf("%w: reserved chunk type %#x", errSnappy, chunkType)
+		}
+	}
+
+	return out, nil
+}
+
+// snappyBlock decompresses the snappy block: length of the output,
+// then the literals and the copies of the previous output.
+func snappyBlock(src []byte) ([]byte, error) {
+	length, n := binary.Uvarint(src)
+	if n <= 0 || length > 1<<32 {
+		return nil, fmt.Errorf("%w: invalid block length", errSnappy)
+	}
+
+	src = src[n:]
+	// length comes from the data, it's not trusted with the allocation
+	dst := make([]byte, 0, min(length, 1<<20))
+
+	for len(src) > 0 {
+		tag := src[0]
+
+		switch tag & 0x03 {
+		case 0x00:
+			size := int(tag>>2) + 1
+			src = src[1:]
+
+			if size > 60 {
+				// length-1 is in the next 1..4 bytes
+				extra := size - 60
+				if len(src) < extra {
+					return nil, fmt.Errorf("%w: truncated literal", errSnappy)
+				}
+
+				size = 0
+				for i := extra - 1; i >= 0; i-- {
+					size = size<<8 | int(src[i])
+				}
+
+				size++
+				src = src[extra:]
+			}
+
+			if size < 0 || len(src) < size {
+				return nil, fmt.Errorf("%w: truncated literal", errSnappy)
+			}
+
+			dst = append(dst, src[:size]...)
+			src = src[size:]
+		case 0x01:
+			if len(src) < 2 {
+				return nil, fmt.Errorf("%w: truncated copy", errSnappy)
+			}
+
+			size := 4 + int(tag>>2)&0x07
+			offset := int(tag&0xe0)<<3 | int(src[1])
+			src = src[2:]
+
+			if err := snappyCopy(&dst, offset, size); err != nil {
+				return nil, err
+			}
+		case 0x02:
+			if len(src) < 3 {
+				return nil, fmt.Errorf("%w: truncated copy", errSnappy)
+			}
+
+			size := 1 + int(tag>>2)
+			offset := int(binary.LittleEndian.Uint16(src[1:]))
+			src = src[3:]
+
+			if err := snappyCopy(&dst, offset, size); err != nil {
+				return nil, err
+			}
+		case 0x03:
+			if len(src) < 5 {
+				return nil, fmt.Errorf("%w: truncated copy", errSnappy)
+			}
+
+			size := 1 + int(tag>>2)
+			offset := int(binary.LittleEndian.Uint32(src[1:]))
+			src = src[5:]
+
+			if err := snappyCopy(&dst, offset, size); err != nil {
+				return nil, err
+			}
+		}
+
+		if uint64(len(dst)) > length {
+			return nil, fmt.Errorf("%w: decoded more than %d bytes", errSnappy, length)
+		}
+	}
+
+	if uint64(len(dst)) != length {
+		return nil, fmt.Errorf("%w: decoded %d bytes, want %d", errSnappy, len(dst), length)
+	}
+
+	return dst, nil
+}
+
+// snappyCopy appends size bytes from offset back, the copy can overlap itself.
+func snappyCopy(dst *[]byte, offset, size int) error {
+	if offset <= 0 || offset > len(*dst) {
+		return fmt.Errorf("%w: invalid copy offset", errSnappy)
+	}
+
+	start := len(*dst) - offset
+	for i := 0; i < size; i++ {
+		*dst = append(*dst, (*dst)[start+i])
+	}
+
+	return nil
+}
+
+// maskedCRC is the checksum of the chunk.
+func maskedCRC(b []byte) uint32 {
+	c := crc32.Checksum(b, crcTable)
+
+	return (c>>15 | c<<17) + 0xa282ead8
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+
+	"tw/internal/ethereum"
+)
+
+const redacted = "xxxxx"
+
+// StaticHeaders sets the same headers on every
+// request, i.e. api keys of the rpc providers.
+type StaticHeaders struct {
+	headers map[string]string
+}
+
+var _ ethereum.RequestAuthenticator = (*StaticHeaders)(nil)
+var _ fmt.Stringer = (*StaticHeaders)(nil)
+
+func NewStaticHeaders(headers map[string]string) *StaticHeaders {
+	copied := make(map[string]string, len(headers))
+	for k, v := range headers {
+		copied[k] = v
+	}
+
+	return &StaticHeaders{
+		headers: copied,
+	}
+}
+
+// Authenticate sets the headers on the request.
+func (sh *StaticHeaders) Authenticate(req *http.Request) error {
+	for k, v := range sh.headers {
+		req.Header.Set(k, v)
+	}
+
+	return nil
+}
+
+// String returns header names with values redacted.
+func (sh *StaticHeaders) String() string {
+	names := make([]string, 0, len(sh.headers))
+	for k := range sh.headers {
+		names = append(names, fmt.Sprintf("%s: %s", k, redacted))
+	}
+
+	sort.Strings(names)
+
+	return fmt.Sprintf("static headers [%s]", strings.Join(names, ", "))
+}
+
+// BasicAuth authenticates requests with the
+// http basic authentication.
+type BasicAuth struct {
+	username string
+	password string
+}
+
+var _ ethereum.RequestAuthenticator = (*BasicAuth)(nil)
+var _ fmt.Stringer = (*BasicAuth)(nil)
+
+func NewBasicAuth(username, password string) *BasicAuth {
+	return &BasicAuth{
+		username: username,
+		password: password,
+	}
+}
+
+// Authenticate sets the Authorization header on the request.
+func (ba *BasicAuth) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(ba.username+":"+ba.password)))
+
+	return nil
+}
+
+// String returns username with password redacted.
+func (ba *BasicAuth) String() string {
+	return fmt.Sprintf("basic auth %s:%s", ba.username, redacted)
+}
@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuthenticators_Authenticate(t *testing.T) {
+	tests := []struct {
+		name          string
+		authenticator interface {
+			Authenticate(req *http.Request) error
+		}
+		header string
+		want   string
+	}{
+		{
+			name:          "static headers sets header",
+			authenticator: NewStaticHeaders(map[string]string{"X-Api-Key": "secret"}),
+			header:        "X-Api-Key",
+			want:          "secret",
+		},
+		{
+			name:          "basic auth sets authorization header",
+			authenticator: NewBasicAuth("user", "pass"),
+			header:        "Authorization",
+			want:          "Basic dXNlcjpwYXNz",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodPost, "http://localhost", nil)
+
+			if err := tt.authenticator.Authenticate(req); err != nil {
+				t.Fatalf("Authenticate() error = %v", err)
+			}
+
+			if got := req.Header.Get(tt.header); got != tt.want {
+				t.Errorf("header %s = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticators_StringRedactsSecrets(t *testing.T) {
+	jwtAuth, _ := NewJWTAuth([]byte("supersecret"), 0)
+
+	tests := []struct {
+		name          string
+		authenticator interface{ String() string }
+		secret        string
+	}{
+		{
+			name:          "static headers",
+			authenticator: NewStaticHeaders(map[string]string{"X-Api-Key": "supersecret"}),
+			secret:        "supersecret",
+		},
+		{
+			name:          "basic auth",
+			authenticator: NewBasicAuth("user", "supersecret"),
+			secret:        "supersecret",
+		},
+		{
+			name:          "jwt",
+			authenticator: jwtAuth,
+			secret:        "supersecret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.authenticator.String(); strings.Contains(got, tt.secret) {
+				t.Errorf("String() = %v, contains secret", got)
+			}
+		})
+	}
+}
+
+func TestJWTAuth_SignsAndRotatesToken(t *testing.T) {
+	secret := []byte("0123456789abcdef0123456789abcdef")
+
+	jwtAuth, err := NewJWTAuth(secret, time.Minute)
+	if err != nil {
+		t.Fatalf("NewJWTAuth() error = %v", err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	jwtAuth.now = func() time.Time { return now }
+
+	first, _ := jwtAuth.currentToken()
+
+	parts := strings.Split(first, ".")
+	if len(parts) != 3 {
+		t.Fatalf("token = %v, want three parts", first)
+	}
+
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if want := base64.RawURLEncoding.EncodeToString(mac.Sum(nil)); parts[2] != want {
+		t.Errorf("signature = %v, want %v", parts[2], want)
+	}
+
+	claims, _ := base64.RawURLEncoding.DecodeString(parts[1])
+	if string(claims) != `{"iat":1700000000}` {
+		t.Errorf("claims = %s", claims)
+	}
+
+	now = now.Add(30 * time.Second)
+	if second, _ := jwtAuth.currentToken(); second != first {
+		t.Errorf("token rotated before rotation period")
+	}
+
+	now = now.Add(time.Minute)
+	if third, _ := jwtAuth.currentToken(); third == first {
+		t.Errorf("token not rotated after rotation period")
+	}
+}
+
+func TestBearerTokenFile_ReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token")
+
+	if err := os.WriteFile(path, []byte("first\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	bf := NewBearerTokenFile(path)
+
+	if got, _ := bf.currentToken(); got != "first" {
+		t.Errorf("currentToken() = %v, want first", got)
+	}
+
+	if err := os.WriteFile(path, []byte("second"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// make sure mod time differs even on file systems with low resolution
+	_ = os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+
+	if got, _ := bf.currentToken(); got != "second" {
+		t.Errorf("currentToken() = %v, want second", got)
+	}
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+// BearerTokenFile authenticates requests with the bearer token read
+// from the file. File is read again whenever it changes, so the
+// token can be rotated without restarting (i.e. mounted secrets).
+type BearerTokenFile struct {
+	path string
+
+	token   string
+	modTime time.Time
+	mu      sync.Mutex
+}
+
+var _ ethereum.RequestAuthenticator = (*BearerTokenFile)(nil)
+var _ fmt.Stringer = (*BearerTokenFile)(nil)
+
+func NewBearerTokenFile(path string) *BearerTokenFile {
+	return &BearerTokenFile{
+		path: path,
+	}
+}
+
+// Authenticate sets the bearer token on the request.
+func (bf *BearerTokenFile) Authenticate(req *http.Request) error {
+	token, err := bf.currentToken()
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return nil
+}
+
+// String returns the file path, the token is never logged.
+func (bf *BearerTokenFile) String() string {
+	return fmt.Sprintf("bearer token from file %s", bf.path)
+}
+
+func (bf *BearerTokenFile) currentToken() (string, error) {
+	bf.mu.Lock()
+	defer bf.mu.Unlock()
+
+	info, err := os.Stat(bf.path)
+	if err != nil {
+		return "", fmt.Errorf("stat bearer token file: %w", err)
+	}
+
+	if bf.token != "" && info.ModTime().Equal(bf.modTime) {
+		return bf.token, nil
+	}
+
+	content, err := os.ReadFile(bf.path)
+	if err != nil {
+		return "", fmt.Errorf("read bearer token file: %w", err)
+	}
+
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", errors.New("bearer token file is empty")
+	}
+
+	bf.token = token
+	bf.modTime = info.ModTime()
+
+	return bf.token, nil
+}
@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+// DefaultJWTRotation is how often new token is generated. Nodes are
+// accepting tokens issued at most 60 seconds ago, so it has to be less.
+const DefaultJWTRotation = 30 * time.Second
+
+// jwtHeader is the base64 encoded {"alg":"HS256","typ":"JWT"} header.
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// JWTAuth authenticates requests with the HS256 signed token, the same
+// way execution clients authenticate engine api requests. Token
+// is rotated, so its issued at claim is always fresh.
+type JWTAuth struct {
+	secret   []byte
+	rotation time.Duration
+	now      func() time.Time
+
+	token    string
+	issuedAt time.Time
+	mu       sync.Mutex
+}
+
+var _ ethereum.RequestAuthenticator = (*JWTAuth)(nil)
+var _ fmt.Stringer = (*JWTAuth)(nil)
+
+// NewJWTAuth creates a new instance of JWTAuth. Rotation 0 means DefaultJWTRotation.
+func NewJWTAuth(secret []byte, rotation time.Duration) (*JWTAuth, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("jwt secret is empty")
+	}
+
+	if rotation <= 0 {
+		rotation = DefaultJWTRotation
+	}
+
+	return &JWTAuth{
+		secret:   secret,
+		rotation: rotation,
+		now:      time.Now,
+	}, nil
+}
+
+// NewJWTAuthFromFile creates a new instance of JWTAuth with the secret read from
+// the hex encoded file, like the jwtsecret file generated by the nodes.
+func NewJWTAuthFromFile(path string, rotation time.Duration) (*JWTAuth, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read jwt secret file: %w", err)
+	}
+
+	secret, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(content)), "0x"))
+	if err != nil {
+		return nil, fmt.Errorf("decode jwt secret: %w", err)
+	}
+
+	return NewJWTAuth(secret, rotation)
+}
+
+// Authenticate sets the bearer token on the request.
+func (ja *JWTAuth) Authenticate(req *http.Request) error {
+	token, err := ja.currentToken()
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	return nil
+}
+
+// String returns description of the authenticator without the secret.
+func (ja *JWTAuth) String() string {
+	return fmt.Sprintf("jwt hs256 secret: %s, rotation: %s", redacted, ja.rotation)
+}
+
+// currentToken returns token, new one is signed if the
+// current one is older than the rotation period.
+func (ja *JWTAuth) currentToken() (string, error) {
+	ja.mu.Lock()
+	defer ja.mu.Unlock()
+
+	now := ja.now()
+	if ja.token != "" && now.Sub(ja.issuedAt) < ja.rotation {
+		return ja.token, nil
+	}
+
+	claims, err := json.Marshal(struct {
+		IssuedAt int64 `json:"iat"`
+	}{
+		IssuedAt: now.Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("json marshal jwt claims: %w", err)
+	}
+
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	mac := hmac.New(sha256.New, ja.secret)
+	mac.Write([]byte(unsigned))
+
+	ja.token = unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+	ja.issuedAt = now
+
+	return ja.token, nil
+}
@@ -1,6 +1,89 @@
+// Package clogger has the loggers of the project and the adapter,
+// which passes structured records to the standard *log.Logger.
 package clogger
 
-import "log"
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+)
+
+const (
+	// FormatText is key=value text format of the records.
+	FormatText = "text"
+	// FormatJSON is one json object per record.
+	FormatJSON = "json"
+)
 
 // ConsoleLogger is a simple logger that logs output to the console.
-var ConsoleLogger = log.New(&consoleWriter{}, "logger: ", log.Ldate|log.Ltime|log.Lshortfile)
+var ConsoleLogger = log.New(os.Stderr, "logger: ", log.Ldate|log.Ltime|log.Lshortfile)
+
+// Logger is the default logger, it writes info and higher levels
+// as text to the console.
+var Logger = slog.New(slog.NewTextHandler(os.Stderr, nil))
+
+// New creates the logger writing records of the level and higher in the format.
+func New(w io.Writer, format string, level slog.Level) (*slog.Logger, error) {
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch format {
+	case FormatText, "":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case FormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("unknown log format %q, want %s or %s", format, FormatText, FormatJSON)
+	}
+}
+
+// ParseLevel parses debug, info, warn or error, empty means info.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if name == "" {
+		return level, nil
+	}
+
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("unknown log level %q, want debug, info, warn or error", name)
+	}
+
+	return level, nil
+}
+
+// FromLog adapts the logger, so it can be passed where *slog.Logger is
+// expected. Records of info and higher levels are written as "level=INFO
+// msg=... key=value" lines, timestamp is left to the logger. Nil logger
+// stays nil.
+func FromLog(logger *log.Logger) *slog.Logger {
+	if logger == nil {
+		return nil
+	}
+
+	return slog.New(slog.NewTextHandler(&logWriter{logger: logger}, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	}))
+}
+
+// logWriter writes every line through the logger, text handler
+// writes each record with one Write call.
+type logWriter struct {
+	logger *log.Logger
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	for _, line := range strings.Split(string(bytes.TrimSuffix(p, []byte("\n"))), "\n") {
+		w.logger.Print(line)
+	}
+
+	return len(p), nil
+}
@@ -0,0 +1,63 @@
+package clogger
+
+import (
+	"bytes"
+	"log"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestFromLog(t *testing.T) {
+	var b bytes.Buffer
+	logger := FromLog(log.New(&b, "tw: ", 0))
+
+	logger.Debug("checking for new block")
+	logger.Info("new block found", "block", 16, "address", "0x1")
+
+	if got, want := b.String(), "tw: level=INFO msg=\"new block found\" block=16 address=0x1\n"; got != want {
+		t.Errorf("logged %q, want %q", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  string
+		level   string
+		want    string
+		wantErr bool
+	}{
+		{name: "text", format: FormatText, level: "info", want: "level=INFO msg=started"},
+		{name: "json", format: FormatJSON, level: "debug", want: `"level":"INFO","msg":"started"`},
+		{name: "level filters", format: FormatText, level: "error"},
+		{name: "unknown format", format: "xml", wantErr: true},
+		{name: "unknown level", format: FormatText, level: "verbose", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			level, err := ParseLevel(tt.level)
+			var logger *slog.Logger
+			if err == nil {
+				logger, err = New(&b, tt.format, level)
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if err != nil {
+				return
+			}
+
+			logger.Info("started")
+
+			if !strings.Contains(b.String(), tt.want) || (tt.want == "") != (b.Len() == 0) {
+				t.Errorf("logged %q, want %q", b.String(), tt.want)
+			}
+		})
+	}
+}
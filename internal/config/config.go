@@ -0,0 +1,442 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+)
+
+// Config describes the whole deployment: networks with their endpoints
+// and watched addresses, storage backend and notification sinks.
+type Config struct {
+	PollInterval  Duration       `toml:"poll_interval"`
+	Confirmations int64          `toml:"confirmations"`
+	StartBlock    int64          `toml:"start_block"`
+	Storage       Storage        `toml:"storage"`
+	RateLimit     RateLimit      `toml:"rate_limit"`
+	Queue         Queue          `toml:"queue"`
+	HTTP          HTTP           `toml:"http"`
+	GRPC          GRPC           `toml:"grpc"`
+	Log           Log            `toml:"log"`
+	Tracing       Tracing        `toml:"tracing"`
+	Health        Health         `toml:"health"`
+	Networks      []Network      `toml:"networks"`
+	Notifications []Notification `toml:"notifications"`
+}
+
+// Network is the observed network. Chain id and endpoint of the known
+// networks (mainnet, sepolia, polygon, arbitrum, base) can be omitted.
+type Network struct {
+	Name      string   `toml:"name"`
+	ChainID   int64    `toml:"chain_id"`
+	Endpoints []string `toml:"endpoints"`
+	Addresses []string `toml:"addresses"`
+	Auth      Auth     `toml:"auth"`
+}
+
+// Auth are the credentials of the network endpoints.
+type Auth struct {
+	// Type is one of: basic, bearer-file, jwt-file. Empty means no credentials.
+	Type     string `toml:"type"`
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+	// Path is the file with the token (bearer-file) or hex secret (jwt-file).
+	Path string `toml:"path"`
+}
+
+// Storage is the transactions storage backend.
+type Storage struct {
+	// Backend is memory or file.
+	Backend string `toml:"backend"`
+	// Path is the file of the file backend.
+	Path string `toml:"path"`
+}
+
+type RateLimit struct {
+	RequestsPerSecond float64 `toml:"requests_per_second"`
+	Burst             int     `toml:"burst"`
+	DailyBudget       uint64  `toml:"daily_budget"`
+	MonthlyBudget     uint64  `toml:"monthly_budget"`
+}
+
+type Queue struct {
+	Size     int    `toml:"size"`
+	Policy   string `toml:"policy"`
+	SpillDir string `toml:"spill_dir"`
+}
+
+// HTTP is the http api server of the daemon.
+type HTTP struct {
+	// Listen is the address the api listens on, i.e. ":8080". Empty disables the api.
+	Listen string `toml:"listen"`
+	// AllowedOrigins are the origins of the browser pages which can open the
+	// websockets, i.e. "https://app.example.com", besides the api host. "*" allows all.
+	AllowedOrigins []string `toml:"allowed_origins"`
+}
+
+// GRPC is the gRPC api server of the daemon.
+type GRPC struct {
+	// Listen is the address the api listens on, i.e. ":9090". Empty disables the api.
+	Listen string `toml:"listen"`
+}
+
+// Log is the logging of the parsers and the daemon.
+type Log struct {
+	// Level is debug, info, warn or error.
+	Level string `toml:"level"`
+	// Format is text or json.
+	Format string `toml:"format"`
+}
+
+// Tracing is where the spans of the block processing go.
+type Tracing struct {
+	// Exporter is stdout, empty disables the tracing.
+	Exporter string `toml:"exporter"`
+}
+
+// Health are the thresholds of the /healthz and /readyz endpoints, zero disables the check.
+type Health struct {
+	// MaxLagBlocks is how many blocks the processed block can be behind the head.
+	MaxLagBlocks int64 `toml:"max_lag_blocks"`
+	// MaxLag is how long the observer can go without polling or processing a block while behind.
+	MaxLag Duration `toml:"max_lag"`
+	// MaxFailures is the number of polls in a row that all the endpoints can fail.
+	MaxFailures int `toml:"max_failures"`
+}
+
+// Notification is the sink the events are sent to.
+type Notification struct {
+	// Type is webhook or log.
+	Type string `toml:"type"`
+	// URL is the webhook url.
+	URL string `toml:"url"`
+	// Secret is used to sign the webhook requests.
+	Secret string `toml:"secret"`
+	// OutboxDir keeps the pending webhook deliveries over the restarts, empty means in memory.
+	OutboxDir string `toml:"outbox_dir"`
+	// MaxAttempts of the webhook delivery, 0 means the default.
+	MaxAttempts int `toml:"max_attempts"`
+	// Addresses limits notifications to the given addresses, empty means all watched ones.
+	Addresses []string `toml:"addresses"`
+}
+
+const (
+	StorageMemory = "memory"
+	StorageFile   = "file"
+
+	AuthBasic      = "basic"
+	AuthBearerFile = "bearer-file"
+	AuthJWTFile    = "jwt-file"
+
+	NotificationWebhook = "webhook"
+	NotificationLog     = "log"
+
+	TracingStdout = "stdout"
+)
+
+// Default returns config used for the keys that are not set.
+func Default() Config {
+	return Config{
+		PollInterval:  Duration(5 * time.Second),
+		Confirmations: 12,
+		Storage: Storage{
+			Backend: StorageMemory,
+		},
+		RateLimit: RateLimit{
+			RequestsPerSecond: 5,
+			Burst:             5,
+		},
+		Queue: Queue{
+			Policy: ethereum.Block.String(),
+		},
+		Log: Log{
+			Level:  "info",
+			Format: clogger.FormatText,
+		},
+		Health: Health{
+			MaxLagBlocks: ethereum.DefaultHealthConfig.MaxLagBlocks,
+			MaxLag:       Duration(ethereum.DefaultHealthConfig.MaxLagTime),
+			MaxFailures:  ethereum.DefaultHealthConfig.MaxFailures,
+		},
+	}
+}
+
+// Duration is time.Duration written as string in the config, i.e. "5s".
+type Duration time.Duration
+
+// UnmarshalText parses the duration string.
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", text)
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// Load reads the config file (empty path means defaults only) and applies
+// TW_* overrides from the environment, use Validate to check the values.
+func Load(path string, environ []string) (Config, error) {
+	config := Default()
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return config, fmt.Errorf("read config file: %w", err)
+		}
+
+		if err := Parse(string(data), &config); err != nil {
+			return config, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+
+	if err := ApplyEnv(&config, environ); err != nil {
+		return config, fmt.Errorf("environment: %w", err)
+	}
+
+	return config, nil
+}
+
+// Parse parses the TOML config into the config, keys that are not in data are
+// left untouched. Unknown keys are reported all at once.
+func Parse(data string, config *Config) error {
+	meta, err := toml.Decode(data, config)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	var unknown []string
+	for _, key := range meta.Undecoded() {
+		name := key.String()
+
+		// keys of the unknown table aren't reported again
+		if len(unknown) > 0 && strings.HasPrefix(name, unknown[len(unknown)-1]+".") {
+			continue
+		}
+
+		unknown = append(unknown, name)
+		errs = append(errs, fmt.Errorf("%s: unknown key", name))
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate returns all the problems with the config at once.
+func (c *Config) Validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.PollInterval <= 0 {
+		invalid("poll_interval must be positive, got %s", time.Duration(c.PollInterval))
+	}
+
+	if c.Confirmations < 0 {
+		invalid("confirmations can't be negative, got %d", c.Confirmations)
+	}
+
+	if c.StartBlock < 0 {
+		invalid("start_block can't be negative, got %d", c.StartBlock)
+	}
+
+	switch c.Storage.Backend {
+	case StorageMemory:
+	case StorageFile:
+		if c.Storage.Path == "" {
+			invalid("storage.path is required by the file backend")
+		}
+	default:
+		invalid("storage.backend must be %s or %s, got %q", StorageMemory, StorageFile, c.Storage.Backend)
+	}
+
+	if c.RateLimit.RequestsPerSecond < 0 {
+		invalid("rate_limit.requests_per_second can't be negative, got %v", c.RateLimit.RequestsPerSecond)
+	}
+
+	if c.RateLimit.RequestsPerSecond > 0 && c.RateLimit.Burst < 1 {
+		invalid("rate_limit.burst must be at least 1, got %d", c.RateLimit.Burst)
+	}
+
+	if c.Queue.Size < 0 {
+		invalid("queue.size can't be negative, got %d", c.Queue.Size)
+	}
+
+	if _, err := ethereum.ParseOverflowPolicy(c.Queue.Policy); err != nil {
+		invalid("queue.policy: %s", err.Error())
+	}
+
+	if c.HTTP.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.HTTP.Listen); err != nil {
+			invalid("http.listen: %s", err.Error())
+		}
+	}
+
+	for i, origin := range c.HTTP.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+
+		if u, err := ethereum.ParseHTTPURL(origin); err != nil {
+			invalid("http.allowed_origins[%d]: %s", i, err.Error())
+		} else if strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" || u.User != nil {
+			invalid("http.allowed_origins[%d] must be scheme://host[:port], got %q", i, origin)
+		}
+	}
+
+	if _, err := clogger.ParseLevel(c.Log.Level); err != nil {
+		invalid("log.level: %s", err.Error())
+	}
+
+	if c.Log.Format != clogger.FormatText && c.Log.Format != clogger.FormatJSON {
+		invalid("log.format must be %s or %s, got %q", clogger.FormatText, clogger.FormatJSON, c.Log.Format)
+	}
+
+	if c.Health.MaxLagBlocks < 0 || c.Health.MaxLag < 0 || c.Health.MaxFailures < 0 {
+		invalid("health thresholds can't be negative")
+	}
+
+	if c.Tracing.Exporter != "" && c.Tracing.Exporter != TracingStdout {
+		invalid("tracing.exporter must be %s or empty, got %q", TracingStdout, c.Tracing.Exporter)
+	}
+
+	if c.GRPC.Listen != "" {
+		if _, _, err := net.SplitHostPort(c.GRPC.Listen); err != nil {
+			invalid("grpc.listen: %s", err.Error())
+		}
+	}
+
+	if len(c.Networks) == 0 {
+		invalid("at least one network is required")
+	}
+
+	names := make(map[string]bool)
+	for i, network := range c.Networks {
+		path := fmt.Sprintf("networks[%d]", i)
+
+		if network.Name == "" {
+			invalid("%s.name is required", path)
+		} else if names[network.Name] {
+			invalid("%s.name %q is used more than once", path, network.Name)
+		}
+
+		names[network.Name] = true
+
+		resolved, err := network.Resolve()
+		if err != nil {
+			invalid("%s: %s", path, err.Error())
+		}
+
+		for j, endpoint := range resolved.Endpoints {
+			if _, err := ethereum.ParseHTTPURL(endpoint); err != nil {
+				invalid("%s.endpoints[%d]: %s", path, j, err.Error())
+			}
+		}
+
+		for j, address := range network.Addresses {
+			if _, err := ethereum.NormalizeAddress(address); err != nil {
+				invalid("%s.addresses[%d]: %s", path, j, err.Error())
+			}
+		}
+
+		errs = append(errs, network.Auth.validate(path+".auth")...)
+	}
+
+	// notifier delivers every delivery of its outbox, they can't be shared
+	outboxDirs := make(map[string]bool)
+	for i, notification := range c.Notifications {
+		path := fmt.Sprintf("notifications[%d]", i)
+
+		switch notification.Type {
+		case NotificationLog:
+		case NotificationWebhook:
+			if _, err := ethereum.ParseHTTPURL(notification.URL); err != nil {
+				invalid("%s.url: %s", path, err.Error())
+			}
+
+			if notification.MaxAttempts < 0 {
+				invalid("%s.max_attempts can't be negative, got %d", path, notification.MaxAttempts)
+			}
+
+			if notification.OutboxDir != "" {
+				dir := filepath.Clean(notification.OutboxDir)
+				if outboxDirs[dir] {
+					invalid("%s.outbox_dir %q is used more than once", path, notification.OutboxDir)
+				}
+
+				outboxDirs[dir] = true
+			}
+		default:
+			invalid("%s.type must be %s or %s, got %q", path, NotificationWebhook, NotificationLog, notification.Type)
+		}
+
+		for j, address := range notification.Addresses {
+			if _, err := ethereum.NormalizeAddress(address); err != nil {
+				invalid("%s.addresses[%d]: %s", path, j, err.Error())
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Resolve fills chain id and endpoint of the known network.
+func (n Network) Resolve() (Network, error) {
+	known, ok := ethereum.NetworkByName(n.Name)
+
+	switch {
+	case ok && n.ChainID == 0:
+		n.ChainID = known.ChainID
+	case ok && n.ChainID != known.ChainID:
+		return n, fmt.Errorf("chain_id of %s is %d, got %d", n.Name, known.ChainID, n.ChainID)
+	case !ok && n.ChainID <= 0:
+		return n, fmt.Errorf("chain_id is required for unknown network %q", n.Name)
+	}
+
+	if len(n.Endpoints) == 0 {
+		if !ok {
+			return n, fmt.Errorf("endpoints are required for unknown network %q", n.Name)
+		}
+
+		n.Endpoints = []string{known.DefaultEndpoint}
+	}
+
+	return n, nil
+}
+
+func (a Auth) validate(path string) []error {
+	var errs []error
+
+	switch a.Type {
+	case "":
+	case AuthBasic:
+		if a.Username == "" {
+			errs = append(errs, fmt.Errorf("%s.username is required by basic auth", path))
+		}
+	case AuthBearerFile, AuthJWTFile:
+		if a.Path == "" {
+			errs = append(errs, fmt.Errorf("%s.path is required by %s auth", path, a.Type))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s.type must be %s, %s or %s, got %q", path, AuthBasic, AuthBearerFile, AuthJWTFile, a.Type))
+	}
+
+	return errs
+}
+
+// envName is the network part of the TW_* variables, i.e. TW_MAINNET_ENDPOINTS.
+func envName(network string) string {
+	return strings.ToUpper(strings.ReplaceAll(network, "-", "_"))
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding the config.
+const EnvPrefix = "TW_"
+
+// ApplyEnv overrides the config with TW_* variables from environ (os.Environ
+// format). Global keys are upper cased, i.e. TW_POLL_INTERVAL, TW_STORAGE_PATH.
+// TW_NETWORKS adds networks (comma separated names) that are not in the config,
+// and network keys are prefixed with the network name, i.e. TW_MAINNET_ENDPOINTS,
+// TW_MAINNET_ADDRESSES (comma separated) or TW_MAINNET_AUTH_PASSWORD.
+func ApplyEnv(config *Config, environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if key, value, ok := strings.Cut(kv, "="); ok && strings.HasPrefix(key, EnvPrefix) {
+			env[strings.TrimPrefix(key, EnvPrefix)] = value
+		}
+	}
+
+	var errs []error
+
+	setDuration := func(key string, target *Duration) {
+		if v, ok := env[key]; ok {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: invalid duration %q", EnvPrefix, key, v))
+				return
+			}
+
+			*target = Duration(d)
+		}
+	}
+
+	setInt := func(key string, set func(n int64)) {
+		if v, ok := env[key]; ok {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s%s: invalid integer %q", EnvPrefix, key, v))
+				return
+			}
+
+			set(n)
+		}
+	}
+
+	setString := func(key string, target *string) {
+		if v, ok := env[key]; ok {
+			*target = v
+		}
+	}
+
+	setList := func(key string, target *[]string) {
+		if v, ok := env[key]; ok {
+			*target = splitList(v)
+		}
+	}
+
+	setDuration("POLL_INTERVAL", &config.PollInterval)
+	setInt("CONFIRMATIONS", func(n int64) { config.Confirmations = n })
+	setInt("START_BLOCK", func(n int64) { config.StartBlock = n })
+	setInt("QUEUE_SIZE", func(n int64) { config.Queue.Size = int(n) })
+	setInt("RATE_LIMIT_BURST", func(n int64) { config.RateLimit.Burst = int(n) })
+	setString("STORAGE_BACKEND", &config.Storage.Backend)
+	setString("STORAGE_PATH", &config.Storage.Path)
+	setString("QUEUE_POLICY", &config.Queue.Policy)
+	setString("QUEUE_SPILL_DIR", &config.Queue.SpillDir)
+	setString("HTTP_LISTEN", &config.HTTP.Listen)
+	setList("HTTP_ALLOWED_ORIGINS", &config.HTTP.AllowedOrigins)
+	setString("GRPC_LISTEN", &config.GRPC.Listen)
+	setString("LOG_LEVEL", &config.Log.Level)
+	setString("LOG_FORMAT", &config.Log.Format)
+	setString("TRACING_EXPORTER", &config.Tracing.Exporter)
+	setInt("HEALTH_MAX_LAG_BLOCKS", func(n int64) { config.Health.MaxLagBlocks = n })
+	setDuration("HEALTH_MAX_LAG", &config.Health.MaxLag)
+	setInt("HEALTH_MAX_FAILURES", func(n int64) { config.Health.MaxFailures = int(n) })
+
+	if v, ok := env["RATE_LIMIT_REQUESTS_PER_SECOND"]; ok {
+		rps, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%sRATE_LIMIT_REQUESTS_PER_SECOND: invalid number %q", EnvPrefix, v))
+		} else {
+			config.RateLimit.RequestsPerSecond = rps
+		}
+	}
+
+	if v, ok := env["NETWORKS"]; ok {
+		for _, name := range splitList(v) {
+			if !hasNetwork(config, name) {
+				config.Networks = append(config.Networks, Network{Name: name})
+			}
+		}
+	}
+
+	for i := range config.Networks {
+		network := &config.Networks[i]
+		prefix := envName(network.Name) + "_"
+
+		setInt(prefix+"CHAIN_ID", func(n int64) { network.ChainID = n })
+		setList(prefix+"ENDPOINTS", &network.Endpoints)
+		setList(prefix+"ADDRESSES", &network.Addresses)
+		setString(prefix+"AUTH_TYPE", &network.Auth.Type)
+		setString(prefix+"AUTH_USERNAME", &network.Auth.Username)
+		setString(prefix+"AUTH_PASSWORD", &network.Auth.Password)
+		setString(prefix+"AUTH_PATH", &network.Auth.Path)
+	}
+
+	return errors.Join(errs...)
+}
+
+func hasNetwork(config *Config, name string) bool {
+	for _, network := range config.Networks {
+		if strings.EqualFold(network.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitList splits comma separated list, skipping the empty items.
+func splitList(v string) []string {
+	var items []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
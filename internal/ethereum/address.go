@@ -0,0 +1,90 @@
+package ethereum
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"tw/internal/keccak"
+)
+
+// AddressLength is the length of the address in bytes.
+const AddressLength = 20
+
+var (
+	// ErrInvalidAddress is returned when address is not 20 bytes hex string.
+	ErrInvalidAddress = errors.New("invalid address")
+	// ErrInvalidAddressChecksum is returned when mixed case address doesn't match EIP-55 checksum.
+	ErrInvalidAddressChecksum = errors.New("invalid address checksum")
+)
+
+// Address is the ethereum account address.
+type Address [AddressLength]byte
+
+// ParseAddress parses 0x prefixed hex address. All lower or all upper case
+// addresses are accepted as they are, mixed case addresses have to match
+// the EIP-55 checksum, so typos are caught.
+func ParseAddress(s string) (Address, error) {
+	var address Address
+
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return address, fmt.Errorf("%w %q: missing 0x prefix", ErrInvalidAddress, s)
+	}
+
+	hexPart := s[2:]
+	if len(hexPart) != AddressLength*2 {
+		return address, fmt.Errorf("%w %q: expected %d hex characters, got %d", ErrInvalidAddress, s, AddressLength*2, len(hexPart))
+	}
+
+	if _, err := hex.Decode(address[:], []byte(hexPart)); err != nil {
+		return address, fmt.Errorf("%w %q: %s", ErrInvalidAddress, s, err.Error())
+	}
+
+	if hexPart != strings.ToLower(hexPart) && hexPart != strings.ToUpper(hexPart) && address.Hex() != "0x"+hexPart {
+		return address, fmt.Errorf("%w %q: did you mean %s?", ErrInvalidAddressChecksum, s, address.Hex())
+	}
+
+	return address, nil
+}
+
+// NormalizeAddress parses address and returns its canonical (checksummed)
+// form. The canonical form is used for all the storage keys.
+func NormalizeAddress(s string) (string, error) {
+	address, err := ParseAddress(s)
+	if err != nil {
+		return "", err
+	}
+
+	return address.Hex(), nil
+}
+
+// Hex returns EIP-55 checksummed hex representation of the address.
+func (a Address) Hex() string {
+	lower := hex.EncodeToString(a[:])
+	hash := keccak.Sum256([]byte(lower))
+
+	checksummed := []byte(lower)
+	for i, c := range checksummed {
+		if c < 'a' {
+			continue
+		}
+
+		// every hex character of the address is uppercased if the
+		// corresponding nibble of the hash is 8 or higher
+		nibble := hash[i/2]
+		if i%2 == 0 {
+			nibble >>= 4
+		}
+
+		if nibble&0x0f >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+
+	return "0x" + string(checksummed)
+}
+
+func (a Address) String() string {
+	return a.Hex()
+}
@@ -0,0 +1,63 @@
+package ethereum
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+		wantErr error
+	}{
+		{
+			name:    "checksummed address is accepted",
+			address: "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+			want:    "0xdAC17F958D2ee523a2206206994597C13D831ec7",
+		},
+		{
+			name:    "lower case address is normalized",
+			address: "0x5aaeb6053f3e94c9b9a09f33669435e7ef1beaed",
+			want:    "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		},
+		{
+			name:    "upper case address is normalized",
+			address: "0xFB6916095CA1DF60BB79CE92CE3EA74C37C5D359",
+			want:    "0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		},
+		{
+			name:    "mixed case address with wrong checksum is rejected",
+			address: "0xdAC17F958D2ee523a2206206994597C13D831eC7",
+			wantErr: ErrInvalidAddressChecksum,
+		},
+		{
+			name:    "address without prefix is rejected",
+			address: "dAC17F958D2ee523a2206206994597C13D831ec7",
+			wantErr: ErrInvalidAddress,
+		},
+		{
+			name:    "too short address is rejected",
+			address: "0xdAC17F958D2ee523a2206206994597C13D831e",
+			wantErr: ErrInvalidAddress,
+		},
+		{
+			name:    "non hex address is rejected",
+			address: "0xzzC17F958D2ee523a2206206994597C13D831ec7",
+			wantErr: ErrInvalidAddress,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeAddress(tt.address)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NormalizeAddress() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("NormalizeAddress() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
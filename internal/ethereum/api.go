@@ -8,18 +8,26 @@ import (
 	"math/rand/v2"
 	"net/http"
 	"net/url"
+	"strconv"
+	"time"
+
+	"tw/internal/hexutil"
 )
 
 const (
-	defaultJSONRpc = "2.0"
+	// JSONRPCVersion is the version of the JSON-RPC requests and responses.
+	JSONRPCVersion = "2.0"
 
 	methodGetCurrentBlock      = "eth_blockNumber"
 	methodGetBlockByNumber     = "eth_getBlockByNumber"
 	methodGetTransactionByHash = "eth_getTransactionByHash"
+	methodChainID              = "eth_chainId"
 )
 
+// EthApiWrapper executes requests to the ethereum JSONRPC api.
 type EthApiWrapper struct {
-	apiEndpoint *url.URL
+	apiEndpoint   *url.URL
+	authenticator RequestAuthenticator
 }
 
 func NewEthApiWrapper(endpoint *url.URL) *EthApiWrapper {
@@ -28,87 +36,50 @@ func NewEthApiWrapper(endpoint *url.URL) *EthApiWrapper {
 	}
 }
 
-// GetCurrentBlock returns current block number based on the http call to the api.
-func (e *EthApiWrapper) GetCurrentBlock(httpClient *http.Client) (string, error) {
-	ethReq := ethRequest{
-		ID:      generateRandomID(),
-		JSONRpc: defaultJSONRpc,
-		Method:  methodGetCurrentBlock,
-		Params:  []any{},
-	}
-
-	body, err := json.Marshal(ethReq)
-	if err != nil {
-		return "", fmt.Errorf("json marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, e.apiEndpoint.String(), bytes.NewBuffer(body))
-	if err != nil {
-		return "", fmt.Errorf("new http request: %w", err)
+// NewAuthenticatedEthApiWrapper creates a new instance of EthApiWrapper
+// which authenticates every request with the given authenticator.
+func NewAuthenticatedEthApiWrapper(endpoint *url.URL, authenticator RequestAuthenticator) *EthApiWrapper {
+	return &EthApiWrapper{
+		apiEndpoint:   endpoint,
+		authenticator: authenticator,
 	}
+}
 
-	res, err := httpClient.Do(req)
-	if err != nil {
-		return "", fmt.Errorf("http client do request: %w", err)
-	}
-	defer res.Body.Close()
+// String returns api endpoint with the secrets redacted, so
+// it can be safely logged.
+func (e *EthApiWrapper) String() string {
+	return RedactURL(e.apiEndpoint)
+}
 
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return "", fmt.Errorf("invalid response status code from api: %d", res.StatusCode)
+// GetCurrentBlock returns current block number based on the http call to the api.
+func (e *EthApiWrapper) GetCurrentBlock(httpClient *http.Client) (string, error) {
+	var ethRes quantityResponse
+	if err := e.call(httpClient, methodGetCurrentBlock, []any{}, &ethRes); err != nil {
+		return "", err
 	}
 
-	readBytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		return "", fmt.Errorf("read all bytes from response: %w", err)
-	}
+	return ethRes.Result, nil
+}
 
-	var ethRes ethBaseResponse
-	if err := json.Unmarshal(readBytes, &ethRes); err != nil {
-		return "", fmt.Errorf("json unmarshal bytes from response: %w", err)
+// GetChainID returns chain id of the network served by the api.
+func (e *EthApiWrapper) GetChainID(httpClient *http.Client) (string, error) {
+	var ethRes quantityResponse
+	if err := e.call(httpClient, methodChainID, []any{}, &ethRes); err != nil {
+		return "", err
 	}
 
 	return ethRes.Result, nil
 }
 
 func (e *EthApiWrapper) GetTransactionsForBlock(httpClient *http.Client, blockNum string) ([]Transaction, error) {
-	ethReq := ethRequest{
-		ID:      generateRandomID(),
-		JSONRpc: defaultJSONRpc,
-		Method:  methodGetBlockByNumber,
-		Params: []any{
-			fmt.Sprintf("0x%s", blockNum),
-			true,
-		},
-	}
-
-	body, err := json.Marshal(ethReq)
-	if err != nil {
-		return nil, fmt.Errorf("json marshal request: %w", err)
-	}
-
-	req, err := http.NewRequest(http.MethodPost, e.apiEndpoint.String(), bytes.NewBuffer(body))
-	if err != nil {
-		return nil, fmt.Errorf("new http request: %w", err)
-	}
-
-	res, err := httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("http client do request: %w", err)
-	}
-	defer res.Body.Close()
-
-	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return nil, fmt.Errorf("invalid response status code from api: %d", res.StatusCode)
-	}
-
-	readBytes, err := io.ReadAll(res.Body)
-	if err != nil {
-		return nil, fmt.Errorf("read all bytes from response: %w", err)
+	params := []any{
+		fmt.Sprintf("0x%s", blockNum),
+		true,
 	}
 
 	var ethRes getBlockByNumberResponseFixed
-	if err := json.Unmarshal(readBytes, &ethRes); err != nil {
-		return nil, fmt.Errorf("json unmarshal bytes from response: %w", err)
+	if err := e.call(httpClient, methodGetBlockByNumber, params, &ethRes); err != nil {
+		return nil, err
 	}
 
 	transactions := make([]Transaction, 0, len(ethRes.Result.Transactions))
@@ -122,6 +93,10 @@ func (e *EthApiWrapper) GetTransactionsForBlock(httpClient *http.Client, blockNu
 	//	transactions = append(transactions, *transaction)
 	//}
 	for _, transaction := range ethRes.Result.Transactions {
+		if transaction.BlockTimestamp == "" {
+			transaction.BlockTimestamp = ethRes.Result.Timestamp
+		}
+
 		transactions = append(transactions, transaction)
 	}
 
@@ -129,59 +104,151 @@ func (e *EthApiWrapper) GetTransactionsForBlock(httpClient *http.Client, blockNu
 }
 
 func (e *EthApiWrapper) getTransaction(httpClient *http.Client, transactionHash string) (*Transaction, error) {
-	ethReq := ethRequest{
-		ID:      generateRandomID(),
-		JSONRpc: defaultJSONRpc,
-		Method:  methodGetTransactionByHash,
-		Params: []any{
-			transactionHash,
-		},
+	params := []any{
+		transactionHash,
+	}
+
+	var ethRes getTransactionByHashResponse
+	if err := e.call(httpClient, methodGetTransactionByHash, params, &ethRes); err != nil {
+		return nil, err
+	}
+
+	return &ethRes.Result, nil
+}
+
+// call executes json rpc method with given params and unmarshals
+// the response into the result.
+func (e *EthApiWrapper) call(httpClient *http.Client, method string, params []any, result any) error {
+	start := time.Now()
+	code, err := e.do(httpClient, method, params, result)
+	rpcRequestDuration.Observe(time.Since(start).Seconds(), method)
+
+	if err != nil {
+		rpcErrors.Inc(method, code)
+	}
+
+	return err
+}
+
+// do is the call, it returns the error code for the metrics: JSON-RPC
+// error code, http status code, transport or decode.
+func (e *EthApiWrapper) do(httpClient *http.Client, method string, params []any, result any) (string, error) {
+	rawParams, err := json.Marshal(params)
+	if err != nil {
+		return "decode", fmt.Errorf("json marshal params: %w", err)
+	}
+
+	ethReq := RPCRequest{
+		ID:      json.RawMessage(strconv.FormatInt(generateRandomID(), 10)),
+		JSONRpc: JSONRPCVersion,
+		Method:  method,
+		Params:  rawParams,
 	}
 
 	body, err := json.Marshal(ethReq)
 	if err != nil {
-		return nil, fmt.Errorf("json marshal request: %w", err)
+		return "decode", fmt.Errorf("json marshal request: %w", err)
 	}
 
 	req, err := http.NewRequest(http.MethodPost, e.apiEndpoint.String(), bytes.NewBuffer(body))
 	if err != nil {
-		return nil, fmt.Errorf("new http request: %w", err)
+		return "transport", fmt.Errorf("new http request: %w", RedactURLError(err, e.apiEndpoint))
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if e.authenticator != nil {
+		if err := e.authenticator.Authenticate(req); err != nil {
+			return "transport", fmt.Errorf("authenticate request: %w", err)
+		}
 	}
 
 	res, err := httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("http client do request: %w", err)
+		return "transport", fmt.Errorf("http client do request: %w", RedactURLError(err, e.apiEndpoint))
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode < 200 || res.StatusCode > 299 {
-		return nil, fmt.Errorf("invalid response status code from api: %d", res.StatusCode)
+		return strconv.Itoa(res.StatusCode), fmt.Errorf("invalid response status code from api: %d", res.StatusCode)
 	}
 
 	readBytes, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("read all bytes from response: %w", err)
+		return "transport", fmt.Errorf("read all bytes from response: %w", err)
 	}
 
-	var ethRes getTransactionByHashResponse
-	if err := json.Unmarshal(readBytes, &ethRes); err != nil {
-		return nil, fmt.Errorf("json unmarshal bytes from response: %w", err)
+	// error response has no result, it would be unmarshalled as the empty one
+	var errRes struct {
+		Error *RPCError `json:"error"`
+	}
+	if err := json.Unmarshal(readBytes, &errRes); err == nil && errRes.Error != nil {
+		return strconv.Itoa(errRes.Error.Code), errRes.Error
 	}
 
-	return &ethRes.Result, nil
+	if err := json.Unmarshal(readBytes, result); err != nil {
+		return "decode", fmt.Errorf("json unmarshal bytes from response: %w", err)
+	}
+
+	if v, ok := result.(validator); ok {
+		if err := v.validate(); err != nil {
+			return "decode", fmt.Errorf("invalid result: %w", err)
+		}
+	}
+
+	return "", nil
 }
 
-type ethRequest struct {
-	ID      int64  `json:"id"`
-	JSONRpc string `json:"jsonrpc"`
-	Method  string `json:"method"`
-	Params  []any  `json:"params"`
+// validator is implemented by the results which are checked after they are
+// unmarshalled, so the malformed one is the error, not the zero value.
+type validator interface {
+	validate() error
+}
+
+// RPCRequest is the JSON-RPC 2.0 request, it's sent to the api and
+// received by the tw_* server. Request without id is the notification.
+type RPCRequest struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	JSONRpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// RPCResponse is the JSON-RPC 2.0 response, either the result or the error is set.
+// Server notifications (i.e. tw_subscription) have the method and params instead.
+type RPCResponse struct {
+	ID      json.RawMessage `json:"id,omitempty"`
+	JSONRpc string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// RPCError is the error of the JSON-RPC 2.0 response.
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("json rpc error %d: %s", e.Code, e.Message)
 }
 
 type ethBaseResponse struct {
 	JSONRpc string `json:"jsonrpc"`
 	Result  string `json:"result"`
 }
+
+// quantityResponse is the response of the methods returning hex quantity.
+type quantityResponse ethBaseResponse
+
+func (r *quantityResponse) validate() error {
+	_, err := hexutil.DecodeUint64(r.Result)
+
+	return err
+}
+
 type getBlockByNumberResponseFixed struct {
 	Jsonrpc string `json:"jsonrpc"`
 	Result  struct {
@@ -0,0 +1,78 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Backfill looks for the transactions to the address in the blocks from..to
+// (both inclusive) and stores the ones that are not stored yet. It doesn't need
+// the observer, so it can fill the history from before the address was subscribed.
+// Progress is called after every block, it can be nil. It returns the number of
+// stored transactions, also when it stops early because of the error or context.
+func Backfill(
+	ctx context.Context,
+	apiWrapper ApiWrapper,
+	httpClient *http.Client,
+	network Network,
+	storage TransactionsStorage,
+	address string,
+	from, to int64,
+	progress func(progress BackfillProgress),
+) (int, error) {
+	observed, err := ParseAddress(address)
+	if err != nil {
+		return 0, err
+	}
+
+	if from < 0 || to < from {
+		return 0, fmt.Errorf("invalid block range %d..%d", from, to)
+	}
+
+	stored := make(map[string]bool)
+	for _, transaction := range storage.GetTransactionsForAddress(observed.Hex()) {
+		stored[transaction.Hash] = true
+	}
+
+	count := 0
+	for blockNum := from; blockNum <= to; blockNum++ {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		transactions, err := apiWrapper.GetTransactionsForBlock(httpClient, fmt.Sprintf("%x", blockNum))
+		if err != nil {
+			return count, fmt.Errorf("get transactions for block %d: %w", blockNum, err)
+		}
+
+		var errs []error
+		for _, transaction := range transactions {
+			if to, ok := network.recipient(transaction); !ok || to != observed || stored[transaction.Hash] {
+				continue
+			}
+
+			if err := storage.SerializeTransaction(SerializableTransaction{
+				Address:     observed.Hex(),
+				Transaction: transaction,
+			}); err != nil {
+				errs = append(errs, fmt.Errorf("serialize transaction %s: %w", transaction.Hash, err))
+				continue
+			}
+
+			stored[transaction.Hash] = true
+			count++
+		}
+
+		if err := errors.Join(errs...); err != nil {
+			return count, err
+		}
+
+		if progress != nil {
+			progress(BackfillProgress{FromBlock: from, ToBlock: to, CurrentBlock: blockNum})
+		}
+	}
+
+	return count, nil
+}
@@ -0,0 +1,54 @@
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestBackfill(t *testing.T) {
+	apiWrapper := &mockApiWrapper{
+		getTransactionsForBlockFunc: func(httpClient *http.Client, blockNum string) ([]Transaction, error) {
+			n, _ := strconv.ParseInt(blockNum, 16, 64)
+
+			return []Transaction{
+				{Hash: "0xa" + blockNum, To: testAddress, BlockNumber: "0x" + blockNum},
+				{Hash: "0xb" + blockNum, To: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"},
+				// replayed on the other chain
+				{Hash: "0xc" + blockNum, To: testAddress, ChainId: fmt.Sprintf("0x%x", n+100)},
+			}, nil
+		},
+	}
+
+	storage := &mapTransactionStorage{transactions: make(map[string][]Transaction)}
+	// already stored transaction is not stored again
+	_ = storage.SerializeTransaction(SerializableTransaction{Address: testAddress, Transaction: Transaction{Hash: "0xa1"}})
+
+	var progress []int64
+	count, err := Backfill(context.Background(), apiWrapper, nil, Mainnet, storage, testAddress, 1, 3, func(p BackfillProgress) {
+		progress = append(progress, p.CurrentBlock)
+	})
+	if err != nil {
+		t.Fatalf("Backfill() error = %v", err)
+	}
+
+	if count != 2 {
+		t.Errorf("Backfill() = %v, want 2", count)
+	}
+
+	var hashes []string
+	for _, transaction := range storage.GetTransactionsForAddress(testAddress) {
+		hashes = append(hashes, transaction.Hash)
+	}
+
+	if want := []string{"0xa1", "0xa2", "0xa3"}; !reflect.DeepEqual(hashes, want) {
+		t.Errorf("stored = %v, want %v", hashes, want)
+	}
+
+	if want := []int64{1, 2, 3}; !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+}
@@ -1,11 +1,18 @@
 package ethereum
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"io"
-	"log"
-	"math/big"
+	"log/slog"
 	"net/http"
+	"sort"
 	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+
+	"tw/internal/hexutil"
 )
 
 // Parser must be implemented by any struct
@@ -28,6 +35,20 @@ type Observer interface {
 	ObserveAddress(address string) (<-chan Transaction, error)
 }
 
+// Unobserver can be implemented by the Observer which can stop observing
+// the address. Transaction channel of the address has to be closed.
+type Unobserver interface {
+	// UnobserveAddress stops observing the address.
+	UnobserveAddress(address string) error
+}
+
+// EventEmitter can be implemented by the Observer which reports
+// events not related to the observed transactions.
+type EventEmitter interface {
+	// OnEvent sets the function called with the emitted events.
+	OnEvent(emit func(event Event))
+}
+
 // TransactionsStorage should be implemented
 // by the struct that can store transactions.
 type TransactionsStorage interface {
@@ -38,6 +59,13 @@ type TransactionsStorage interface {
 	GetTransactionsForAddress(address string) []Transaction
 }
 
+// AddressLister can be implemented by the TransactionsStorage
+// which can list the addresses it has transactions for.
+type AddressLister interface {
+	// Addresses returns the addresses with stored transactions.
+	Addresses() []string
+}
+
 // ApiWrapper must be implemented by the struct
 // that can execute http requests to the ethereum
 // JSONRPC api.
@@ -46,6 +74,16 @@ type ApiWrapper interface {
 	GetCurrentBlock(httpClient *http.Client) (string, error)
 	// GetTransactionsForBlock returns transactions for given block number.
 	GetTransactionsForBlock(httpClient *http.Client, blockNum string) ([]Transaction, error)
+	// GetChainID returns chain id of the network served by the api.
+	GetChainID(httpClient *http.Client) (string, error)
+}
+
+// RequestAuthenticator must be implemented by the struct
+// that can authenticate requests done to the ethereum
+// JSONRPC api (i.e. by setting headers).
+type RequestAuthenticator interface {
+	// Authenticate adds credentials to the given request.
+	Authenticate(req *http.Request) error
 }
 
 // Transaction represents transaction from
@@ -54,6 +92,7 @@ type Transaction struct {
 	Type                 string `json:"type"`
 	BlockHash            string `json:"blockHash"`
 	BlockNumber          string `json:"blockNumber"`
+	BlockTimestamp       string `json:"blockTimestamp,omitempty"` // set from the block, if the api doesn't return it
 	From                 string `json:"from"`
 	Gas                  string `json:"gas"`
 	Hash                 string `json:"hash"`
@@ -73,6 +112,9 @@ type Transaction struct {
 		Address     string   `json:"address"`
 		StorageKeys []string `json:"storageKeys"`
 	} `json:"accessList"`
+
+	// span is the match span of the observer, parent of the parser spans
+	span trace.SpanContext
 }
 
 // SerializableTransaction represents transaction
@@ -88,16 +130,36 @@ type SerializableTransaction struct {
 type JSONRPCParser struct {
 	observer            Observer
 	transactionsStorage TransactionsStorage
-	logger              *log.Logger
+	logger              *slog.Logger
 	httpClient          *http.Client
 	apiWrapper          ApiWrapper
+	// network is the metrics label, empty if the observer doesn't tell it
+	network string
 
 	closeChan     chan struct{}
+	closeOnce     sync.Once
 	subscribersWG sync.WaitGroup
+	closed        bool
+
+	mu sync.Mutex
+	// subscriptions are closed to stop the subscriber goroutine of the address
+	subscriptions map[string]chan struct{}
+	handlers      map[string][]*handlerDispatcher
+	deadLetters   []DeadLetter
+
+	events        chan Event
+	eventsClosed  bool
+	droppedEvents uint64
+	// storageFailures is the number of storage writes in a row that failed
+	storageFailures int
 }
 
 var _ Parser = (*JSONRPCParser)(nil)
 var _ io.Closer = (*JSONRPCParser)(nil)
+var _ Lifecycle = (*JSONRPCParser)(nil)
+
+// EventsBufferSize is the size of the buffer of the Events channel.
+const EventsBufferSize = 1024
 
 // NewJSONRPCParser creates a new instance of JSONRPCParser.
 func NewJSONRPCParser(
@@ -105,88 +167,479 @@ func NewJSONRPCParser(
 	transactionsStorage TransactionsStorage,
 	apiWrapper ApiWrapper,
 	httpClient *http.Client,
-	logger *log.Logger,
+	logger *slog.Logger,
 ) *JSONRPCParser {
-	closeChan := make(chan struct{}, 1)
+	closeChan := make(chan struct{})
 
-	return &JSONRPCParser{
+	jp := &JSONRPCParser{
 		observer:            observer,
 		httpClient:          httpClient,
 		apiWrapper:          apiWrapper,
 		logger:              logger,
 		transactionsStorage: transactionsStorage,
 		closeChan:           closeChan,
+		events:              make(chan Event, EventsBufferSize),
+	}
+
+	if emitter, ok := observer.(EventEmitter); ok {
+		emitter.OnEvent(jp.emit)
+	}
+
+	if networked, ok := observer.(interface{ Network() Network }); ok {
+		jp.network = networked.Network().Name
+	}
+
+	return jp
+}
+
+// Start starts the observer, if it has to be started. Observer is also
+// started by the first subscription, so calling Start is optional.
+func (jp *JSONRPCParser) Start() error {
+	if lifecycle, ok := jp.observer.(Lifecycle); ok {
+		return lifecycle.Start()
+	}
+
+	return nil
+}
+
+// Shutdown stops the parser in order: observer stops polling and hands over
+// transactions it already found, subscribers store them, handlers deliver
+// what is queued, storage is flushed and api wrapper is closed. If it doesn't
+// finish before the context is done, the rest is given up and it returns
+// ErrShutdownTimeout. It is safe to call Shutdown more than once.
+func (jp *JSONRPCParser) Shutdown(ctx context.Context) error {
+	jp.mu.Lock()
+	jp.closed = true
+	jp.mu.Unlock()
+
+	var errs []error
+
+	if lifecycle, ok := jp.observer.(Lifecycle); ok {
+		if err := lifecycle.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("observer shutdown: %w", err))
+		}
+	} else {
+		// observer channels are never closed, subscribers have to be told to stop
+		jp.stopSubscribers()
+	}
+
+	if err := waitContext(ctx, &jp.subscribersWG); err != nil {
+		jp.stopSubscribers()
+
+		errs = append(errs, fmt.Errorf("%w: subscribers: %w", ErrShutdownTimeout, err))
+	}
+
+	// nothing is dispatched anymore, handlers can be stopped
+	jp.mu.Lock()
+	handlers := jp.handlers
+	jp.handlers = nil
+
+	if !jp.eventsClosed && jp.events != nil {
+		jp.eventsClosed = true
+		close(jp.events)
+	}
+	jp.mu.Unlock()
+
+	for address, dispatchers := range handlers {
+		for _, dispatcher := range dispatchers {
+			if err := dispatcher.shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("%w: handler for address %s: %w", ErrShutdownTimeout, address, err))
+			}
+		}
+	}
+
+	if flusher, ok := jp.transactionsStorage.(Flusher); ok {
+		if err := flusher.Flush(); err != nil {
+			errs = append(errs, fmt.Errorf("flush storage: %w", err))
+		}
 	}
+
+	if closer, ok := jp.apiWrapper.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close api wrapper: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
-// Close safely closes JSONRPCParser. It sends close signal to
-// serialize goroutine, which then safely is being done
-// with the serialize process.
+// Close shuts down the parser, waiting at most DefaultShutdownTimeout.
 func (jp *JSONRPCParser) Close() error {
-	jp.closeChan <- struct{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
 
-	// wait for the serialization to be done for all addresses
-	jp.subscribersWG.Wait()
+	return jp.Shutdown(ctx)
+}
 
-	return nil
+// stopSubscribers tells subscriber goroutines to stop without
+// waiting for the rest of the transactions.
+func (jp *JSONRPCParser) stopSubscribers() {
+	jp.closeOnce.Do(func() {
+		close(jp.closeChan)
+	})
 }
 
 // GetCurrentBlock returns an number of current block.
 func (jp *JSONRPCParser) GetCurrentBlock() int {
 	res, err := jp.apiWrapper.GetCurrentBlock(jp.httpClient)
 	if err != nil {
-		jp.logger.Printf("get current block error: %s", err.Error())
+		jp.logger.Warn("get current block failed", "network", jp.network, "err", err)
 		return 0
 	}
 
-	n := new(big.Int)
-	// passing 0, it will pick base based on the string
-	n.SetString(res, 0)
+	n, err := hexutil.DecodeInt64(res)
+	if err != nil {
+		jp.logger.Warn("invalid current block", "network", jp.network, "err", err)
+		return 0
+	}
 
-	return int(n.Int64())
+	return int(n)
 }
 
 func (jp *JSONRPCParser) Subscribe(address string) bool {
+	if err := jp.SubscribeAddress(address); err != nil {
+		jp.logger.Warn("subscribe address failed", "network", jp.network, "address", address, "err", err)
+		return false
+	}
+
+	return true
+}
+
+// SubscribeAddress adds address to observer. It returns ErrInvalidAddress or
+// ErrInvalidAddressChecksum if the address is not valid ethereum address.
+func (jp *JSONRPCParser) SubscribeAddress(address string) error {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	jp.mu.Lock()
+	defer jp.mu.Unlock()
+
+	if jp.closed {
+		return ErrClosed
+	}
+
+	// address is already observed, observing it again would store every transaction twice
+	if _, ok := jp.subscriptions[address]; ok {
+		return nil
+	}
+
 	transactionsChan, err := jp.observer.ObserveAddress(address)
 	if err != nil {
-		jp.logger.Printf("observer observe address: %s", err.Error())
-		return false
+		return fmt.Errorf("observer observe address: %w", err)
+	}
+
+	if jp.subscriptions == nil {
+		jp.subscriptions = make(map[string]chan struct{})
 	}
 
+	unsubscribed := make(chan struct{})
+	jp.subscriptions[address] = unsubscribed
+	subscriptions.Set(float64(len(jp.subscriptions)), jp.network)
+
 	jp.subscribersWG.Add(1)
 
-	go jp.onTransactionsSubscribe(address, transactionsChan)
+	go jp.onTransactionsSubscribe(address, transactionsChan, unsubscribed)
 
-	return true
+	return nil
+}
+
+// SubscribeFunc subscribes address and calls handler for every transaction found
+// for it. Delivery is at least once: transaction is stored first and then handler is
+// called until it returns nil, or it runs out of attempts, then the event is moved to
+// the dead letters. Multiple handlers can be subscribed to the same address.
+func (jp *JSONRPCParser) SubscribeFunc(address string, handler EventHandler, opts ...HandlerOption) error {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	dispatcher, err := newHandlerDispatcher(handler, jp.logger, jp.addDeadLetter, opts...)
+	if err != nil {
+		return err
+	}
+
+	jp.mu.Lock()
+	if jp.handlers == nil {
+		jp.handlers = make(map[string][]*handlerDispatcher)
+	}
+
+	// handler is registered before the address is observed, so it gets the first transactions
+	jp.handlers[address] = append(jp.handlers[address], dispatcher)
+	jp.mu.Unlock()
+
+	if err := jp.SubscribeAddress(address); err != nil {
+		jp.removeHandler(address, dispatcher)
+		dispatcher.close()
+
+		return err
+	}
+
+	return nil
+}
+
+// AddHandler calls handler for every transaction found for the address, like
+// SubscribeFunc, but it doesn't subscribe the address: it returns ErrNotSubscribed
+// if it isn't subscribed. Returned func removes the handler, it waits for the
+// handler calls in progress. Handler is removed with the address too, returned
+// channel is closed once the handler is removed either way.
+func (jp *JSONRPCParser) AddHandler(address string, handler EventHandler, opts ...HandlerOption) (func(), <-chan struct{}, error) {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dispatcher, err := newHandlerDispatcher(handler, jp.logger, jp.addDeadLetter, opts...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jp.mu.Lock()
+	defer jp.mu.Unlock()
+
+	if jp.closed {
+		dispatcher.close()
+		return nil, nil, ErrClosed
+	}
+
+	if _, ok := jp.subscriptions[address]; !ok {
+		dispatcher.close()
+		return nil, nil, fmt.Errorf("%w: %s", ErrNotSubscribed, address)
+	}
+
+	if jp.handlers == nil {
+		jp.handlers = make(map[string][]*handlerDispatcher)
+	}
+
+	jp.handlers[address] = append(jp.handlers[address], dispatcher)
+
+	remove := func() {
+		jp.removeHandler(address, dispatcher)
+		dispatcher.close()
+	}
+
+	// dispatcher is canceled when it's closed by remove, Unsubscribe or Shutdown
+	return remove, dispatcher.ctx.Done(), nil
+}
+
+// removeHandler removes the dispatcher of the address, if it's still there.
+func (jp *JSONRPCParser) removeHandler(address string, dispatcher *handlerDispatcher) {
+	jp.mu.Lock()
+	defer jp.mu.Unlock()
+
+	dispatchers := jp.handlers[address]
+	for i, d := range dispatchers {
+		if d == dispatcher {
+			dispatchers = append(dispatchers[:i:i], dispatchers[i+1:]...)
+			break
+		}
+	}
+
+	if len(dispatchers) == 0 {
+		delete(jp.handlers, address)
+	} else {
+		jp.handlers[address] = dispatchers
+	}
+}
+
+// Unsubscribe stops observing the address and stops its handlers, events
+// waiting for the handlers are given up. Stored transactions are kept.
+// It returns ErrNotSubscribed if the address is not subscribed.
+func (jp *JSONRPCParser) Unsubscribe(address string) error {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return err
+	}
+
+	jp.mu.Lock()
+	unsubscribed, ok := jp.subscriptions[address]
+	if !ok {
+		jp.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrNotSubscribed, address)
+	}
+
+	delete(jp.subscriptions, address)
+	subscriptions.Set(float64(len(jp.subscriptions)), jp.network)
+	dispatchers := jp.handlers[address]
+	delete(jp.handlers, address)
+	jp.mu.Unlock()
+
+	var errs []error
+	if unobserver, ok := jp.observer.(Unobserver); ok {
+		// subscriber goroutine stores what is left and exits once channel is closed
+		if err := unobserver.UnobserveAddress(address); err != nil {
+			errs = append(errs, fmt.Errorf("observer unobserve address: %w", err))
+		}
+	} else {
+		close(unsubscribed)
+	}
+
+	for _, dispatcher := range dispatchers {
+		dispatcher.close()
+	}
+
+	return errors.Join(errs...)
+}
+
+// Subscriptions returns subscribed addresses, sorted.
+func (jp *JSONRPCParser) Subscriptions() []string {
+	jp.mu.Lock()
+	defer jp.mu.Unlock()
+
+	addresses := make([]string, 0, len(jp.subscriptions))
+	for address := range jp.subscriptions {
+		addresses = append(addresses, address)
+	}
+
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+// Events returns the stream of the parser events: TransactionObserved,
+// TransactionConfirmed, Reorg, BackfillProgress, SourceError and Lagging.
+// Channel is buffered (EventsBufferSize) and parser never waits for the
+// reader, when the buffer is full the event is dropped and counted by
+// DroppedEvents. Observed transactions are stored and passed to the handlers
+// no matter if the event was dropped, so GetTransactions is always complete.
+// Channel is closed when parser is closed.
+func (jp *JSONRPCParser) Events() <-chan Event {
+	return jp.events
+}
+
+// DroppedEvents returns number of events dropped because Events reader didn't keep up.
+func (jp *JSONRPCParser) DroppedEvents() uint64 {
+	jp.mu.Lock()
+	defer jp.mu.Unlock()
+
+	return jp.droppedEvents
+}
+
+// emit publishes event on the stream and passes
+// it to the handlers of the event address.
+func (jp *JSONRPCParser) emit(event Event) {
+	jp.emitContext(context.Background(), event)
+}
+
+// emitContext is emit, handler spans are children of the span of the context.
+func (jp *JSONRPCParser) emitContext(ctx context.Context, event Event) {
+	jp.mu.Lock()
+	if !jp.eventsClosed {
+		select {
+		case jp.events <- event:
+		default:
+			jp.droppedEvents++
+			droppedEvents.Inc(jp.network)
+		}
+	}
+	jp.mu.Unlock()
+
+	if address := event.EventAddress(); address != "" {
+		jp.dispatch(ctx, address, event)
+	}
 }
 
+// QueueStats returns stats of the observer queues by address, it's
+// empty if the observer doesn't queue transactions.
+func (jp *JSONRPCParser) QueueStats() map[string]QueueStats {
+	if queued, ok := jp.observer.(interface {
+		QueueStats() map[string]QueueStats
+	}); ok {
+		return queued.QueueStats()
+	}
+
+	return map[string]QueueStats{}
+}
+
+// DeadLetters returns events that handlers failed to handle.
+func (jp *JSONRPCParser) DeadLetters() []DeadLetter {
+	jp.mu.Lock()
+	defer jp.mu.Unlock()
+
+	deadLetters := make([]DeadLetter, len(jp.deadLetters))
+	copy(deadLetters, jp.deadLetters)
+
+	return deadLetters
+}
+
+func (jp *JSONRPCParser) addDeadLetter(deadLetter DeadLetter) {
+	jp.logger.Error("event moved to dead letters", "network", jp.network, "address", deadLetter.Event.EventAddress(), "attempts", deadLetter.Attempts, "err", deadLetter.Err)
+	deadLetters.Inc(jp.network)
+
+	jp.mu.Lock()
+	defer jp.mu.Unlock()
+
+	jp.deadLetters = append(jp.deadLetters, deadLetter)
+}
+
+// dispatch passes event to all handlers of the address.
+func (jp *JSONRPCParser) dispatch(ctx context.Context, address string, event Event) {
+	jp.mu.Lock()
+	dispatchers := jp.handlers[address]
+	jp.mu.Unlock()
+
+	for _, dispatcher := range dispatchers {
+		dispatcher.dispatch(ctx, event)
+	}
+}
+
+// GetTransactions returns transactions for the address, casing of
+// the address doesn't matter. Invalid address has no transactions.
 func (jp *JSONRPCParser) GetTransactions(address string) []Transaction {
+	address, err := NormalizeAddress(address)
+	if err != nil {
+		return nil
+	}
+
 	return jp.transactionsStorage.GetTransactionsForAddress(address)
 }
 
-func (jp *JSONRPCParser) onTransactionsSubscribe(address string, transactionsChan <-chan Transaction) {
+func (jp *JSONRPCParser) onTransactionsSubscribe(address string, transactionsChan <-chan Transaction, unsubscribed <-chan struct{}) {
 	defer func() {
-		jp.logger.Printf("on transaction subscribe done for address: %s", address)
+		jp.logger.Debug("subscriber done", "network", jp.network, "address", address)
 		jp.subscribersWG.Done()
 	}()
 
 	for {
 		select {
 		case <-jp.closeChan:
-			jp.logger.Println("signal from close chan")
+			jp.logger.Debug("subscriber stopped", "network", jp.network, "address", address)
+			return
+		case <-unsubscribed:
+			jp.logger.Debug("address unsubscribed", "network", jp.network, "address", address)
 			return
 		case transaction, ok := <-transactionsChan:
 			if !ok {
-				jp.logger.Println("transaction chan closed")
+				jp.logger.Debug("transaction channel closed", "network", jp.network, "address", address)
 				return
 			}
 
-			if err := jp.transactionsStorage.SerializeTransaction(SerializableTransaction{
+			ctx, span := startSpan(trace.ContextWithSpanContext(context.Background(), transaction.span), spanPersist,
+				attrNetwork.String(jp.network), attrAddress.String(address), attrHash.String(transaction.Hash))
+
+			err := jp.transactionsStorage.SerializeTransaction(SerializableTransaction{
 				Address:     address,
 				Transaction: transaction,
-			}); err != nil {
-				jp.logger.Printf("serialize transaction error: %s", err.Error())
+			})
+			if err != nil {
+				jp.logger.Error("store transaction failed", "network", jp.network, "address", address, "hash", transaction.Hash, "err", err)
+			}
+
+			jp.mu.Lock()
+			if err != nil {
+				jp.storageFailures++
+			} else {
+				jp.storageFailures = 0
 			}
+			jp.mu.Unlock()
+
+			endSpan(span, err)
+
+			jp.emitContext(ctx, TransactionObserved{
+				Address:     address,
+				Transaction: transaction,
+			})
 		}
 	}
 }
@@ -3,7 +3,7 @@ package ethereum
 import (
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"reflect"
 	"sync"
@@ -13,15 +13,16 @@ import (
 	"tw/internal/clogger"
 )
 
+const testAddress = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+
 func TestJSONRPCParser_GetCurrentBlock(t *testing.T) {
 	type fields struct {
 		observer            Observer
 		transactionsStorage TransactionsStorage
 		apiWrapper          *mockApiWrapper
-		logger              *log.Logger
+		logger              *slog.Logger
 		httpClient          *http.Client
 		closeChan           chan struct{}
-		subscribersWG       sync.WaitGroup
 	}
 	tests := []struct {
 		name                 string
@@ -32,7 +33,7 @@ func TestJSONRPCParser_GetCurrentBlock(t *testing.T) {
 		{
 			name: "get current block request fails, returns 0",
 			fields: fields{
-				logger:              clogger.ConsoleLogger,
+				logger:              clogger.Logger,
 				apiWrapper:          &mockApiWrapper{},
 				transactionsStorage: &mockTransactionStorage{},
 			},
@@ -51,7 +52,7 @@ func TestJSONRPCParser_GetCurrentBlock(t *testing.T) {
 				}
 			},
 			fields: fields{
-				logger:              clogger.ConsoleLogger,
+				logger:              clogger.Logger,
 				apiWrapper:          &mockApiWrapper{},
 				transactionsStorage: &mockTransactionStorage{},
 			},
@@ -69,7 +70,6 @@ func TestJSONRPCParser_GetCurrentBlock(t *testing.T) {
 				httpClient:          tt.fields.httpClient,
 				closeChan:           tt.fields.closeChan,
 				apiWrapper:          tt.fields.apiWrapper,
-				subscribersWG:       tt.fields.subscribersWG,
 			}
 			if got := jp.GetCurrentBlock(); got != tt.want {
 				t.Errorf("GetCurrentBlock() = %v, want %v", got, tt.want)
@@ -82,10 +82,9 @@ func TestJSONRPCParser_Subscribe(t *testing.T) {
 	type fields struct {
 		observerFunc        func() Observer
 		transactionsStorage TransactionsStorage
-		logger              *log.Logger
+		logger              *slog.Logger
 		httpClient          *http.Client
 		closeChan           chan struct{}
-		subscribersWG       sync.WaitGroup
 	}
 	type args struct {
 		address string
@@ -99,7 +98,7 @@ func TestJSONRPCParser_Subscribe(t *testing.T) {
 		{
 			name: "observer returns error, should return false",
 			fields: fields{
-				logger:              clogger.ConsoleLogger,
+				logger:              clogger.Logger,
 				transactionsStorage: &mockTransactionStorage{},
 				observerFunc: func() Observer {
 					return &mockObserver{
@@ -110,14 +109,14 @@ func TestJSONRPCParser_Subscribe(t *testing.T) {
 				},
 			},
 			args: args{
-				address: "test",
+				address: testAddress,
 			},
 			want: false,
 		},
 		{
 			name: "observer returns channel, should return true",
 			fields: fields{
-				logger:              clogger.ConsoleLogger,
+				logger:              clogger.Logger,
 				transactionsStorage: &mockTransactionStorage{},
 				observerFunc: func() Observer {
 					return &mockObserver{func(address string) (<-chan Transaction, error) {
@@ -149,10 +148,24 @@ func TestJSONRPCParser_Subscribe(t *testing.T) {
 				},
 			},
 			args: args{
-				address: "test",
+				address: testAddress,
 			},
 			want: true,
 		},
+		{
+			name: "invalid address, should return false",
+			fields: fields{
+				logger:              clogger.Logger,
+				transactionsStorage: &mockTransactionStorage{},
+				observerFunc: func() Observer {
+					return &mockObserver{}
+				},
+			},
+			args: args{
+				address: "test",
+			},
+			want: false,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -162,7 +175,6 @@ func TestJSONRPCParser_Subscribe(t *testing.T) {
 				logger:              tt.fields.logger,
 				httpClient:          tt.fields.httpClient,
 				closeChan:           tt.fields.closeChan,
-				subscribersWG:       tt.fields.subscribersWG,
 			}
 			if got := jp.Subscribe(tt.args.address); got != tt.want {
 				t.Errorf("Subscribe() = %v, want %v", got, tt.want)
@@ -181,10 +193,9 @@ func TestJSONRPCParser_GetTransactions(t *testing.T) {
 	type fields struct {
 		observer            Observer
 		transactionsStorage TransactionsStorage
-		logger              *log.Logger
+		logger              *slog.Logger
 		httpClient          *http.Client
 		closeChan           chan struct{}
-		subscribersWG       sync.WaitGroup
 	}
 	type args struct {
 		address string
@@ -199,10 +210,10 @@ func TestJSONRPCParser_GetTransactions(t *testing.T) {
 			name: "there are no transactions in storage, returns empty array",
 			fields: fields{
 				transactionsStorage: &mockTransactionStorage{},
-				logger:              clogger.ConsoleLogger,
+				logger:              clogger.Logger,
 			},
 			args: args{
-				address: "test",
+				address: testAddress,
 			},
 			want: nil,
 		},
@@ -210,9 +221,11 @@ func TestJSONRPCParser_GetTransactions(t *testing.T) {
 			name: "transaction storage returns transactions, returns these transactions",
 			fields: fields{
 				transactionsStorage: &mockTransactionStorage{transactions: transactions},
-				logger:              clogger.ConsoleLogger,
+				logger:              clogger.Logger,
+			},
+			args: args{
+				address: testAddress,
 			},
-			args: args{},
 			want: transactions,
 		},
 	}
@@ -224,7 +237,6 @@ func TestJSONRPCParser_GetTransactions(t *testing.T) {
 				logger:              tt.fields.logger,
 				httpClient:          tt.fields.httpClient,
 				closeChan:           tt.fields.closeChan,
-				subscribersWG:       tt.fields.subscribersWG,
 			}
 			if got := jp.GetTransactions(tt.args.address); !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("GetTransactions() = %v, want %v", got, tt.want)
@@ -262,6 +274,7 @@ func (m *mockTransactionStorage) GetTransactionsForAddress(address string) []Tra
 type mockApiWrapper struct {
 	getCurrentBlockFunc         func(httpClient *http.Client) (string, error)
 	getTransactionsForBlockFunc func(httpClient *http.Client, blockNum string) ([]Transaction, error)
+	getChainIDFunc              func(httpClient *http.Client) (string, error)
 }
 
 func (m *mockApiWrapper) GetCurrentBlock(httpClient *http.Client) (string, error) {
@@ -279,3 +292,11 @@ func (m *mockApiWrapper) GetTransactionsForBlock(httpClient *http.Client, blockN
 
 	return nil, nil
 }
+
+func (m *mockApiWrapper) GetChainID(httpClient *http.Client) (string, error) {
+	if m.getChainIDFunc != nil {
+		return m.getChainIDFunc(httpClient)
+	}
+
+	return "", nil
+}
@@ -0,0 +1,125 @@
+package ethereum
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is emitted by the parser, type switch
+// can be used to find out what happened.
+type Event interface {
+	// EventAddress returns address that event relates to,
+	// it's empty for the events not related to any address.
+	EventAddress() string
+}
+
+// TransactionObserved is emitted when transaction
+// for the subscribed address was found.
+type TransactionObserved struct {
+	Address     string      `json:"address"`
+	Transaction Transaction `json:"transaction"`
+}
+
+// TransactionConfirmed is emitted when block of the observed
+// transaction got enough confirmations and it is still there.
+type TransactionConfirmed struct {
+	Address       string      `json:"address"`
+	Transaction   Transaction `json:"transaction"`
+	Confirmations int64       `json:"confirmations"`
+}
+
+// Reorg is emitted when block of the observed transaction was
+// replaced and the transaction is not there anymore.
+type Reorg struct {
+	Address     string      `json:"address"`
+	Transaction Transaction `json:"transaction"`
+	BlockNumber int64       `json:"blockNumber"`
+	// OldBlockHash is the hash of the block transaction was observed in.
+	OldBlockHash string `json:"oldBlockHash"`
+	// NewBlockHash is the hash of the block that replaced it, it's empty
+	// if the new block doesn't have any transactions.
+	NewBlockHash string `json:"newBlockHash"`
+}
+
+// BackfillProgress is emitted while observer catches up with the blocks
+// it missed, i.e. after the api was unavailable for some time.
+type BackfillProgress struct {
+	FromBlock    int64 `json:"fromBlock"`
+	ToBlock      int64 `json:"toBlock"`
+	CurrentBlock int64 `json:"currentBlock"`
+}
+
+// SourceError is emitted when api request fails.
+type SourceError struct {
+	Err error `json:"-"`
+	// Since is the time of the first failure in a row.
+	Since time.Time `json:"since"`
+	// Failures is the number of failures in a row.
+	Failures int `json:"failures"`
+}
+
+// Lagging is emitted when observer is behind the head of the chain.
+type Lagging struct {
+	HeadBlock      int64 `json:"headBlock"`
+	ProcessedBlock int64 `json:"processedBlock"`
+	Behind         int64 `json:"behind"`
+}
+
+func (e TransactionObserved) EventAddress() string {
+	return e.Address
+}
+
+func (e TransactionConfirmed) EventAddress() string {
+	return e.Address
+}
+
+func (e Reorg) EventAddress() string {
+	return e.Address
+}
+
+func (e BackfillProgress) EventAddress() string {
+	return ""
+}
+
+func (e SourceError) EventAddress() string {
+	return ""
+}
+
+// MarshalJSON adds the error message, errors are not marshaled on their own.
+func (e SourceError) MarshalJSON() ([]byte, error) {
+	type sourceError SourceError
+
+	var message string
+	if e.Err != nil {
+		message = e.Err.Error()
+	}
+
+	return json.Marshal(struct {
+		sourceError
+		Error string `json:"error"`
+	}{sourceError(e), message})
+}
+
+func (e Lagging) EventAddress() string {
+	return ""
+}
+
+// EventName returns the name of the event type, i.e. "TransactionObserved".
+func EventName(event Event) string {
+	switch event.(type) {
+	case TransactionObserved:
+		return "TransactionObserved"
+	case TransactionConfirmed:
+		return "TransactionConfirmed"
+	case Reorg:
+		return "Reorg"
+	case BackfillProgress:
+		return "BackfillProgress"
+	case SourceError:
+		return "SourceError"
+	case Lagging:
+		return "Lagging"
+	default:
+		return "Unknown"
+	}
+}
@@ -0,0 +1,154 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"tw/internal/clogger"
+)
+
+func TestJSONRPCParser_Events(t *testing.T) {
+	jp := NewJSONRPCParser(&mockObserver{}, &mockTransactionStorage{}, &mockApiWrapper{}, nil, clogger.Logger)
+
+	for i := range EventsBufferSize + 3 {
+		jp.emit(Lagging{Behind: int64(i)})
+	}
+
+	if got := jp.DroppedEvents(); got != 3 {
+		t.Errorf("DroppedEvents() = %v, want 3", got)
+	}
+
+	_ = jp.Close()
+
+	received := 0
+	for range jp.Events() {
+		received++
+	}
+
+	if received != EventsBufferSize {
+		t.Errorf("received events = %v, want %v", received, EventsBufferSize)
+	}
+
+	// emitting after close doesn't panic
+	jp.emit(Lagging{})
+}
+
+func TestJSONRpcBasedObserver_processBlocks(t *testing.T) {
+	tests := []struct {
+		name         string
+		lastBlockNum int64
+		currentBlock int64
+		failBlock    int64
+		want         int64
+		wantEvents   []string
+	}{
+		{
+			name:         "no new block, nothing happens",
+			lastBlockNum: 10,
+			currentBlock: 10,
+			want:         10,
+		},
+		{
+			name:         "one new block, no events",
+			lastBlockNum: 10,
+			currentBlock: 11,
+			want:         11,
+		},
+		{
+			name:         "observer catches up, emits backfill progress",
+			lastBlockNum: 10,
+			currentBlock: 12,
+			want:         12,
+			wantEvents:   []string{"BackfillProgress", "BackfillProgress"},
+		},
+		{
+			name:         "observer far behind, emits lagging",
+			lastBlockNum: 10,
+			currentBlock: 10 + laggingThresholdBlocks + 1,
+			failBlock:    11,
+			want:         10,
+			wantEvents:   []string{"Lagging", "SourceError"},
+		},
+		{
+			name:         "block fetch fails, emits source error and stops before the block",
+			lastBlockNum: 10,
+			currentBlock: 13,
+			failBlock:    12,
+			want:         11,
+			wantEvents:   []string{"BackfillProgress", "SourceError"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var events []string
+
+			observer := &JSONRpcBasedObserver{
+				logger: clogger.Logger,
+				apiWrapper: &mockApiWrapper{
+					getTransactionsForBlockFunc: func(httpClient *http.Client, blockNum string) ([]Transaction, error) {
+						if blockNum == fmt.Sprintf("%x", tt.failBlock) {
+							return nil, errors.New("error")
+						}
+
+						return nil, nil
+					},
+				},
+				emit: func(event Event) {
+					events = append(events, strings.TrimPrefix(reflect.TypeOf(event).String(), "ethereum."))
+				},
+			}
+
+			if got := observer.processBlocks(context.Background(), tt.lastBlockNum, tt.currentBlock); got != tt.want {
+				t.Errorf("processBlocks() = %v, want %v", got, tt.want)
+			}
+
+			if !reflect.DeepEqual(events, tt.wantEvents) {
+				t.Errorf("events = %v, want %v", events, tt.wantEvents)
+			}
+		})
+	}
+}
+
+func Test_confirmationEvent(t *testing.T) {
+	observed := Transaction{Hash: "0xaa", BlockHash: "0x01"}
+	pending := pendingTransaction{address: testAddress, transaction: observed, blockNumber: 5}
+
+	tests := []struct {
+		name         string
+		transactions []Transaction
+		want         Event
+	}{
+		{
+			name:         "transaction still in the block, confirmed",
+			transactions: []Transaction{{Hash: "0xbb", BlockHash: "0x01"}, observed},
+			want: TransactionConfirmed{
+				Address:       testAddress,
+				Transaction:   observed,
+				Confirmations: 12,
+			},
+		},
+		{
+			name:         "block replaced, reorg",
+			transactions: []Transaction{{Hash: "0xbb", BlockHash: "0x02"}},
+			want: Reorg{
+				Address:      testAddress,
+				Transaction:  observed,
+				BlockNumber:  5,
+				OldBlockHash: "0x01",
+				NewBlockHash: "0x02",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := confirmationEvent(pending, tt.transactions, 12); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("confirmationEvent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+// FailoverApiWrapper sends requests to the first api wrapper that works. It
+// sticks to the wrapper until it fails, then it tries the next ones in order.
+type FailoverApiWrapper struct {
+	apiWrappers []ApiWrapper
+	logger      *slog.Logger
+
+	current int
+	mu      sync.Mutex
+}
+
+var _ ApiWrapper = (*FailoverApiWrapper)(nil)
+
+// NewFailoverApiWrapper creates a new instance of FailoverApiWrapper, at least one api wrapper is required.
+func NewFailoverApiWrapper(logger *slog.Logger, apiWrappers ...ApiWrapper) (*FailoverApiWrapper, error) {
+	if len(apiWrappers) == 0 {
+		return nil, errors.New("failover requires at least one api wrapper")
+	}
+
+	return &FailoverApiWrapper{
+		apiWrappers: apiWrappers,
+		logger:      logger,
+	}, nil
+}
+
+func (f *FailoverApiWrapper) GetCurrentBlock(httpClient *http.Client) (string, error) {
+	var block string
+	err := f.try(func(apiWrapper ApiWrapper) (err error) {
+		block, err = apiWrapper.GetCurrentBlock(httpClient)
+		return err
+	})
+
+	return block, err
+}
+
+func (f *FailoverApiWrapper) GetTransactionsForBlock(httpClient *http.Client, blockNum string) ([]Transaction, error) {
+	var transactions []Transaction
+	err := f.try(func(apiWrapper ApiWrapper) (err error) {
+		transactions, err = apiWrapper.GetTransactionsForBlock(httpClient, blockNum)
+		return err
+	})
+
+	return transactions, err
+}
+
+func (f *FailoverApiWrapper) GetChainID(httpClient *http.Client) (string, error) {
+	var chainID string
+	err := f.try(func(apiWrapper ApiWrapper) (err error) {
+		chainID, err = apiWrapper.GetChainID(httpClient)
+		return err
+	})
+
+	return chainID, err
+}
+
+// try calls request with the current api wrapper and with the
+// next ones if it fails. It returns errors of all of them.
+func (f *FailoverApiWrapper) try(request func(apiWrapper ApiWrapper) error) error {
+	f.mu.Lock()
+	start := f.current
+	f.mu.Unlock()
+
+	var errs []error
+	for i := range f.apiWrappers {
+		n := (start + i) % len(f.apiWrappers)
+
+		err := request(f.apiWrappers[n])
+		if err == nil {
+			if n != start {
+				f.logger.Warn("api failover", "endpoint", fmt.Sprint(f.apiWrappers[n]), "previous_endpoint", fmt.Sprint(f.apiWrappers[start]))
+
+				f.mu.Lock()
+				f.current = n
+				f.mu.Unlock()
+			}
+
+			return nil
+		}
+
+		errs = append(errs, fmt.Errorf("%v: %w", f.apiWrappers[n], err))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,95 @@
+package ethereum
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"tw/internal/clogger"
+	"tw/internal/ethtest"
+)
+
+func TestFailoverApiWrapper_GetCurrentBlock(t *testing.T) {
+	failing := &mockApiWrapper{
+		getCurrentBlockFunc: func(httpClient *http.Client) (string, error) {
+			return "", errors.New("unavailable")
+		},
+	}
+
+	tests := []struct {
+		name        string
+		apiWrappers []ApiWrapper
+		want        string
+		wantErr     bool
+		wantCurrent int
+	}{
+		{
+			name: "first works, it is used",
+			apiWrappers: []ApiWrapper{
+				&mockApiWrapper{getCurrentBlockFunc: func(httpClient *http.Client) (string, error) { return "0x1", nil }},
+				&mockApiWrapper{getCurrentBlockFunc: func(httpClient *http.Client) (string, error) { return "0x2", nil }},
+			},
+			want:        "0x1",
+			wantCurrent: 0,
+		},
+		{
+			name: "first fails, switches to the second",
+			apiWrappers: []ApiWrapper{
+				failing,
+				&mockApiWrapper{getCurrentBlockFunc: func(httpClient *http.Client) (string, error) { return "0x2", nil }},
+			},
+			want:        "0x2",
+			wantCurrent: 1,
+		},
+		{
+			name:        "all fail, returns error",
+			apiWrappers: []ApiWrapper{failing, failing},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, _ := NewFailoverApiWrapper(clogger.Logger, tt.apiWrappers...)
+
+			got, err := f.GetCurrentBlock(nil)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCurrentBlock() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("GetCurrentBlock() = %v, want %v", got, tt.want)
+			}
+
+			if f.current != tt.wantCurrent {
+				t.Errorf("current = %v, want %v", f.current, tt.wantCurrent)
+			}
+		})
+	}
+}
+
+func TestFailoverApiWrapper_Nodes(t *testing.T) {
+	primary := ethtest.NewNode(Mainnet.ChainID)
+	defer primary.Close()
+
+	secondary := ethtest.NewNode(Mainnet.ChainID)
+	defer secondary.Close()
+
+	primary.Mine(1)
+	secondary.Mine(2)
+
+	// primary is rate limited after the first request
+	primary.SetRateLimit(1, time.Minute)
+
+	f, _ := NewFailoverApiWrapper(clogger.Logger, NewEthApiWrapper(primary.URL()), NewEthApiWrapper(secondary.URL()))
+
+	for _, want := range []string{"0x1", "0x2"} {
+		if got, err := f.GetCurrentBlock(http.DefaultClient); err != nil || got != want {
+			t.Errorf("GetCurrentBlock() = %v, %v, want %v", got, err, want)
+		}
+	}
+
+	if got := primary.Requests("eth_blockNumber"); got != 2 {
+		t.Errorf("primary requests = %v, want 2", got)
+	}
+}
@@ -0,0 +1,256 @@
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	defaultHandlerConcurrency  = 1
+	defaultHandlerMaxAttempts  = 5
+	defaultHandlerRetryBackoff = time.Second
+	defaultHandlerMaxBackoff   = time.Minute
+	handlerQueueSize           = 64
+)
+
+// EventHandler handles events of the subscribed address. Returning error
+// means that the event was not handled and it is going to be retried.
+type EventHandler func(ctx context.Context, event Event) error
+
+// HandlerOption configures how events are delivered to the handler.
+type HandlerOption func(options *handlerOptions)
+
+type handlerOptions struct {
+	concurrency  int
+	maxAttempts  int
+	retryBackoff time.Duration
+	maxBackoff   time.Duration
+}
+
+// WithConcurrency sets how many events can be handled at the same time.
+// Events are handled in order only if concurrency is 1 (default).
+func WithConcurrency(concurrency int) HandlerOption {
+	return func(options *handlerOptions) {
+		options.concurrency = concurrency
+	}
+}
+
+// WithMaxAttempts sets how many times handler is called for the same event
+// before the event is moved to the dead letters. Default is 5.
+func WithMaxAttempts(maxAttempts int) HandlerOption {
+	return func(options *handlerOptions) {
+		options.maxAttempts = maxAttempts
+	}
+}
+
+// WithRetryBackoff sets the time to wait before the first retry, it is doubled
+// with each attempt up to the max backoff. Default is 1s and 1m.
+func WithRetryBackoff(backoff, maxBackoff time.Duration) HandlerOption {
+	return func(options *handlerOptions) {
+		options.retryBackoff = backoff
+		options.maxBackoff = maxBackoff
+	}
+}
+
+// DeadLetter is the event that failed to be handled within max attempts.
+type DeadLetter struct {
+	Event    Event
+	Err      error
+	Attempts int
+	FailedAt time.Time
+}
+
+// handlerDispatcher delivers events to the handler at least once,
+// using given amount of workers.
+type handlerDispatcher struct {
+	handler EventHandler
+	options handlerOptions
+	logger  *slog.Logger
+
+	queue  chan queuedEvent
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	// closed guards the queue from being sent to after it was closed,
+	// done wakes up dispatch waiting for the full queue before that.
+	closed    bool
+	closedMu  sync.RWMutex
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// onDeadLetter is called when event failed all the attempts.
+	onDeadLetter func(deadLetter DeadLetter)
+}
+
+func newHandlerDispatcher(handler EventHandler, logger *slog.Logger, onDeadLetter func(DeadLetter), opts ...HandlerOption) (*handlerDispatcher, error) {
+	options := handlerOptions{
+		concurrency:  defaultHandlerConcurrency,
+		maxAttempts:  defaultHandlerMaxAttempts,
+		retryBackoff: defaultHandlerRetryBackoff,
+		maxBackoff:   defaultHandlerMaxBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.concurrency < 1 {
+		return nil, fmt.Errorf("handler concurrency must be at least 1, got %d", options.concurrency)
+	}
+
+	if options.maxAttempts < 1 {
+		return nil, fmt.Errorf("handler max attempts must be at least 1, got %d", options.maxAttempts)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d := &handlerDispatcher{
+		handler:      handler,
+		options:      options,
+		logger:       logger,
+		queue:        make(chan queuedEvent, handlerQueueSize),
+		done:         make(chan struct{}),
+		ctx:          ctx,
+		cancel:       cancel,
+		onDeadLetter: onDeadLetter,
+	}
+
+	for range options.concurrency {
+		d.wg.Add(1)
+
+		go d.work()
+	}
+
+	return d, nil
+}
+
+// queuedEvent is the event with the span it was dispatched in.
+type queuedEvent struct {
+	event Event
+	span  trace.SpanContext
+}
+
+// dispatch queues the event, it blocks if handler is not keeping up,
+// because events can't be dropped, until the dispatcher is closed. Span
+// of the context is the parent of the notify spans.
+func (d *handlerDispatcher) dispatch(ctx context.Context, event Event) {
+	d.closedMu.RLock()
+	defer d.closedMu.RUnlock()
+
+	if d.closed {
+		return
+	}
+
+	select {
+	case d.queue <- queuedEvent{event: event, span: trace.SpanContextFromContext(ctx)}:
+	case <-d.done:
+	case <-d.ctx.Done():
+	}
+}
+
+// close stops the workers. Events that are being retried are given up.
+func (d *handlerDispatcher) close() {
+	d.cancel()
+	d.closeQueue()
+
+	d.wg.Wait()
+}
+
+// shutdown lets workers deliver what is left in the queue. When the context
+// is done before that, retries are given up like with close, but the handler
+// calls in progress aren't waited for, the handler may ignore its context.
+func (d *handlerDispatcher) shutdown(ctx context.Context) error {
+	d.closeQueue()
+
+	err := waitContext(ctx, &d.wg)
+
+	d.cancel()
+
+	return err
+}
+
+func (d *handlerDispatcher) closeQueue() {
+	// dispatch waiting for the full queue holds the read lock
+	d.closeOnce.Do(func() { close(d.done) })
+
+	d.closedMu.Lock()
+	defer d.closedMu.Unlock()
+
+	if !d.closed {
+		d.closed = true
+		close(d.queue)
+	}
+}
+
+func (d *handlerDispatcher) work() {
+	defer d.wg.Done()
+
+	for queued := range d.queue {
+		// dispatcher is closed, the rest of the queue is given up
+		if d.ctx.Err() != nil {
+			continue
+		}
+
+		d.deliver(queued.event, queued.span)
+	}
+}
+
+// deliver calls handler until it succeeds or runs out of attempts.
+func (d *handlerDispatcher) deliver(event Event, parent trace.SpanContext) {
+	backoff := d.options.retryBackoff
+
+	var err error
+	for attempt := 1; attempt <= d.options.maxAttempts; attempt++ {
+		if err = d.call(event, parent, attempt); err == nil {
+			return
+		}
+
+		d.logger.Warn("handler failed", "address", event.EventAddress(), "attempt", attempt, "max_attempts", d.options.maxAttempts, "err", err)
+
+		if attempt == d.options.maxAttempts {
+			break
+		}
+
+		timer := time.NewTimer(backoff)
+		select {
+		case <-d.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		backoff *= 2
+		if backoff > d.options.maxBackoff {
+			backoff = d.options.maxBackoff
+		}
+	}
+
+	d.onDeadLetter(DeadLetter{
+		Event:    event,
+		Err:      err,
+		Attempts: d.options.maxAttempts,
+		FailedAt: time.Now(),
+	})
+}
+
+// call calls handler in the notify span, panic in the handler is treated as error.
+func (d *handlerDispatcher) call(event Event, parent trace.SpanContext, attempt int) (err error) {
+	ctx, span := startSpan(trace.ContextWithSpanContext(d.ctx, parent), spanNotify,
+		attrAddress.String(event.EventAddress()), attrAttempt.Int(attempt))
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panic: %v", r)
+		}
+
+		endSpan(span, err)
+	}()
+
+	return d.handler(ctx, event)
+}
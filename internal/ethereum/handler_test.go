@@ -0,0 +1,281 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tw/internal/clogger"
+)
+
+func TestJSONRPCParser_SubscribeFunc(t *testing.T) {
+	tests := []struct {
+		name            string
+		failures        int
+		maxAttempts     int
+		wantCalls       int
+		wantDeadLetters int
+	}{
+		{
+			name:        "handler succeeds, called once",
+			maxAttempts: 3,
+			wantCalls:   1,
+		},
+		{
+			name:        "handler fails twice, retried until success",
+			failures:    2,
+			maxAttempts: 3,
+			wantCalls:   3,
+		},
+		{
+			name:            "handler keeps failing, event moved to dead letters",
+			failures:        10,
+			maxAttempts:     3,
+			wantCalls:       3,
+			wantDeadLetters: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transactionsChan := make(chan Transaction)
+
+			jp := NewJSONRPCParser(
+				&mockObserver{func(address string) (<-chan Transaction, error) {
+					return transactionsChan, nil
+				}},
+				&mockTransactionStorage{},
+				&mockApiWrapper{},
+				nil,
+				clogger.Logger,
+			)
+
+			var calls atomic.Int32
+			handled := make(chan struct{})
+			var once sync.Once
+
+			err := jp.SubscribeFunc(testAddress, func(ctx context.Context, event Event) error {
+				n := calls.Add(1)
+				if int(n) >= tt.maxAttempts || int(n) > tt.failures {
+					defer once.Do(func() { close(handled) })
+				}
+
+				if int(n) <= tt.failures {
+					return errors.New("handler error")
+				}
+
+				return nil
+			}, WithMaxAttempts(tt.maxAttempts), WithRetryBackoff(time.Millisecond, time.Millisecond))
+			if err != nil {
+				t.Fatalf("SubscribeFunc() error = %v", err)
+			}
+
+			transactionsChan <- Transaction{Hash: "0x1", To: testAddress}
+
+			select {
+			case <-handled:
+			case <-time.After(5 * time.Second):
+				t.Fatal("handler was not called")
+			}
+
+			close(transactionsChan)
+			_ = jp.Close()
+
+			if got := int(calls.Load()); got != tt.wantCalls {
+				t.Errorf("handler calls = %v, want %v", got, tt.wantCalls)
+			}
+
+			if got := len(jp.DeadLetters()); got != tt.wantDeadLetters {
+				t.Errorf("DeadLetters() = %v, want %v", got, tt.wantDeadLetters)
+			}
+		})
+	}
+}
+
+func TestJSONRPCParser_SubscribeFunc_InvalidOptions(t *testing.T) {
+	jp := NewJSONRPCParser(&mockObserver{}, &mockTransactionStorage{}, &mockApiWrapper{}, nil, clogger.Logger)
+
+	err := jp.SubscribeFunc(testAddress, func(ctx context.Context, event Event) error {
+		return nil
+	}, WithConcurrency(0))
+	if err == nil {
+		t.Errorf("SubscribeFunc() error = nil, want error")
+	}
+}
+
+func TestJSONRPCParser_AddHandler(t *testing.T) {
+	transactionsChan := make(chan Transaction)
+
+	jp := NewJSONRPCParser(
+		&mockObserver{func(address string) (<-chan Transaction, error) {
+			return transactionsChan, nil
+		}},
+		&mockTransactionStorage{},
+		&mockApiWrapper{},
+		nil,
+		clogger.Logger,
+	)
+	defer jp.Close()
+
+	handled := make(chan Event, 2)
+	handler := func(ctx context.Context, event Event) error {
+		handled <- event
+		return nil
+	}
+
+	// address isn't subscribed by adding the handler
+	if _, _, err := jp.AddHandler(testAddress, handler); !errors.Is(err, ErrNotSubscribed) {
+		t.Fatalf("AddHandler() error = %v, want ErrNotSubscribed", err)
+	}
+
+	if got := jp.Subscriptions(); len(got) != 0 {
+		t.Fatalf("Subscriptions() = %v, want none", got)
+	}
+
+	if err := jp.SubscribeAddress(testAddress); err != nil {
+		t.Fatal(err)
+	}
+
+	remove, removed, err := jp.AddHandler(testAddress, handler)
+	if err != nil {
+		t.Fatalf("AddHandler() error = %v", err)
+	}
+
+	// the second one is removed by unsubscribing the address
+	_, unsubscribed, err := jp.AddHandler(testAddress, handler)
+	if err != nil {
+		t.Fatalf("AddHandler() error = %v", err)
+	}
+
+	transactionsChan <- Transaction{Hash: "0x1", To: testAddress}
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-handled:
+		case <-time.After(5 * time.Second):
+			t.Fatal("handler was not called")
+		}
+	}
+
+	remove()
+
+	select {
+	case <-removed:
+	default:
+		t.Error("removed handler is not done")
+	}
+
+	jp.mu.Lock()
+	dispatchers := len(jp.handlers[testAddress])
+	jp.mu.Unlock()
+
+	if dispatchers != 1 {
+		t.Errorf("handlers = %v after remove, want 1", dispatchers)
+	}
+
+	// address stays subscribed
+	if got := jp.Subscriptions(); len(got) != 1 {
+		t.Errorf("Subscriptions() = %v after remove, want the address", got)
+	}
+
+	if err := jp.Unsubscribe(testAddress); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-unsubscribed:
+	case <-time.After(5 * time.Second):
+		t.Error("handler of the unsubscribed address is not done")
+	}
+}
+
+func TestHandlerDispatcher_Concurrency(t *testing.T) {
+	const concurrency = 4
+
+	var running, maxRunning atomic.Int32
+	var wg sync.WaitGroup
+	wg.Add(concurrency * 2)
+
+	dispatcher, err := newHandlerDispatcher(func(ctx context.Context, event Event) error {
+		defer wg.Done()
+
+		n := running.Add(1)
+		defer running.Add(-1)
+
+		for {
+			current := maxRunning.Load()
+			if n <= current || maxRunning.CompareAndSwap(current, n) {
+				break
+			}
+		}
+
+		time.Sleep(20 * time.Millisecond)
+
+		return nil
+	}, clogger.Logger, func(DeadLetter) {}, WithConcurrency(concurrency))
+	if err != nil {
+		t.Fatalf("newHandlerDispatcher() error = %v", err)
+	}
+
+	for range concurrency * 2 {
+		dispatcher.dispatch(context.Background(), TransactionObserved{Address: testAddress})
+	}
+
+	wg.Wait()
+	dispatcher.close()
+
+	if got := maxRunning.Load(); got != concurrency {
+		t.Errorf("max running handlers = %v, want %v", got, concurrency)
+	}
+}
+
+func TestHandlerDispatcher_ShutdownTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	// handler ignores its context
+	dispatcher, err := newHandlerDispatcher(func(ctx context.Context, event Event) error {
+		<-release
+		return nil
+	}, clogger.Logger, func(DeadLetter) {})
+	if err != nil {
+		t.Fatalf("newHandlerDispatcher() error = %v", err)
+	}
+
+	// one event is handled, the queue is full and the last one waits for it
+	dispatched := make(chan struct{})
+	go func() {
+		defer close(dispatched)
+
+		for range handlerQueueSize + 2 {
+			dispatcher.dispatch(context.Background(), TransactionObserved{Address: testAddress})
+		}
+	}()
+
+	for len(dispatcher.queue) < handlerQueueSize {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	result := make(chan error, 1)
+	go func() { result <- dispatcher.shutdown(ctx) }()
+
+	select {
+	case err := <-result:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("shutdown() error = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown() didn't return once its context was done")
+	}
+
+	select {
+	case <-dispatched:
+	case <-time.After(5 * time.Second):
+		t.Error("dispatch is still waiting for the closed dispatcher")
+	}
+}
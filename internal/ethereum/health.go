@@ -0,0 +1,113 @@
+package ethereum
+
+import (
+	"fmt"
+	"time"
+)
+
+// DefaultHealthConfig are the thresholds used by the health endpoints.
+var DefaultHealthConfig = HealthConfig{
+	MaxLagBlocks: laggingThresholdBlocks,
+	MaxLagTime:   2 * time.Minute,
+	MaxFailures:  3,
+}
+
+// HealthConfig are the thresholds of Health, zero value disables the check.
+type HealthConfig struct {
+	// MaxLagBlocks is how many blocks the last processed block can be behind the head.
+	MaxLagBlocks int64
+	// MaxLagTime is how long the observer can stay behind the head, and also
+	// how long it can go without polling.
+	MaxLagTime time.Duration
+	// MaxFailures is the number of polls in a row that all the endpoints failed.
+	MaxFailures int
+}
+
+// ObserverState is what the observer knows about its progress.
+type ObserverState struct {
+	// HeadBlock is the newest block of the node, zero until the first poll succeeds.
+	HeadBlock int64 `json:"headBlock"`
+	// ProcessedBlock is the last processed block.
+	ProcessedBlock int64 `json:"processedBlock"`
+	// LastPoll is when the observer asked for the head last time.
+	LastPoll time.Time `json:"lastPoll"`
+	// Progressed is when the processed block moved last time, or when
+	// the observer was the head, or when it polled for the first time.
+	Progressed time.Time `json:"progressed"`
+	// Failures is the number of polls in a row the current block couldn't
+	// be fetched, with failover it means all the endpoints failed.
+	Failures int `json:"failures"`
+}
+
+// Health is the state of the parser checked against the HealthConfig. Ready is
+// false on any problem: lag, failing endpoints, storage writes or before the
+// first poll. Live is false only when the parser is stuck and restart can help:
+// it doesn't poll or processed block doesn't move. Long backfill is not ready,
+// but it's live, failing storage too: restart doesn't fix the full disk, and
+// the transactions are still observed.
+type Health struct {
+	Live     bool          `json:"live"`
+	Ready    bool          `json:"ready"`
+	Problems []string      `json:"problems,omitempty"`
+	Observer ObserverState `json:"observer"`
+	// StorageFailures is the number of storage writes in a row that failed.
+	StorageFailures int `json:"storageFailures"`
+}
+
+// Health checks the observer state and the storage writes, observer which
+// doesn't report its state (see JSONRpcBasedObserver.State) is always healthy.
+func (jp *JSONRPCParser) Health(config HealthConfig) Health {
+	jp.mu.Lock()
+	storageFailures := jp.storageFailures
+	jp.mu.Unlock()
+
+	health := Health{Live: true, Ready: true, StorageFailures: storageFailures}
+
+	// restart can help the stuck parser
+	stuck := func(format string, args ...any) {
+		health.Live = false
+		health.Ready = false
+		health.Problems = append(health.Problems, fmt.Sprintf(format, args...))
+	}
+
+	notReady := func(format string, args ...any) {
+		health.Ready = false
+		health.Problems = append(health.Problems, fmt.Sprintf(format, args...))
+	}
+
+	if storageFailures > 0 {
+		notReady("last %d storage writes failed", storageFailures)
+	}
+
+	stater, ok := jp.observer.(interface{ State() ObserverState })
+	if !ok {
+		return health
+	}
+
+	state := stater.State()
+	health.Observer = state
+
+	now := time.Now()
+
+	switch {
+	case state.LastPoll.IsZero():
+		notReady("observer didn't poll yet")
+	case config.MaxLagTime > 0 && now.Sub(state.LastPoll) > config.MaxLagTime:
+		stuck("last poll was %s ago", now.Sub(state.LastPoll).Round(time.Second))
+	}
+
+	if config.MaxFailures > 0 && state.Failures >= config.MaxFailures {
+		notReady("current block failed %d times in a row on all endpoints", state.Failures)
+	}
+
+	lag := state.HeadBlock - state.ProcessedBlock
+	if config.MaxLagBlocks > 0 && lag > config.MaxLagBlocks {
+		notReady("%d blocks behind the head, at most %d allowed", lag, config.MaxLagBlocks)
+	}
+
+	if config.MaxLagTime > 0 && lag > 0 && !state.Progressed.IsZero() && now.Sub(state.Progressed) > config.MaxLagTime {
+		stuck("%d blocks behind the head and no block processed for %s", lag, now.Sub(state.Progressed).Round(time.Second))
+	}
+
+	return health
+}
@@ -0,0 +1,80 @@
+package ethereum
+
+import (
+	"testing"
+	"time"
+
+	"tw/internal/clogger"
+)
+
+type stateObserver struct {
+	mockObserver
+	state ObserverState
+}
+
+func (s *stateObserver) State() ObserverState {
+	return s.state
+}
+
+func TestJSONRPCParser_Health(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name            string
+		state           ObserverState
+		storageFailures int
+		wantLive        bool
+		wantReady       bool
+	}{
+		{
+			name:      "caught up",
+			state:     ObserverState{HeadBlock: 100, ProcessedBlock: 100, LastPoll: now, Progressed: now},
+			wantLive:  true,
+			wantReady: true,
+		},
+		{
+			name:     "before the first poll",
+			wantLive: true,
+		},
+		{
+			name:     "backfill is progressing",
+			state:    ObserverState{HeadBlock: 100, ProcessedBlock: 50, LastPoll: now, Progressed: now},
+			wantLive: true,
+		},
+		{
+			name:  "processed block doesn't move",
+			state: ObserverState{HeadBlock: 100, ProcessedBlock: 98, LastPoll: now, Progressed: now.Add(-time.Hour)},
+		},
+		{
+			name:  "polling stopped",
+			state: ObserverState{HeadBlock: 100, ProcessedBlock: 100, LastPoll: now.Add(-time.Hour), Progressed: now.Add(-time.Hour)},
+		},
+		{
+			name:     "all endpoints failing",
+			state:    ObserverState{HeadBlock: 100, ProcessedBlock: 100, LastPoll: now, Progressed: now, Failures: 3},
+			wantLive: true,
+		},
+		{
+			name:            "storage writes failing",
+			state:           ObserverState{HeadBlock: 100, ProcessedBlock: 100, LastPoll: now, Progressed: now},
+			storageFailures: 1,
+			wantLive:        true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jp := NewJSONRPCParser(&stateObserver{state: tt.state}, &mockTransactionStorage{}, &mockApiWrapper{}, nil, clogger.Logger)
+			jp.storageFailures = tt.storageFailures
+
+			health := jp.Health(DefaultHealthConfig)
+			if health.Live != tt.wantLive || health.Ready != tt.wantReady {
+				t.Errorf("Health() live = %v, ready = %v, want %v, %v, problems %v", health.Live, health.Ready, tt.wantLive, tt.wantReady, health.Problems)
+			}
+
+			if (health.Live && health.Ready) != (len(health.Problems) == 0) {
+				t.Errorf("Health() problems = %v", health.Problems)
+			}
+		})
+	}
+}
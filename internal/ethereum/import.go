@@ -0,0 +1,98 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ArchivedBlock is the block read from the archive, see Import.
+type ArchivedBlock struct {
+	Number       int64
+	Hash         string
+	Transactions []Transaction
+}
+
+// BlockSource returns the blocks of the archive in order, it
+// returns io.EOF after the last one.
+type BlockSource interface {
+	NextBlock() (ArchivedBlock, error)
+}
+
+// ImportProgress is reported by Import after every block.
+type ImportProgress struct {
+	Blocks       int64 `json:"blocks"`
+	CurrentBlock int64 `json:"currentBlock"`
+	Stored       int   `json:"stored"`
+}
+
+// Import is the Backfill from the exported blocks instead of the api, so the
+// history can be filled at the disk speed. Transactions to the addresses are
+// matched like the observer does it and the ones that are not stored yet are
+// stored. Progress is called after every block, it can be nil. It returns the
+// number of stored transactions, also when it stops early.
+func Import(
+	ctx context.Context,
+	source BlockSource,
+	network Network,
+	storage TransactionsStorage,
+	addresses []string,
+	progress func(progress ImportProgress),
+) (int, error) {
+	stored := make(map[Address]map[string]bool, len(addresses))
+	for _, address := range addresses {
+		observed, err := ParseAddress(address)
+		if err != nil {
+			return 0, err
+		}
+
+		stored[observed] = make(map[string]bool)
+		for _, transaction := range storage.GetTransactionsForAddress(observed.Hex()) {
+			stored[observed][transaction.Hash] = true
+		}
+	}
+
+	count := 0
+	for blocks := int64(1); ; blocks++ {
+		if err := ctx.Err(); err != nil {
+			return count, err
+		}
+
+		block, err := source.NextBlock()
+		if errors.Is(err, io.EOF) {
+			return count, nil
+		}
+
+		if err != nil {
+			return count, fmt.Errorf("read block: %w", err)
+		}
+
+		var errs []error
+		for _, transaction := range block.Transactions {
+			to, ok := network.recipient(transaction)
+			if !ok || stored[to] == nil || stored[to][transaction.Hash] {
+				continue
+			}
+
+			if err := storage.SerializeTransaction(SerializableTransaction{
+				Address:     to.Hex(),
+				Transaction: transaction,
+			}); err != nil {
+				errs = append(errs, fmt.Errorf("serialize transaction %s: %w", transaction.Hash, err))
+				continue
+			}
+
+			stored[to][transaction.Hash] = true
+			count++
+		}
+
+		if err := errors.Join(errs...); err != nil {
+			return count, err
+		}
+
+		if progress != nil {
+			progress(ImportProgress{Blocks: blocks, CurrentBlock: block.Number, Stored: count})
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	secondAddress := "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	source := &sliceBlockSource{blocks: []ArchivedBlock{
+		{Number: 1, Transactions: []Transaction{
+			{Hash: "0xa1", To: testAddress},
+			{Hash: "0xb1", To: secondAddress},
+			{Hash: "0xc1", To: "0x1111111111111111111111111111111111111111"},
+		}},
+		{Number: 2, Transactions: []Transaction{
+			// replayed on the other chain
+			{Hash: "0xa2", To: testAddress, ChainId: "0x89"},
+			// contract creation
+			{Hash: "0xa3"},
+			{Hash: "0xa4", To: testAddress, ChainId: "0x1"},
+		}},
+	}}
+
+	storage := &mapTransactionStorage{transactions: make(map[string][]Transaction)}
+	// already stored transaction is not stored again
+	_ = storage.SerializeTransaction(SerializableTransaction{Address: testAddress, Transaction: Transaction{Hash: "0xa1"}})
+
+	var progress []ImportProgress
+	count, err := Import(context.Background(), source, Mainnet, storage, []string{testAddress, secondAddress}, func(p ImportProgress) {
+		progress = append(progress, p)
+	})
+	if err != nil {
+		t.Fatalf("Import() error = %v", err)
+	}
+
+	if count != 2 {
+		t.Errorf("Import() = %v, want 2", count)
+	}
+
+	for address, want := range map[string][]string{testAddress: {"0xa1", "0xa4"}, secondAddress: {"0xb1"}} {
+		var hashes []string
+		for _, transaction := range storage.GetTransactionsForAddress(address) {
+			hashes = append(hashes, transaction.Hash)
+		}
+
+		if !reflect.DeepEqual(hashes, want) {
+			t.Errorf("stored for %s = %v, want %v", address, hashes, want)
+		}
+	}
+
+	want := []ImportProgress{{Blocks: 1, CurrentBlock: 1, Stored: 1}, {Blocks: 2, CurrentBlock: 2, Stored: 2}}
+	if !reflect.DeepEqual(progress, want) {
+		t.Errorf("progress = %v, want %v", progress, want)
+	}
+}
+
+func TestImport_SourceError(t *testing.T) {
+	source := &sliceBlockSource{err: errors.New("broken archive")}
+
+	if _, err := Import(context.Background(), source, Mainnet, &flushingStorage{}, []string{testAddress}, nil); err == nil {
+		t.Error("Import() error = nil, want source error")
+	}
+
+	if _, err := Import(context.Background(), source, Mainnet, &flushingStorage{}, []string{"test"}, nil); !errors.Is(err, ErrInvalidAddress) {
+		t.Errorf("Import() error = %v, want %v", err, ErrInvalidAddress)
+	}
+}
+
+type sliceBlockSource struct {
+	blocks []ArchivedBlock
+	err    error
+}
+
+func (s *sliceBlockSource) NextBlock() (ArchivedBlock, error) {
+	if s.err != nil {
+		return ArchivedBlock{}, s.err
+	}
+
+	if len(s.blocks) == 0 {
+		return ArchivedBlock{}, io.EOF
+	}
+
+	block := s.blocks[0]
+	s.blocks = s.blocks[1:]
+
+	return block, nil
+}
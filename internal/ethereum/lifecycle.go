@@ -0,0 +1,64 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// DefaultShutdownTimeout is the time Close waits for the graceful shutdown.
+const DefaultShutdownTimeout = 30 * time.Second
+
+var (
+	// ErrClosed is returned when closed component is used.
+	ErrClosed = errors.New("closed")
+	// ErrNotSubscribed is returned when address that is not subscribed is unsubscribed.
+	ErrNotSubscribed = errors.New("address not subscribed")
+	// ErrShutdownTimeout is returned when shutdown didn't finish before the context was done.
+	ErrShutdownTimeout = errors.New("shutdown timeout")
+	// ErrStorageLocked is returned by Locker when another process writes to the storage.
+	ErrStorageLocked = errors.New("storage is locked by another process")
+)
+
+// Lifecycle is implemented by the components which are
+// doing their work in the background goroutines.
+type Lifecycle interface {
+	// Start starts the background work.
+	Start() error
+	// Shutdown stops the background work and waits until it is done,
+	// or until the context is done.
+	Shutdown(ctx context.Context) error
+}
+
+// Flusher can be implemented by the TransactionsStorage
+// which doesn't write transactions right away.
+type Flusher interface {
+	// Flush writes all the buffered transactions.
+	Flush() error
+}
+
+// Locker can be implemented by the TransactionsStorage which
+// is shared by the processes, i.e. the file one.
+type Locker interface {
+	// Lock makes this process the only writer of the storage until it is
+	// closed, it returns ErrStorageLocked if another process is.
+	Lock() error
+}
+
+// waitContext waits for the wait group, or until the context is done.
+func waitContext(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
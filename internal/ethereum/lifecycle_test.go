@@ -0,0 +1,176 @@
+package ethereum
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"tw/internal/clogger"
+)
+
+func TestJSONRPCParser_ShutdownDoesNotLeakGoroutines(t *testing.T) {
+	secondAddress := "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	baseline := runtime.NumGoroutine()
+
+	apiWrapper := &mockApiWrapper{
+		getCurrentBlockFunc: func(httpClient *http.Client) (string, error) {
+			return "0x10", nil
+		},
+		getTransactionsForBlockFunc: func(httpClient *http.Client, blockNum string) ([]Transaction, error) {
+			return []Transaction{{Hash: "0x1", To: testAddress}, {Hash: "0x2", To: secondAddress}}, nil
+		},
+	}
+
+	storage := &flushingStorage{}
+	observer := NewJSONRpcBasedObserver(nil, clogger.Logger, apiWrapper, Mainnet)
+	jp := NewJSONRPCParser(observer, storage, apiWrapper, nil, clogger.Logger)
+
+	if err := jp.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	handled := make(chan struct{}, 1)
+	_ = jp.SubscribeFunc(testAddress, func(ctx context.Context, event Event) error {
+		select {
+		case handled <- struct{}{}:
+		default:
+		}
+
+		return nil
+	})
+	_ = jp.SubscribeAddress(secondAddress)
+
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler wasn't called")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := jp.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	if storage.flushed != 1 {
+		t.Errorf("storage flushed = %v, want 1", storage.flushed)
+	}
+
+	if err := jp.SubscribeAddress(testAddress); !errors.Is(err, ErrClosed) {
+		t.Errorf("SubscribeAddress() after shutdown error = %v, want %v", err, ErrClosed)
+	}
+
+	// handler of the failed subscription doesn't stay registered, its workers are stopped
+	if err := jp.SubscribeFunc(secondAddress, func(ctx context.Context, event Event) error { return nil }); !errors.Is(err, ErrClosed) {
+		t.Errorf("SubscribeFunc() after shutdown error = %v, want %v", err, ErrClosed)
+	}
+
+	if handlers := jp.handlers[secondAddress]; len(handlers) != 0 {
+		t.Errorf("handlers after failed SubscribeFunc() = %v, want none", handlers)
+	}
+
+	// second shutdown is no-op
+	if err := jp.Shutdown(ctx); err != nil {
+		t.Errorf("second Shutdown() error = %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for runtime.NumGoroutine() > baseline {
+		if time.Now().After(deadline) {
+			t.Fatalf("goroutines = %v, want at most %v", runtime.NumGoroutine(), baseline)
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestJSONRPCParser_ShutdownTimeout(t *testing.T) {
+	jp := NewJSONRPCParser(&mockObserver{}, &mockTransactionStorage{}, &mockApiWrapper{}, nil, clogger.Logger)
+
+	// handler holds the shutdown until it is given up
+	_ = jp.SubscribeFunc(testAddress, func(ctx context.Context, event Event) error {
+		<-ctx.Done()
+
+		return ctx.Err()
+	})
+
+	jp.emit(TransactionObserved{Address: testAddress})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := jp.Shutdown(ctx); !errors.Is(err, ErrShutdownTimeout) {
+		t.Errorf("Shutdown() error = %v, want %v", err, ErrShutdownTimeout)
+	}
+}
+
+type flushingStorage struct {
+	mu           sync.Mutex
+	transactions []Transaction
+	flushed      int
+}
+
+func (f *flushingStorage) SerializeTransaction(transaction SerializableTransaction) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.transactions = append(f.transactions, transaction.Transaction)
+
+	return nil
+}
+
+func (f *flushingStorage) GetTransactionsForAddress(address string) []Transaction {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.transactions
+}
+
+func (f *flushingStorage) Flush() error {
+	f.flushed++
+
+	return nil
+}
+
+func TestJSONRPCParser_Unsubscribe(t *testing.T) {
+	apiWrapper := &mockApiWrapper{
+		getCurrentBlockFunc: func(httpClient *http.Client) (string, error) {
+			return "0x10", nil
+		},
+	}
+
+	observer := NewJSONRpcBasedObserver(nil, clogger.Logger, apiWrapper, Mainnet)
+	jp := NewJSONRPCParser(observer, &flushingStorage{}, apiWrapper, nil, clogger.Logger)
+
+	_ = jp.SubscribeFunc(testAddress, func(ctx context.Context, event Event) error { return nil })
+
+	if err := jp.Unsubscribe(testAddress); err != nil {
+		t.Fatalf("Unsubscribe() error = %v", err)
+	}
+
+	if err := jp.Unsubscribe(testAddress); !errors.Is(err, ErrNotSubscribed) {
+		t.Errorf("second Unsubscribe() error = %v, want %v", err, ErrNotSubscribed)
+	}
+
+	if got := jp.Subscriptions(); len(got) != 0 {
+		t.Errorf("Subscriptions() = %v, want none", got)
+	}
+
+	// address can be subscribed again
+	if err := jp.SubscribeAddress(testAddress); err != nil {
+		t.Errorf("SubscribeAddress() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := jp.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+}
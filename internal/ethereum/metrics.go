@@ -0,0 +1,29 @@
+package ethereum
+
+import (
+	"tw/internal/metrics"
+)
+
+// metrics of the package, labeled by the network name, except the
+// api requests which don't know the network
+var (
+	rpcRequestDuration = metrics.Default.NewHistogram("tw_rpc_request_duration_seconds", "Duration of the JSON-RPC api requests by method.", nil, "method")
+	rpcErrors          = metrics.Default.NewCounter("tw_rpc_errors_total", "Failed JSON-RPC api requests by method and code: JSON-RPC error code, http status code, transport or decode.", "method", "code")
+
+	headBlock          = metrics.Default.NewGauge("tw_head_block", "Newest block of the network.", "network")
+	processedBlock     = metrics.Default.NewGauge("tw_processed_block", "Last block processed by the observer.", "network")
+	headLag            = metrics.Default.NewGauge("tw_head_lag_blocks", "Number of blocks the observer is behind the head.", "network")
+	blocksProcessed    = metrics.Default.NewCounter("tw_blocks_processed_total", "Blocks processed by the observer.", "network")
+	observerErrors     = metrics.Default.NewCounter("tw_observer_errors_total", "Failed attempts to get the current block or the block transactions.", "network")
+	transactionsQueued = metrics.Default.NewCounter("tw_transactions_matched_total", "Transactions to the observed addresses.", "network")
+	queueDepth         = metrics.Default.NewGauge("tw_subscriber_queue_depth", "Transactions waiting in the subscriber queue by address.", "network", "address")
+	subscriberDropped  = metrics.Default.NewCounter("tw_subscriber_dropped_total", "Transactions dropped by the subscriber queues, because they were full or the spill file couldn't be read, by the overflow policy.", "network", "policy")
+
+	subscriptions = metrics.Default.NewGauge("tw_subscriptions", "Subscribed addresses.", "network")
+	droppedEvents = metrics.Default.NewCounter("tw_events_dropped_total", "Events dropped because the Events reader didn't keep up.", "network")
+	deadLetters   = metrics.Default.NewCounter("tw_dead_letters_total", "Events handlers failed to handle.", "network")
+
+	storageWrites        = metrics.Default.NewCounter("tw_storage_writes_total", "Transactions written to the storage.", "network")
+	storageErrors        = metrics.Default.NewCounter("tw_storage_errors_total", "Failed storage writes.", "network")
+	storageWriteDuration = metrics.Default.NewHistogram("tw_storage_write_duration_seconds", "Duration of the storage writes.", []float64{.0001, .0005, .001, .005, .01, .05, .1, .5, 1}, "network")
+)
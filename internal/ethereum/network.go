@@ -0,0 +1,192 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"tw/internal/hexutil"
+)
+
+// ErrChainIDMismatch is returned when api endpoint serves
+// different network than the configured one.
+var ErrChainIDMismatch = errors.New("chain id mismatch")
+
+// Network describes EVM compatible network.
+type Network struct {
+	// Name is used to scope storage keys, so it has to be unique.
+	Name string
+	// ChainID is the EIP-155 chain id of the network.
+	ChainID int64
+	// DefaultEndpoint is the public api endpoint of the network.
+	DefaultEndpoint string
+}
+
+var (
+	Mainnet = Network{
+		Name:            "mainnet",
+		ChainID:         1,
+		DefaultEndpoint: "https://cloudflare-eth.com",
+	}
+	Sepolia = Network{
+		Name:            "sepolia",
+		ChainID:         11155111,
+		DefaultEndpoint: "https://rpc.sepolia.org",
+	}
+	Polygon = Network{
+		Name:            "polygon",
+		ChainID:         137,
+		DefaultEndpoint: "https://polygon-rpc.com",
+	}
+	Arbitrum = Network{
+		Name:            "arbitrum",
+		ChainID:         42161,
+		DefaultEndpoint: "https://arb1.arbitrum.io/rpc",
+	}
+	Base = Network{
+		Name:            "base",
+		ChainID:         8453,
+		DefaultEndpoint: "https://mainnet.base.org",
+	}
+)
+
+// Networks are all the known networks.
+var Networks = []Network{Mainnet, Sepolia, Polygon, Arbitrum, Base}
+
+// NetworkByName returns known network with the given name.
+func NetworkByName(name string) (Network, bool) {
+	for _, network := range Networks {
+		if strings.EqualFold(network.Name, name) {
+			return network, true
+		}
+	}
+
+	return Network{}, false
+}
+
+func (n Network) String() string {
+	return fmt.Sprintf("%s (chain id %d)", n.Name, n.ChainID)
+}
+
+// matchesChainID returns true if transaction belongs to the network. Legacy
+// transactions (before EIP-155) don't have chain id, so they always match.
+func (n Network) matchesChainID(chainID string) bool {
+	if n.ChainID == 0 || chainID == "" {
+		return true
+	}
+
+	// malformed chain id can't be told from the other chain
+	id, err := hexutil.DecodeInt64(chainID)
+	if err != nil {
+		return false
+	}
+
+	return id == n.ChainID
+}
+
+// recipient returns the address the transaction is sent to, if the
+// transaction belongs to the network and it isn't contract creation.
+func (n Network) recipient(transaction Transaction) (Address, bool) {
+	// replayed transaction signed for the other network, it's not ours
+	if !n.matchesChainID(transaction.ChainId) {
+		return Address{}, false
+	}
+
+	to, err := ParseAddress(transaction.To)
+	if err != nil {
+		return Address{}, false
+	}
+
+	return to, true
+}
+
+// VerifyChainID checks if api serves the given network.
+func VerifyChainID(apiWrapper ApiWrapper, httpClient *http.Client, network Network) error {
+	res, err := apiWrapper.GetChainID(httpClient)
+	if err != nil {
+		return fmt.Errorf("get chain id: %w", err)
+	}
+
+	chainID, err := hexutil.DecodeInt64(res)
+	if err != nil {
+		return fmt.Errorf("invalid chain id returned from api: %w", err)
+	}
+
+	if chainID != network.ChainID {
+		return fmt.Errorf("%w: expected %s, api serves chain id %d", ErrChainIDMismatch, network, chainID)
+	}
+
+	return nil
+}
+
+// networkScopedStorage prefixes addresses with network name, so
+// the same storage can be shared by parsers of different networks.
+type networkScopedStorage struct {
+	network Network
+	storage TransactionsStorage
+}
+
+var _ TransactionsStorage = (*networkScopedStorage)(nil)
+var _ Flusher = (*networkScopedStorage)(nil)
+var _ AddressLister = (*networkScopedStorage)(nil)
+
+// NewNetworkScopedStorage wraps storage, so all the keys are scoped to the network.
+func NewNetworkScopedStorage(storage TransactionsStorage, network Network) TransactionsStorage {
+	return &networkScopedStorage{
+		network: network,
+		storage: storage,
+	}
+}
+
+func (ns *networkScopedStorage) SerializeTransaction(transaction SerializableTransaction) error {
+	transaction.Address = ns.key(transaction.Address)
+
+	start := time.Now()
+	err := ns.storage.SerializeTransaction(transaction)
+	storageWriteDuration.Observe(time.Since(start).Seconds(), ns.network.Name)
+
+	if err != nil {
+		storageErrors.Inc(ns.network.Name)
+		return err
+	}
+
+	storageWrites.Inc(ns.network.Name)
+
+	return nil
+}
+
+func (ns *networkScopedStorage) GetTransactionsForAddress(address string) []Transaction {
+	return ns.storage.GetTransactionsForAddress(ns.key(address))
+}
+
+// Flush flushes the wrapped storage, if it buffers transactions.
+func (ns *networkScopedStorage) Flush() error {
+	if flusher, ok := ns.storage.(Flusher); ok {
+		return flusher.Flush()
+	}
+
+	return nil
+}
+
+// Addresses returns the addresses of the network, if the wrapped storage lists them.
+func (ns *networkScopedStorage) Addresses() []string {
+	lister, ok := ns.storage.(AddressLister)
+	if !ok {
+		return nil
+	}
+
+	var addresses []string
+	for _, key := range lister.Addresses() {
+		if address, ok := strings.CutPrefix(key, ns.network.Name+":"); ok {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
+
+func (ns *networkScopedStorage) key(address string) string {
+	return ns.network.Name + ":" + address
+}
@@ -0,0 +1,160 @@
+package ethereum
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+
+	"tw/internal/hexutil"
+)
+
+func TestVerifyChainID(t *testing.T) {
+	tests := []struct {
+		name    string
+		chainID string
+		err     error
+		network Network
+		wantErr error
+	}{
+		{
+			name:    "api serves configured network, no error",
+			chainID: "0x1",
+			network: Mainnet,
+		},
+		{
+			name:    "api serves other network, returns mismatch error",
+			chainID: "0xaa36a7",
+			network: Mainnet,
+			wantErr: ErrChainIDMismatch,
+		},
+		{
+			name:    "api returns chain id with leading zero, returns error",
+			chainID: "0x01",
+			network: Mainnet,
+			wantErr: hexutil.ErrLeadingZero,
+		},
+		{
+			name:    "api returns empty chain id, returns error",
+			network: Mainnet,
+			wantErr: hexutil.ErrEmpty,
+		},
+		{
+			name:    "api returns error, returns error",
+			err:     errors.New("error"),
+			network: Base,
+			wantErr: errors.New("get chain id: error"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			apiWrapper := &mockApiWrapper{
+				getChainIDFunc: func(httpClient *http.Client) (string, error) {
+					return tt.chainID, tt.err
+				},
+			}
+
+			err := VerifyChainID(apiWrapper, http.DefaultClient, tt.network)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Errorf("VerifyChainID() error = %v, want nil", err)
+			case tt.wantErr != nil && err == nil:
+				t.Errorf("VerifyChainID() error = nil, want %v", tt.wantErr)
+			case tt.wantErr != nil && !errors.Is(err, tt.wantErr) && err.Error() != tt.wantErr.Error():
+				t.Errorf("VerifyChainID() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNetwork_matchesChainID(t *testing.T) {
+	tests := []struct {
+		name    string
+		network Network
+		chainID string
+		want    bool
+	}{
+		{
+			name:    "same chain id matches",
+			network: Polygon,
+			chainID: "0x89",
+			want:    true,
+		},
+		{
+			name:    "other chain id doesn't match",
+			network: Polygon,
+			chainID: "0x1",
+			want:    false,
+		},
+		{
+			name:    "decimal chain id isn't quantity, it doesn't match",
+			network: Polygon,
+			chainID: "137",
+			want:    false,
+		},
+		{
+			name:    "legacy transaction without chain id matches",
+			network: Polygon,
+			want:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.network.matchesChainID(tt.chainID); got != tt.want {
+				t.Errorf("matchesChainID() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNetworkScopedStorage_SharedStorage(t *testing.T) {
+	shared := &mapTransactionStorage{transactions: make(map[string][]Transaction)}
+
+	mainnet := NewNetworkScopedStorage(shared, Mainnet)
+	base := NewNetworkScopedStorage(shared, Base)
+
+	mainnetTransaction := Transaction{Hash: "0x1"}
+	_ = mainnet.SerializeTransaction(SerializableTransaction{Address: "addr", Transaction: mainnetTransaction})
+
+	if got := mainnet.GetTransactionsForAddress("addr"); !reflect.DeepEqual(got, []Transaction{mainnetTransaction}) {
+		t.Errorf("mainnet GetTransactionsForAddress() = %v", got)
+	}
+
+	if got := base.GetTransactionsForAddress("addr"); got != nil {
+		t.Errorf("base GetTransactionsForAddress() = %v, want nil", got)
+	}
+
+	if got := mainnet.(AddressLister).Addresses(); !reflect.DeepEqual(got, []string{"addr"}) {
+		t.Errorf("mainnet Addresses() = %v, want [addr]", got)
+	}
+
+	if got := base.(AddressLister).Addresses(); got != nil {
+		t.Errorf("base Addresses() = %v, want nil", got)
+	}
+}
+
+type mapTransactionStorage struct {
+	transactions map[string][]Transaction
+}
+
+func (m *mapTransactionStorage) SerializeTransaction(transaction SerializableTransaction) error {
+	m.transactions[transaction.Address] = append(m.transactions[transaction.Address], transaction.Transaction)
+
+	return nil
+}
+
+func (m *mapTransactionStorage) GetTransactionsForAddress(address string) []Transaction {
+	return m.transactions[address]
+}
+
+func (m *mapTransactionStorage) Addresses() []string {
+	var addresses []string
+	for address := range m.transactions {
+		addresses = append(addresses, address)
+	}
+
+	sort.Strings(addresses)
+
+	return addresses
+}
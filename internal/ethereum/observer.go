@@ -1,135 +1,672 @@
 package ethereum
 
 import (
+	"context"
 	"fmt"
 	"io"
-	"log"
-	"math/big"
+	"log/slog"
 	"net/http"
-	"strings"
 	"sync"
 	"time"
+
+	"tw/internal/hexutil"
 )
 
-const checkBlockNumberIntervalSeconds = 5
+const (
+	// defaultPollInterval is how often observer checks for the new block.
+	defaultPollInterval = 5 * time.Second
+	// defaultConfirmations is the number of blocks after which
+	// observed transaction is considered as confirmed.
+	defaultConfirmations = 12
+	// laggingThresholdBlocks is the number of blocks observer can be
+	// behind the head before it reports that it is lagging.
+	laggingThresholdBlocks = 10
+)
 
 type JSONRpcBasedObserver struct {
-	httpClient *http.Client
-	logger     *log.Logger
-	apiWrapper ApiWrapper
+	httpClient    *http.Client
+	logger        *slog.Logger
+	apiWrapper    ApiWrapper
+	network       Network
+	confirmations int64
+	pollInterval  time.Duration
+	// startBlock is the first block to process, zero means the current one.
+	startBlock int64
+
+	queueConfig QueueConfig
 
 	closeChan chan struct{}
+	closeOnce sync.Once
+	pollWG    sync.WaitGroup
+	forwardWG sync.WaitGroup
+
+	emit        func(event Event)
+	state       ObserverState
+	subscribers map[Address]*subscription
+	pending     []pendingTransaction
+	closed      bool
+	mu          sync.Mutex
+	pollOnce    sync.Once
+}
+
+// subscription is the queue of the observed address, transactions
+// are moved from it to the subscriber channel by separate goroutine,
+// so slow subscriber doesn't hold the other ones.
+type subscription struct {
+	queue            *transactionQueue
+	transactionsChan chan Transaction
+}
+
+// ObserverOption configures JSONRpcBasedObserver.
+type ObserverOption func(observer *JSONRpcBasedObserver)
+
+// WithSubscriberQueue sets size and overflow policy of the subscribers queues.
+func WithSubscriberQueue(config QueueConfig) ObserverOption {
+	return func(observer *JSONRpcBasedObserver) {
+		observer.queueConfig = config
+	}
+}
+
+// WithPollInterval sets how often observer checks for the new block.
+func WithPollInterval(interval time.Duration) ObserverOption {
+	return func(observer *JSONRpcBasedObserver) {
+		observer.pollInterval = interval
+	}
+}
+
+// WithConfirmations sets the number of blocks after which observed transaction
+// is confirmed, zero disables TransactionConfirmed and Reorg events.
+func WithConfirmations(confirmations int64) ObserverOption {
+	return func(observer *JSONRpcBasedObserver) {
+		observer.confirmations = confirmations
+	}
+}
+
+// WithStartBlock makes observer start from the given block instead of the
+// current one, blocks between them are backfilled.
+func WithStartBlock(block int64) ObserverOption {
+	return func(observer *JSONRpcBasedObserver) {
+		observer.startBlock = block
+	}
+}
+
+// pendingTransaction is observed transaction which
+// waits for the confirmations.
+type pendingTransaction struct {
+	address     string
+	transaction Transaction
+	blockNumber int64
 }
 
 var _ Observer = (*JSONRpcBasedObserver)(nil)
+var _ EventEmitter = (*JSONRpcBasedObserver)(nil)
+var _ Unobserver = (*JSONRpcBasedObserver)(nil)
+var _ Lifecycle = (*JSONRpcBasedObserver)(nil)
 var _ io.Closer = (*JSONRpcBasedObserver)(nil)
 
-func NewJSONRpcBasedObserver(httpClient *http.Client, logger *log.Logger, apiWrapper ApiWrapper) *JSONRpcBasedObserver {
-	return &JSONRpcBasedObserver{
-		httpClient: httpClient,
-		logger:     logger,
-		apiWrapper: apiWrapper,
-		closeChan:  make(chan struct{}, 1),
+// NewJSONRpcBasedObserver creates a new instance of JSONRpcBasedObserver. Transactions
+// with chain id different than the one of the network are skipped.
+func NewJSONRpcBasedObserver(httpClient *http.Client, logger *slog.Logger, apiWrapper ApiWrapper, network Network, opts ...ObserverOption) *JSONRpcBasedObserver {
+	observer := &JSONRpcBasedObserver{
+		httpClient:    httpClient,
+		logger:        logger,
+		apiWrapper:    apiWrapper,
+		network:       network,
+		confirmations: defaultConfirmations,
+		pollInterval:  defaultPollInterval,
+		queueConfig: QueueConfig{
+			Size:   defaultQueueSize,
+			Policy: Block,
+		},
+		closeChan: make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(observer)
 	}
+
+	return observer
+}
+
+// OnEvent sets the function called with the events not related to
+// the transactions (errors, reorgs etc.). It has to be called before
+// the first address is observed.
+func (j *JSONRpcBasedObserver) OnEvent(emit func(event Event)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.emit = emit
 }
 
 // ObserveAddress observes blockchain for changes to a given address transactions, if any found
-// it returns that transaction on the channel.
+// it returns that transaction on the channel. All the addresses are observed by the same
+// polling goroutine, which is started with the first observed address.
 func (j *JSONRpcBasedObserver) ObserveAddress(address string) (<-chan Transaction, error) {
-	// we are going to check if new block appeared
-	blockNumberChan := make(chan string)
-	transactionsChan := make(chan Transaction)
+	observedAddress, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
 
-	var mu sync.Mutex
-	closed := false
+	j.mu.Lock()
+	defer j.mu.Unlock()
 
-	// if observer is closed we are closing the transaction chan
-	go func() {
-		<-j.closeChan
+	if j.closed {
+		return nil, ErrClosed
+	}
 
-		mu.Lock()
+	if _, ok := j.subscribers[observedAddress]; ok {
+		return nil, fmt.Errorf("address %s is already observed", observedAddress)
+	}
 
-		defer mu.Unlock()
+	if j.subscribers == nil {
+		j.subscribers = make(map[Address]*subscription)
+	}
 
-		closed = true
+	queue := newTransactionQueue(j.queueConfig)
+	queue.onDrop = func(n int) {
+		subscriberDropped.Add(float64(n), j.network.Name, j.queueConfig.Policy.String())
+	}
 
-		close(transactionsChan)
-		close(blockNumberChan)
-	}()
+	sub := &subscription{
+		queue:            queue,
+		transactionsChan: make(chan Transaction),
+	}
+	j.subscribers[observedAddress] = sub
+
+	j.forwardWG.Add(1)
+	go j.forward(observedAddress, sub)
+
+	// observer which wasn't started explicitly starts with the first address
+	j.startPolling()
+
+	return sub.transactionsChan, nil
+}
+
+// UnobserveAddress stops observing the address, transactions which are already
+// in the queue are passed to the channel and then the channel is closed.
+func (j *JSONRpcBasedObserver) UnobserveAddress(address string) error {
+	observedAddress, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	sub, ok := j.subscribers[observedAddress]
+	if !ok {
+		return fmt.Errorf("address %s is not observed", observedAddress)
+	}
+
+	delete(j.subscribers, observedAddress)
+	sub.queue.close()
+
+	// nobody waits for the confirmations anymore
+	pending := j.pending[:0]
+	for _, p := range j.pending {
+		if p.address != observedAddress.Hex() {
+			pending = append(pending, p)
+		}
+	}
+
+	j.pending = pending
+
+	return nil
+}
 
-	// we are checking if new blocks appeared
-	go func() {
-		for {
-			j.logger.Println("checking for new block")
+// Start starts polling for the new blocks.
+func (j *JSONRpcBasedObserver) Start() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
 
-			num, err := j.apiWrapper.GetCurrentBlock(j.httpClient)
+	if j.closed {
+		return ErrClosed
+	}
+
+	j.startPolling()
+
+	return nil
+}
+
+// startPolling starts the polling goroutine once, j.mu has to be locked.
+func (j *JSONRpcBasedObserver) startPolling() {
+	j.pollOnce.Do(func() {
+		j.pollWG.Add(1)
+		go j.poll()
+	})
+}
+
+// Network returns the observed network.
+func (j *JSONRpcBasedObserver) Network() Network {
+	return j.network
+}
+
+// State returns the progress of the observer, see JSONRPCParser.Health.
+func (j *JSONRpcBasedObserver) State() ObserverState {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.state
+}
+
+// updateState changes the state under the lock.
+func (j *JSONRpcBasedObserver) updateState(update func(state *ObserverState)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	update(&j.state)
+}
+
+// QueueStats returns stats of the subscribers queues by address.
+func (j *JSONRpcBasedObserver) QueueStats() map[string]QueueStats {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	stats := make(map[string]QueueStats, len(j.subscribers))
+	for address, sub := range j.subscribers {
+		stats[address.Hex()] = sub.queue.queueStats()
+	}
+
+	return stats
+}
+
+// Shutdown stops polling for the new blocks. Block that is being processed is
+// finished first, then the subscribers queues are drained to their channels
+// and the channels are closed. If it doesn't finish before the context is done,
+// pending api request is aborted (if api wrapper is io.Closer) and it returns
+// ErrShutdownTimeout.
+func (j *JSONRpcBasedObserver) Shutdown(ctx context.Context) error {
+	// no more subscribers and polling after this point
+	j.mu.Lock()
+	j.closed = true
+	j.mu.Unlock()
+
+	j.closeOnce.Do(func() {
+		close(j.closeChan)
+	})
+
+	if err := waitContext(ctx, &j.pollWG); err != nil {
+		if closer, ok := j.apiWrapper.(io.Closer); ok {
+			_ = closer.Close()
+		}
+
+		// closing the queues releases polling goroutine if it waits for the subscriber
+		j.closeSubscribers()
+
+		return fmt.Errorf("%w: observer polling: %w", ErrShutdownTimeout, err)
+	}
+
+	j.closeSubscribers()
+
+	if err := waitContext(ctx, &j.forwardWG); err != nil {
+		return fmt.Errorf("%w: observer subscribers: %w", ErrShutdownTimeout, err)
+	}
+
+	return nil
+}
+
+// Close shuts down the observer, waiting at most DefaultShutdownTimeout.
+func (j *JSONRpcBasedObserver) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+
+	return j.Shutdown(ctx)
+}
+
+// poll checks for the new blocks until observer is closed.
+func (j *JSONRpcBasedObserver) poll() {
+	defer j.pollWG.Done()
+
+	pollInterval := j.pollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	// we are starting with the current block, unless start block was set
+	lastBlockNum := j.startBlock - 1
+
+	var failures int
+	var failingSince time.Time
+
+	for {
+		j.logger.Debug("checking for new block", "network", j.network.Name)
+
+		ctx, span := startSpan(context.Background(), spanPoll, attrNetwork.String(j.network.Name))
+
+		currentBlockNum, err := j.currentBlock()
+
+		now := time.Now()
+		j.updateState(func(state *ObserverState) {
+			if state.Progressed.IsZero() {
+				state.Progressed = now
+			}
+
+			state.LastPoll = now
 			if err != nil {
-				j.logger.Printf("get current block error: %s", err.Error())
+				state.Failures = failures + 1
+			} else {
+				state.Failures = 0
+				state.HeadBlock = currentBlockNum
+			}
+		})
+
+		if err != nil {
+			if failures == 0 {
+				failingSince = now
 			}
 
-			blockNumberChan <- num
+			failures++
 
-			time.Sleep(time.Second * checkBlockNumberIntervalSeconds)
+			j.logger.Warn("get current block failed", "network", j.network.Name, "failures", failures, "err", err)
+			observerErrors.Inc(j.network.Name)
+			j.emitEvent(SourceError{Err: err, Since: failingSince, Failures: failures})
+		} else {
+			failures = 0
+			headBlock.Set(float64(currentBlockNum), j.network.Name)
+			span.SetAttributes(attrBlock.Int64(currentBlockNum))
+
+			if lastBlockNum < 0 {
+				lastBlockNum = currentBlockNum - 1
+			}
+
+			lastBlockNum = j.processBlocks(ctx, lastBlockNum, currentBlockNum)
+		}
+
+		endSpan(span, err)
+
+		select {
+		case <-j.closeChan:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// processBlocks looks for the transactions in the blocks after the last
+// processed one up to the current one. It returns the last processed block.
+func (j *JSONRpcBasedObserver) processBlocks(ctx context.Context, lastBlockNum, currentBlockNum int64) int64 {
+	// if there is no dif in block num it means there are no new transactions
+	dif := currentBlockNum - lastBlockNum
+	headLag.Set(float64(max(dif, 0)), j.network.Name)
+
+	if dif <= 0 {
+		j.updateState(func(state *ObserverState) {
+			state.ProcessedBlock = lastBlockNum
+			state.Progressed = time.Now()
+		})
+
+		return lastBlockNum
+	}
+
+	if dif > laggingThresholdBlocks {
+		j.emitEvent(Lagging{HeadBlock: currentBlockNum, ProcessedBlock: lastBlockNum, Behind: dif})
+	}
+
+	j.logger.Debug("new block found, looking for transactions", "network", j.network.Name, "block", currentBlockNum, "from_block", lastBlockNum+1)
+
+	// for each new block after the last block we are fetching the transactions
+	// and then we are checking if there are any for given address
+	for blockNum := lastBlockNum + 1; blockNum <= currentBlockNum; blockNum++ {
+		// observer is shutting down, block that was processed is finished, next ones are skipped
+		if j.isClosing() {
+			return blockNum - 1
+		}
+
+		transactions, err := j.fetchBlock(ctx, blockNum)
+		if err != nil {
+			j.logger.Warn("get transactions for block failed", "network", j.network.Name, "block", blockNum, "err", err)
+			observerErrors.Inc(j.network.Name)
+			j.emitEvent(SourceError{Err: fmt.Errorf("get transactions for block %d: %w", blockNum, err), Since: time.Now(), Failures: 1})
+
+			// block is going to be fetched again with the next check
+			return blockNum - 1
+		}
+
+		j.matchTransactions(ctx, blockNum, transactions)
+
+		j.updateState(func(state *ObserverState) {
+			state.ProcessedBlock = blockNum
+			state.Progressed = time.Now()
+		})
+
+		blocksProcessed.Inc(j.network.Name)
+		processedBlock.Set(float64(blockNum), j.network.Name)
+		headLag.Set(float64(currentBlockNum-blockNum), j.network.Name)
+
+		if dif > 1 {
+			j.emitEvent(BackfillProgress{FromBlock: lastBlockNum + 1, ToBlock: currentBlockNum, CurrentBlock: blockNum})
+		}
+	}
+
+	j.checkConfirmations(ctx, currentBlockNum)
+
+	return currentBlockNum
+}
+
+// fetchBlock returns transactions of the block.
+func (j *JSONRpcBasedObserver) fetchBlock(ctx context.Context, blockNum int64) ([]Transaction, error) {
+	_, span := startSpan(ctx, spanFetchBlock, attrNetwork.String(j.network.Name), attrBlock.Int64(blockNum))
+
+	transactions, err := j.apiWrapper.GetTransactionsForBlock(j.httpClient, fmt.Sprintf("%x", blockNum))
+	span.SetAttributes(attrTransactions.Int(len(transactions)))
+	endSpan(span, err)
+
+	return transactions, err
+}
+
+// matchTransactions sends transactions to the subscribers of their addresses.
+// Matched transactions carry the span, so the parser continues the trace.
+func (j *JSONRpcBasedObserver) matchTransactions(ctx context.Context, blockNum int64, transactions []Transaction) {
+	_, span := startSpan(ctx, spanMatch, attrNetwork.String(j.network.Name), attrBlock.Int64(blockNum), attrTransactions.Int(len(transactions)))
+	defer span.End()
+
+	matched := 0
+	defer func() { span.SetAttributes(attrMatched.Int(matched)) }()
+
+	for _, transaction := range transactions {
+		to, ok := j.network.recipient(transaction)
+		if !ok {
+			continue
+		}
+
+		j.mu.Lock()
+		sub, ok := j.subscribers[to]
+		j.mu.Unlock()
+
+		if !ok {
+			continue
+		}
+
+		matched++
+		transaction.span = span.SpanContext()
+
+		// there is an transaction for a given address, we are putting it to its queue
+		queued, err := sub.queue.push(transaction)
+		if err != nil {
+			j.logger.Error("queue transaction failed", "network", j.network.Name, "address", to.Hex(), "hash", transaction.Hash, "err", err)
+		}
+
+		if queued {
+			transactionsQueued.Inc(j.network.Name)
+			queueDepth.Set(float64(sub.queue.queueStats().Depth), j.network.Name, to.Hex())
+		}
+	}
+}
+
+// forward moves transactions from the queue to the subscriber channel
+// until the queue is closed and empty, then it closes the channel.
+func (j *JSONRpcBasedObserver) forward(address Address, sub *subscription) {
+	defer j.forwardWG.Done()
+	defer close(sub.transactionsChan)
+
+	defer func() {
+		queueDepth.Delete(j.network.Name, address.Hex())
+
+		if err := sub.queue.release(); err != nil {
+			j.logger.Error("release queue failed", "network", j.network.Name, "address", address.Hex(), "err", err)
 		}
 	}()
 
-	go func() {
-		var lastBlockNum int64
-		for num := range blockNumberChan {
-			n := new(big.Int)
-			// passing 0, it will pick base based on the string
-			n.SetString(num, 0)
-			currentBlockNum := n.Int64()
+	for {
+		// unreadable spilled transactions are dropped, next pop moves on
+		transaction, ok, err := sub.queue.pop()
+		if err != nil {
+			j.logger.Error("queue failed, spilled transactions dropped", "network", j.network.Name, "address", address.Hex(), "err", err)
+			continue
+		}
 
-			if lastBlockNum == 0 {
-				lastBlockNum = currentBlockNum
-				continue
-			}
+		if !ok {
+			return
+		}
+
+		queueDepth.Set(float64(sub.queue.queueStats().Depth), j.network.Name, address.Hex())
+		sub.transactionsChan <- transaction
+
+		j.addPending(address, sub, transaction)
+	}
+}
+
+// addPending makes the delivered transaction wait for the confirmations.
+// Only delivered ones wait, the ones dropped by the queue never reached the
+// subscriber, so they aren't confirmed either.
+func (j *JSONRpcBasedObserver) addPending(address Address, sub *subscription, transaction Transaction) {
+	if j.confirmations <= 0 {
+		return
+	}
+
+	blockNum, err := hexutil.DecodeInt64(transaction.BlockNumber)
+	if err != nil {
+		j.logger.Warn("transaction block number is invalid, it won't be confirmed", "network", j.network.Name, "hash", transaction.Hash, "err", err)
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	// nobody waits for the confirmations of the unobserved address
+	if j.subscribers[address] != sub {
+		return
+	}
+
+	j.pending = append(j.pending, pendingTransaction{
+		address:     address.Hex(),
+		transaction: transaction,
+		blockNumber: blockNum,
+	})
+}
+
+// checkConfirmations fetches again blocks of the pending transactions which got
+// enough confirmations, if transaction is still there it is confirmed, otherwise
+// the block was reorganized.
+func (j *JSONRpcBasedObserver) checkConfirmations(ctx context.Context, currentBlockNum int64) {
+	j.mu.Lock()
+	var ready, pending []pendingTransaction
+	for _, p := range j.pending {
+		if currentBlockNum-p.blockNumber >= j.confirmations {
+			ready = append(ready, p)
+		} else {
+			pending = append(pending, p)
+		}
+	}
+	j.pending = pending
+	j.mu.Unlock()
+
+	blocks := make(map[int64][]Transaction)
+	for _, p := range ready {
+		transactions, ok := blocks[p.blockNumber]
+		if !ok {
+			var err error
+			transactions, err = j.fetchBlock(ctx, p.blockNumber)
+			if err != nil {
+				j.logger.Warn("get transactions for block failed", "network", j.network.Name, "block", p.blockNumber, "err", err)
+				j.emitEvent(SourceError{Err: fmt.Errorf("get transactions for block %d: %w", p.blockNumber, err), Since: time.Now(), Failures: 1})
+
+				// we will try again with the next block
+				j.mu.Lock()
+				j.pending = append(j.pending, p)
+				j.mu.Unlock()
 
-			// if there is no dif in block num it means there are no new transactions
-			dif := currentBlockNum - lastBlockNum
-			if dif == 0 {
 				continue
 			}
 
-			j.logger.Println("new block found, looking for transactions")
-
-			// for each new block after the last block we are fetching the transactions
-			// and then we are checking if there are any for given address
-			for i := range dif {
-				blockNum := lastBlockNum + i
-				transactions, err := j.apiWrapper.GetTransactionsForBlock(j.httpClient, fmt.Sprintf("%x", blockNum))
-				if err != nil {
-					j.logger.Printf("get transactions for block error: %s", err.Error())
-					continue
-				}
-
-				for _, transaction := range transactions {
-					// there is an transaction for a given address, we are sending it to chan
-					if strings.ToLower(transaction.To) == strings.ToLower(address) {
-						// we want to be sure that we are not going to send anything more on the closed channel
-						mu.Lock()
-						if closed {
-							mu.Unlock()
-							return
-						}
-
-						transactionsChan <- transaction
-
-						mu.Unlock()
-					}
-				}
-			}
+			blocks[p.blockNumber] = transactions
+		}
 
-			lastBlockNum = currentBlockNum
+		j.emitEvent(confirmationEvent(p, transactions, currentBlockNum-p.blockNumber))
+	}
+}
+
+// confirmationEvent returns TransactionConfirmed if transaction is still in the
+// same block, otherwise it returns Reorg.
+func confirmationEvent(p pendingTransaction, transactions []Transaction, confirmations int64) Event {
+	newBlockHash := ""
+	for _, transaction := range transactions {
+		newBlockHash = transaction.BlockHash
+
+		if transaction.Hash == p.transaction.Hash && transaction.BlockHash == p.transaction.BlockHash {
+			return TransactionConfirmed{
+				Address:       p.address,
+				Transaction:   p.transaction,
+				Confirmations: confirmations,
+			}
 		}
-	}()
+	}
 
-	return transactionsChan, nil
+	return Reorg{
+		Address:      p.address,
+		Transaction:  p.transaction,
+		BlockNumber:  p.blockNumber,
+		OldBlockHash: p.transaction.BlockHash,
+		NewBlockHash: newBlockHash,
+	}
 }
 
-func (j *JSONRpcBasedObserver) Close() error {
-	j.closeChan <- struct{}{}
+func (j *JSONRpcBasedObserver) currentBlock() (int64, error) {
+	num, err := j.apiWrapper.GetCurrentBlock(j.httpClient)
+	if err != nil {
+		return 0, err
+	}
 
-	return nil
+	n, err := hexutil.DecodeInt64(num)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number returned from api: %w", err)
+	}
+
+	return n, nil
+}
+
+func (j *JSONRpcBasedObserver) emitEvent(event Event) {
+	j.mu.Lock()
+	emit := j.emit
+	j.mu.Unlock()
+
+	if emit != nil {
+		emit(event)
+	}
+}
+
+func (j *JSONRpcBasedObserver) isClosing() bool {
+	select {
+	case <-j.closeChan:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeSubscribers closes all the queues, forward goroutines
+// close the channels once the queues are drained.
+func (j *JSONRpcBasedObserver) closeSubscribers() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.closed = true
+
+	// channels are closed by the forward goroutines once the queues are empty
+	for _, sub := range j.subscribers {
+		sub.queue.close()
+	}
 }
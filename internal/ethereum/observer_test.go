@@ -1,66 +1,139 @@
 package ethereum
 
 import (
-	"fmt"
+	"context"
 	"net/http"
 	"reflect"
+	"strings"
 	"testing"
+	"time"
+
 	"tw/internal/clogger"
+	"tw/internal/ethtest"
 )
 
 func TestJSONRpcBasedObserver_ObserveAddress_Then_GetTransactions(t *testing.T) {
-	currBlockNum := 0
+	node := ethtest.NewNode(Mainnet.ChainID)
+	defer node.Close()
 
-	var expectedTransactions []Transaction
+	observer := NewJSONRpcBasedObserver(http.DefaultClient, clogger.Logger, NewEthApiWrapper(node.URL()), Mainnet,
+		WithPollInterval(10*time.Millisecond),
+		WithStartBlock(1),
+	)
 
-	transactionNum := 0
+	var expected []string
+	for i := 0; i < 2; i++ {
+		// rpc returns lower case addresses, they have to match checksummed one
+		expected = append(expected, node.Send(ethtest.Transaction{From: "0x1", To: strings.ToLower(testAddress)}))
+		node.Send(ethtest.Transaction{From: "0x1", To: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"})
+		// replayed on the other chain
+		node.Send(ethtest.Transaction{From: "0x1", To: testAddress, ChainId: "0x2"})
+		node.Mine(1)
+	}
 
-	apiWrapper := &mockApiWrapper{
-		getCurrentBlockFunc: func(httpClient *http.Client) (string, error) {
-			currBlockNum++
+	transactionsChan, err := observer.ObserveAddress(testAddress)
+	if err != nil {
+		t.Fatalf("ObserveAddress() error = %v", err)
+	}
 
-			return fmt.Sprintf("%x", currBlockNum), nil
-		},
-		getTransactionsForBlockFunc: func(httpClient *http.Client, blockNum string) ([]Transaction, error) {
-			transactions := []Transaction{
-				{
-					From: "test",
-					To:   fmt.Sprintf("to%d", transactionNum+1),
-				},
-			}
+	var received []string
+	for len(received) < len(expected) {
+		select {
+		case transaction := <-transactionsChan:
+			received = append(received, transaction.Hash)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("received = %v, want %v", received, expected)
+		}
+	}
 
-			expectedTransactions = append(expectedTransactions, transactions...)
+	if err := observer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
 
-			transactionNum++
+	if _, ok := <-transactionsChan; ok {
+		t.Error("transactions channel wasn't closed")
+	}
 
-			return transactions, nil
-		},
+	if !reflect.DeepEqual(received, expected) {
+		t.Errorf("received = %v, want %v", received, expected)
 	}
+}
 
-	observer := JSONRpcBasedObserver{
-		httpClient: http.DefaultClient,
-		closeChan:  make(chan struct{}, 1),
-		logger:     clogger.ConsoleLogger,
-		apiWrapper: apiWrapper,
+func TestJSONRPCParser_EndToEnd(t *testing.T) {
+	tests := []struct {
+		name  string
+		reorg bool
+		want  reflect.Type
+	}{
+		{name: "confirmed", want: reflect.TypeOf(TransactionConfirmed{})},
+		{name: "reorganized", reorg: true, want: reflect.TypeOf(Reorg{})},
 	}
 
-	go func() {
-		for {
-			// let some transactions go through
-			if transactionNum >= 2 {
-				_ = observer.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := ethtest.NewNode(Mainnet.ChainID)
+			defer node.Close()
+
+			api := NewEthApiWrapper(node.URL())
+			observer := NewJSONRpcBasedObserver(http.DefaultClient, clogger.Logger, api, Mainnet,
+				WithPollInterval(10*time.Millisecond),
+				WithConfirmations(2),
+				WithStartBlock(1),
+			)
+
+			storage := &flushingStorage{}
+			jp := NewJSONRPCParser(observer, storage, api, http.DefaultClient, clogger.Logger)
+
+			events := make(chan Event, 10)
+			_ = jp.SubscribeFunc(testAddress, func(ctx context.Context, event Event) error {
+				events <- event
+				return nil
+			})
+
+			if err := jp.Start(); err != nil {
+				t.Fatalf("Start() error = %v", err)
 			}
-		}
-	}()
 
-	transactionsChan, _ := observer.ObserveAddress("test")
+			hash := node.Send(ethtest.Transaction{From: "0x1", To: testAddress})
+			node.Mine(1)
 
-	var receivedTransactions []Transaction
-	for transaction := range transactionsChan {
-		receivedTransactions = append(receivedTransactions, transaction)
+			event := waitEvent(t, events)
+			if observed, ok := event.(TransactionObserved); !ok || observed.Transaction.Hash != hash {
+				t.Fatalf("event = %#v, want TransactionObserved of %v", event, hash)
+			}
+
+			if tt.reorg {
+				node.Reorg(1)
+			}
+
+			node.Mine(2)
+
+			if event := waitEvent(t, events); reflect.TypeOf(event) != tt.want {
+				t.Errorf("event = %#v, want %v", event, tt.want)
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			if err := jp.Shutdown(ctx); err != nil {
+				t.Fatalf("Shutdown() error = %v", err)
+			}
+
+			if stored := storage.GetTransactionsForAddress(testAddress); len(stored) != 1 || stored[0].Hash != hash {
+				t.Errorf("stored = %v, want %v", stored, hash)
+			}
+		})
 	}
+}
+
+func waitEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
 
-	if !reflect.DeepEqual(receivedTransactions, expectedTransactions) {
-		t.Errorf("expected: %v, got: %v", expectedTransactions, receivedTransactions)
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event")
+		return nil
 	}
 }
@@ -0,0 +1,374 @@
+package ethereum
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+// OverflowPolicy decides what happens when subscriber queue is full.
+type OverflowPolicy int
+
+const (
+	// Block waits until subscriber takes transaction from the queue. Nothing
+	// is lost, but slow subscriber holds the processing of the blocks.
+	Block OverflowPolicy = iota
+	// DropOldest removes the oldest transaction from the queue.
+	DropOldest
+	// DropNewest drops the transaction that doesn't fit into the queue.
+	DropNewest
+	// SpillToDisk writes transactions that don't fit into the queue to the
+	// file, they are read back once subscriber catches up.
+	SpillToDisk
+)
+
+const defaultQueueSize = 256
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case Block:
+		return "block"
+	case DropOldest:
+		return "drop-oldest"
+	case DropNewest:
+		return "drop-newest"
+	case SpillToDisk:
+		return "spill-to-disk"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseOverflowPolicy parses policy name returned by OverflowPolicy.String.
+func ParseOverflowPolicy(name string) (OverflowPolicy, error) {
+	for _, policy := range []OverflowPolicy{Block, DropOldest, DropNewest, SpillToDisk} {
+		if policy.String() == name {
+			return policy, nil
+		}
+	}
+
+	return Block, fmt.Errorf("unknown overflow policy %q", name)
+}
+
+// QueueConfig configures queues between observer and its subscribers.
+type QueueConfig struct {
+	// Size is the number of transactions kept in memory for each subscriber.
+	Size int
+	// Policy decides what happens when the queue is full.
+	Policy OverflowPolicy
+	// SpillDir is the directory for the SpillToDisk files, empty means os.TempDir.
+	SpillDir string
+}
+
+// QueueStats are the counters of the subscriber queue.
+type QueueStats struct {
+	// Depth is the number of transactions waiting in the queue (including spilled ones).
+	Depth int
+	// Spilled is the number of transactions waiting in the spill file.
+	Spilled int
+	// Enqueued is the number of transactions put into the queue.
+	Enqueued uint64
+	// Dropped is the number of transactions dropped because the queue was full,
+	// or they couldn't be read back from the spill file.
+	Dropped uint64
+}
+
+// transactionQueue is FIFO queue of the transactions, which
+// handles overflow with the configured policy.
+type transactionQueue struct {
+	config QueueConfig
+
+	items   []Transaction
+	spill   *spillFile
+	stats   QueueStats
+	closed  bool
+	mu      sync.Mutex
+	changed chan struct{}
+
+	// onDrop is called with the number of dropped transactions, q.mu is locked
+	onDrop func(n int)
+}
+
+// errCorruptRecord is returned by spillFile.read for the line which isn't
+// transaction, the line is skipped so the next read moves on.
+var errCorruptRecord = errors.New("corrupt spilled transaction")
+
+func newTransactionQueue(config QueueConfig) *transactionQueue {
+	if config.Size < 1 {
+		config.Size = defaultQueueSize
+	}
+
+	return &transactionQueue{
+		config:  config,
+		changed: make(chan struct{}),
+	}
+}
+
+// push adds transaction to the queue. With Block policy it waits until there is
+// space in the queue, it returns false if queue was closed in the meantime.
+func (q *transactionQueue) push(transaction Transaction) (bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		if q.closed {
+			return false, nil
+		}
+
+		if len(q.items) < q.config.Size && q.stats.Spilled == 0 {
+			q.items = append(q.items, transaction)
+			q.stats.Enqueued++
+			q.notify()
+
+			return true, nil
+		}
+
+		switch q.config.Policy {
+		case DropOldest:
+			q.items = append(q.items[1:], transaction)
+			q.stats.Enqueued++
+			q.drop(1)
+			q.notify()
+
+			return true, nil
+		case DropNewest:
+			q.drop(1)
+
+			return false, nil
+		case SpillToDisk:
+			if err := q.spillTransaction(transaction); err != nil {
+				q.drop(1)
+
+				return false, fmt.Errorf("spill transaction to disk: %w", err)
+			}
+
+			q.stats.Enqueued++
+			q.notify()
+
+			return true, nil
+		default:
+			q.wait()
+		}
+	}
+}
+
+// pop takes the oldest transaction from the queue, it waits if queue is empty.
+// It returns false once queue is closed and there is nothing more in it.
+// Spilled transactions which can't be read back are dropped, pop returns
+// the error once and the next pop moves on.
+func (q *transactionQueue) pop() (Transaction, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.items) == 0 {
+		if q.stats.Spilled > 0 {
+			if err := q.unspill(); err != nil {
+				return Transaction{}, false, fmt.Errorf("read spilled transactions: %w", err)
+			}
+
+			continue
+		}
+
+		if q.closed {
+			return Transaction{}, false, nil
+		}
+
+		q.wait()
+	}
+
+	transaction := q.items[0]
+	q.items = q.items[1:]
+	q.notify()
+
+	return transaction, true, nil
+}
+
+// close wakes up everyone waiting for the queue, pop returns
+// what is left in the queue and then it returns false.
+func (q *transactionQueue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.closed = true
+	q.notify()
+}
+
+// release removes the spill file, it has to be called once nobody uses the queue.
+func (q *transactionQueue) release() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.spill == nil {
+		return nil
+	}
+
+	err := q.spill.remove()
+	q.spill = nil
+	q.stats.Spilled = 0
+
+	return err
+}
+
+func (q *transactionQueue) queueStats() QueueStats {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	stats := q.stats
+	stats.Depth = len(q.items) + q.stats.Spilled
+
+	return stats
+}
+
+// drop counts n dropped transactions, q.mu has to be locked.
+func (q *transactionQueue) drop(n int) {
+	q.stats.Dropped += uint64(n)
+
+	if q.onDrop != nil {
+		q.onDrop(n)
+	}
+}
+
+// wait unlocks the queue until it is changed, q.mu has to be locked.
+func (q *transactionQueue) wait() {
+	changed := q.changed
+
+	q.mu.Unlock()
+	<-changed
+	q.mu.Lock()
+}
+
+// notify wakes up everyone waiting for the queue change, q.mu has to be locked.
+func (q *transactionQueue) notify() {
+	close(q.changed)
+	q.changed = make(chan struct{})
+}
+
+func (q *transactionQueue) spillTransaction(transaction Transaction) error {
+	if q.spill == nil {
+		spill, err := newSpillFile(q.config.SpillDir)
+		if err != nil {
+			return err
+		}
+
+		q.spill = spill
+	}
+
+	if err := q.spill.write(transaction); err != nil {
+		return err
+	}
+
+	q.stats.Spilled++
+
+	return nil
+}
+
+// unspill moves spilled transactions back to memory, as many as fits. Corrupt
+// lines are skipped, when the file can't be read all the spilled transactions
+// are dropped and the file is removed, the next spill creates a new one.
+func (q *transactionQueue) unspill() error {
+	var corrupt error
+
+	for len(q.items) < q.config.Size && q.stats.Spilled > 0 {
+		transaction, err := q.spill.read()
+		if errors.Is(err, errCorruptRecord) {
+			q.stats.Spilled--
+			q.drop(1)
+			corrupt = errors.Join(corrupt, err)
+
+			continue
+		}
+
+		if err != nil {
+			q.drop(q.stats.Spilled)
+			q.stats.Spilled = 0
+			err = errors.Join(corrupt, err, q.spill.remove())
+			q.spill = nil
+
+			return err
+		}
+
+		q.items = append(q.items, transaction)
+		q.stats.Spilled--
+	}
+
+	// everything was read back, file can be reused from the beginning
+	if q.stats.Spilled == 0 {
+		return errors.Join(corrupt, q.spill.reset())
+	}
+
+	return corrupt
+}
+
+// spillFile is the json lines file, transactions are appended
+// at the end and read from the beginning.
+type spillFile struct {
+	file   *os.File
+	reader *bufio.Reader
+	offset int64
+}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	file, err := os.CreateTemp(dir, "tw-queue-*.jsonl")
+	if err != nil {
+		return nil, fmt.Errorf("create spill file: %w", err)
+	}
+
+	return &spillFile{
+		file: file,
+	}, nil
+}
+
+func (s *spillFile) write(transaction Transaction) error {
+	line, err := json.Marshal(transaction)
+	if err != nil {
+		return fmt.Errorf("json marshal transaction: %w", err)
+	}
+
+	if _, err := s.file.Seek(0, io.SeekEnd); err != nil {
+		return fmt.Errorf("seek spill file: %w", err)
+	}
+
+	_, err = s.file.Write(append(line, '\n'))
+
+	return err
+}
+
+func (s *spillFile) read() (Transaction, error) {
+	if _, err := s.file.Seek(s.offset, io.SeekStart); err != nil {
+		return Transaction{}, fmt.Errorf("seek spill file: %w", err)
+	}
+
+	if s.reader == nil {
+		s.reader = bufio.NewReader(s.file)
+	} else {
+		s.reader.Reset(s.file)
+	}
+
+	line, err := s.reader.ReadBytes('\n')
+	if err != nil {
+		return Transaction{}, fmt.Errorf("read spill file: %w", err)
+	}
+
+	s.offset += int64(len(line))
+
+	var transaction Transaction
+	if err := json.Unmarshal(line, &transaction); err != nil {
+		return Transaction{}, fmt.Errorf("%w: %w", errCorruptRecord, err)
+	}
+
+	return transaction, nil
+}
+
+func (s *spillFile) reset() error {
+	s.offset = 0
+
+	return s.file.Truncate(0)
+}
+
+func (s *spillFile) remove() error {
+	return errors.Join(s.file.Close(), os.Remove(s.file.Name()))
+}
@@ -0,0 +1,320 @@
+package ethereum
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"tw/internal/clogger"
+)
+
+func TestTransactionQueue_OverflowPolicies(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      OverflowPolicy
+		pushed      int
+		want        []string
+		wantDropped uint64
+	}{
+		{
+			name:        "drop oldest keeps the newest transactions",
+			policy:      DropOldest,
+			pushed:      5,
+			want:        []string{"0x3", "0x4", "0x5"},
+			wantDropped: 2,
+		},
+		{
+			name:        "drop newest keeps the oldest transactions",
+			policy:      DropNewest,
+			pushed:      5,
+			want:        []string{"0x1", "0x2", "0x3"},
+			wantDropped: 2,
+		},
+		{
+			name:   "spill to disk keeps all transactions in order",
+			policy: SpillToDisk,
+			pushed: 8,
+			want:   []string{"0x1", "0x2", "0x3", "0x4", "0x5", "0x6", "0x7", "0x8"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newTransactionQueue(QueueConfig{Size: 3, Policy: tt.policy, SpillDir: t.TempDir()})
+
+			for i := 1; i <= tt.pushed; i++ {
+				if _, err := queue.push(Transaction{Hash: fmt.Sprintf("0x%d", i)}); err != nil {
+					t.Fatalf("push() error = %v", err)
+				}
+			}
+
+			stats := queue.queueStats()
+			if stats.Dropped != tt.wantDropped {
+				t.Errorf("Dropped = %v, want %v", stats.Dropped, tt.wantDropped)
+			}
+
+			if stats.Depth != len(tt.want) {
+				t.Errorf("Depth = %v, want %v", stats.Depth, len(tt.want))
+			}
+
+			queue.close()
+
+			var got []string
+			for {
+				transaction, ok, err := queue.pop()
+				if err != nil {
+					t.Fatalf("pop() error = %v", err)
+				}
+
+				if !ok {
+					break
+				}
+
+				got = append(got, transaction.Hash)
+			}
+
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("popped = %v, want %v", got, tt.want)
+			}
+
+			if err := queue.release(); err != nil {
+				t.Errorf("release() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestTransactionQueue_UnreadableSpillIsDropped(t *testing.T) {
+	tests := []struct {
+		name string
+		// damage breaks the spill file with 0x2 and 0x3
+		damage      func(t *testing.T, spill *spillFile)
+		wantPopped  []string
+		wantDropped uint64
+	}{
+		{
+			name: "corrupt line is skipped",
+			damage: func(t *testing.T, spill *spillFile) {
+				if _, err := spill.file.WriteAt([]byte("x"), 0); err != nil {
+					t.Fatal(err)
+				}
+			},
+			wantPopped:  []string{"0x1", "0x3"},
+			wantDropped: 1,
+		},
+		{
+			name: "unreadable file is dropped",
+			damage: func(t *testing.T, spill *spillFile) {
+				_ = spill.file.Close()
+			},
+			wantPopped:  []string{"0x1"},
+			wantDropped: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queue := newTransactionQueue(QueueConfig{Size: 1, Policy: SpillToDisk, SpillDir: t.TempDir()})
+
+			var dropped int
+			queue.onDrop = func(n int) { dropped += n }
+
+			for _, hash := range []string{"0x1", "0x2", "0x3"} {
+				_, _ = queue.push(Transaction{Hash: hash})
+			}
+
+			tt.damage(t, queue.spill)
+			queue.close()
+
+			var popped []string
+			var errs int
+			// every pop has to move on, a few more than transactions means the busy loop
+			for i := 0; i < 10; i++ {
+				transaction, ok, err := queue.pop()
+				if err != nil {
+					errs++
+					continue
+				}
+
+				if !ok {
+					break
+				}
+
+				popped = append(popped, transaction.Hash)
+			}
+
+			if !reflect.DeepEqual(popped, tt.wantPopped) {
+				t.Errorf("popped = %v, want %v", popped, tt.wantPopped)
+			}
+
+			if errs != 1 {
+				t.Errorf("pop errors = %v, want 1", errs)
+			}
+
+			if stats := queue.queueStats(); stats.Dropped != tt.wantDropped || stats.Depth != 0 {
+				t.Errorf("stats = %+v, want Dropped %v and Depth 0", stats, tt.wantDropped)
+			}
+
+			if uint64(dropped) != tt.wantDropped {
+				t.Errorf("onDrop counted %v, want %v", dropped, tt.wantDropped)
+			}
+
+			_ = queue.release()
+		})
+	}
+}
+
+func TestTransactionQueue_SpillFileRemoved(t *testing.T) {
+	dir := t.TempDir()
+	queue := newTransactionQueue(QueueConfig{Size: 1, Policy: SpillToDisk, SpillDir: dir})
+
+	_, _ = queue.push(Transaction{Hash: "0x1"})
+	_, _ = queue.push(Transaction{Hash: "0x2"})
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Fatalf("spill files = %v, want 1", len(entries))
+	}
+
+	_ = queue.release()
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("spill files = %v, want 0 after release", len(entries))
+	}
+}
+
+func TestTransactionQueue_BlockWaitsForPop(t *testing.T) {
+	queue := newTransactionQueue(QueueConfig{Size: 1, Policy: Block})
+
+	_, _ = queue.push(Transaction{Hash: "0x1"})
+
+	pushed := make(chan struct{})
+	go func() {
+		_, _ = queue.push(Transaction{Hash: "0x2"})
+		close(pushed)
+	}()
+
+	select {
+	case <-pushed:
+		t.Fatal("push() didn't block on full queue")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	_, _, _ = queue.pop()
+
+	select {
+	case <-pushed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("push() didn't continue after pop")
+	}
+}
+
+func TestJSONRpcBasedObserver_SlowSubscriberDoesNotBlockOthers(t *testing.T) {
+	slowAddress := "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+
+	observer := &JSONRpcBasedObserver{
+		logger:      clogger.Logger,
+		queueConfig: QueueConfig{Size: 1, Policy: SpillToDisk, SpillDir: t.TempDir()},
+		closeChan:   make(chan struct{}),
+		apiWrapper: &mockApiWrapper{
+			getTransactionsForBlockFunc: func(httpClient *http.Client, blockNum string) ([]Transaction, error) {
+				return []Transaction{{Hash: "0x" + blockNum, To: slowAddress}, {Hash: "0x" + blockNum, To: testAddress}}, nil
+			},
+		},
+	}
+
+	// nobody reads the slow subscriber channel
+	_, _ = observer.ObserveAddress(slowAddress)
+	fastChan, _ := observer.ObserveAddress(testAddress)
+
+	received := make(chan int)
+	go func() {
+		n := 0
+		for range fastChan {
+			n++
+		}
+		received <- n
+	}()
+
+	observer.processBlocks(context.Background(), 0, 10)
+	observer.closeSubscribers()
+
+	select {
+	case n := <-received:
+		if n != 10 {
+			t.Errorf("fast subscriber received = %v, want 10", n)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("fast subscriber was blocked")
+	}
+
+	if depth := observer.QueueStats()[slowAddress].Depth; depth == 0 {
+		t.Errorf("slow subscriber queue depth = %v, want > 0", depth)
+	}
+}
+
+func TestJSONRpcBasedObserver_DroppedTransactionIsNotConfirmed(t *testing.T) {
+	block := []Transaction{
+		{Hash: "0xa", BlockNumber: "0x1", BlockHash: "0xb1", To: testAddress},
+		{Hash: "0xb", BlockNumber: "0x1", BlockHash: "0xb1", To: testAddress},
+		{Hash: "0xc", BlockNumber: "0x1", BlockHash: "0xb1", To: testAddress},
+	}
+
+	var confirmed []string
+	observer := &JSONRpcBasedObserver{
+		logger:        clogger.Logger,
+		confirmations: 1,
+		queueConfig:   QueueConfig{Size: 1, Policy: DropOldest},
+		closeChan:     make(chan struct{}),
+		emit: func(event Event) {
+			if event, ok := event.(TransactionConfirmed); ok {
+				confirmed = append(confirmed, event.Transaction.Hash)
+			}
+		},
+		apiWrapper: &mockApiWrapper{
+			getTransactionsForBlockFunc: func(httpClient *http.Client, blockNum string) ([]Transaction, error) {
+				if blockNum == "1" {
+					return block, nil
+				}
+
+				return nil, nil
+			},
+		},
+	}
+
+	transactionsChan, _ := observer.ObserveAddress(testAddress)
+	defer observer.Close()
+
+	// nobody reads the channel while the block is matched, the queue drops
+	observer.processBlocks(context.Background(), 0, 1)
+
+	done := make(chan []string)
+	go func() {
+		var received []string
+		for transaction := range transactionsChan {
+			received = append(received, transaction.Hash)
+		}
+		done <- received
+	}()
+
+	observer.closeSubscribers()
+
+	var received []string
+	select {
+	case received = <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transactions channel wasn't closed")
+	}
+
+	if dropped := len(block) - len(received); dropped == 0 {
+		t.Fatalf("received = %v, want some of them dropped", received)
+	}
+
+	observer.processBlocks(context.Background(), 1, 2)
+
+	if !reflect.DeepEqual(confirmed, received) {
+		t.Errorf("confirmed = %v, want the received ones %v", confirmed, received)
+	}
+}
@@ -0,0 +1,80 @@
+package ethereum
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const redacted = "xxxxx"
+
+// apiKeyPathSegment matches path segments that look like api keys,
+// e.g. https://mainnet.infura.io/v3/<api key>.
+var apiKeyPathSegment = regexp.MustCompile(`^[A-Za-z0-9_\-]{20,}$`)
+
+// RedactURL returns url with the secrets (password, query values and
+// api keys from path) redacted, so it can be safely logged.
+func RedactURL(u *url.URL) string {
+	if u == nil {
+		return ""
+	}
+
+	ru := *u
+
+	if _, ok := ru.User.Password(); ok {
+		ru.User = url.UserPassword(ru.User.Username(), redacted)
+	}
+
+	if ru.RawQuery != "" {
+		query := ru.Query()
+		for key := range query {
+			query.Set(key, redacted)
+		}
+
+		ru.RawQuery = query.Encode()
+	}
+
+	segments := strings.Split(ru.Path, "/")
+	for i, segment := range segments {
+		if apiKeyPathSegment.MatchString(segment) {
+			segments[i] = redacted
+		}
+	}
+
+	ru.Path = strings.Join(segments, "/")
+	ru.RawPath = ""
+
+	return ru.String()
+}
+
+// ParseHTTPURL parses the http or https url with the host. Errors don't
+// contain the url, because it can contain the secrets.
+func ParseHTTPURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, errors.New("invalid url")
+	}
+
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("scheme must be http or https, got %q", u.Scheme)
+	}
+
+	if u.Host == "" {
+		return nil, errors.New("host can't be empty")
+	}
+
+	return u, nil
+}
+
+// RedactURLError replaces url in the *url.Error returned by the http
+// client, because otherwise it would leak the secrets to the logs.
+func RedactURLError(err error, u *url.URL) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		urlErr.URL = RedactURL(u)
+	}
+
+	return err
+}
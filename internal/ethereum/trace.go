@@ -0,0 +1,48 @@
+package ethereum
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"tw/internal/tracing"
+)
+
+// names of the spans of the block processing stages: poll is the root, fetch
+// block and match are its children, persist is the child of match (trace goes
+// with the transaction from the observer to the parser) and notify, one for
+// each handler attempt, is the child of persist
+const (
+	spanPoll       = "poll"
+	spanFetchBlock = "fetch block"
+	spanMatch      = "match"
+	spanPersist    = "persist"
+	spanNotify     = "notify"
+)
+
+// attributes of the spans
+const (
+	attrNetwork      = attribute.Key("tw.network")
+	attrBlock        = attribute.Key("tw.block")
+	attrAddress      = attribute.Key("tw.address")
+	attrHash         = attribute.Key("tw.transaction.hash")
+	attrTransactions = attribute.Key("tw.transactions")
+	attrMatched      = attribute.Key("tw.matched")
+	attrAttempt      = attribute.Key("tw.attempt")
+)
+
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends the span, error marks it as failed.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,81 @@
+package ethereum
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+
+	"tw/internal/clogger"
+	"tw/internal/tracing"
+)
+
+func TestJSONRPCParser_Spans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	_ = tracing.SetupSync(exporter)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	apiWrapper := &mockApiWrapper{
+		getCurrentBlockFunc: func(httpClient *http.Client) (string, error) {
+			return "0x10", nil
+		},
+		getTransactionsForBlockFunc: func(httpClient *http.Client, blockNum string) ([]Transaction, error) {
+			return []Transaction{{Hash: "0x1", To: testAddress}, {Hash: "0x2", To: "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"}}, nil
+		},
+	}
+
+	observer := NewJSONRpcBasedObserver(nil, clogger.Logger, apiWrapper, Mainnet, WithConfirmations(0))
+	jp := NewJSONRPCParser(observer, &flushingStorage{}, apiWrapper, nil, clogger.Logger)
+
+	handled := make(chan struct{}, 1)
+	_ = jp.SubscribeFunc(testAddress, func(ctx context.Context, event Event) error {
+		select {
+		case handled <- struct{}{}:
+		default:
+		}
+
+		return nil
+	})
+
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler wasn't called")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_ = jp.Shutdown(ctx)
+
+	spans := make(map[string]tracetest.SpanStub)
+	for _, span := range exporter.GetSpans() {
+		if _, ok := spans[span.Name]; !ok {
+			spans[span.Name] = span
+		}
+	}
+
+	// every stage is the child of the previous one, except the fetch which is the sibling of the match
+	parents := map[string]string{
+		spanFetchBlock: spanPoll,
+		spanMatch:      spanPoll,
+		spanPersist:    spanMatch,
+		spanNotify:     spanPersist,
+	}
+
+	for name, parent := range parents {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("%s span wasn't exported", name)
+			continue
+		}
+
+		if span.Parent.SpanID() != spans[parent].SpanContext.SpanID() || span.SpanContext.TraceID() != spans[spanPoll].SpanContext.TraceID() {
+			t.Errorf("%s span parent = %v, want %s span %v", name, span.Parent.SpanID(), parent, spans[parent].SpanContext.SpanID())
+		}
+	}
+}
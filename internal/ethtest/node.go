@@ -0,0 +1,449 @@
+// Package ethtest is the fake ethereum node for the tests. It serves the
+// JSON-RPC methods the parser uses from the in-memory chain, which is
+// changed only by the test, so the tests are deterministic and offline.
+package ethtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"tw/internal/hexutil"
+)
+
+// Transaction is the transaction as the node returns it, empty hash, block
+// and chain id fields are filled in when it is mined.
+type Transaction struct {
+	BlockHash        string `json:"blockHash"`
+	BlockNumber      string `json:"blockNumber"`
+	From             string `json:"from"`
+	Hash             string `json:"hash"`
+	To               string `json:"to"`
+	TransactionIndex string `json:"transactionIndex"`
+	Value            string `json:"value"`
+	ChainId          string `json:"chainId"`
+}
+
+// genesisTimestamp is the time of block 0, 2024-01-01.
+const genesisTimestamp = 1704067200
+
+// Block is the mined block.
+type Block struct {
+	Number       int64
+	Hash         string
+	ParentHash   string
+	Timestamp    int64
+	Transactions []Transaction
+}
+
+// fault is the injected failure of the method.
+type fault struct {
+	method  string
+	times   int
+	status  int
+	code    int
+	message string
+}
+
+// Node is the fake node, it has the genesis block at the start. Zero value
+// is not usable, see NewNode.
+type Node struct {
+	server  *httptest.Server
+	chainID int64
+
+	mu       sync.Mutex
+	blocks   []Block
+	pending  []Transaction
+	forks    int
+	txs      int
+	faults   []fault
+	latency  time.Duration
+	limit    int
+	window   time.Duration
+	served   []time.Time
+	requests map[string]int
+}
+
+// NewNode starts the node of the chain, it has to be closed.
+func NewNode(chainID int64) *Node {
+	n := &Node{
+		chainID:  chainID,
+		requests: make(map[string]int),
+	}
+
+	n.blocks = []Block{{Hash: n.blockHash(0), Timestamp: genesisTimestamp}}
+	n.server = httptest.NewServer(http.HandlerFunc(n.serveHTTP))
+
+	return n
+}
+
+// URL returns the endpoint of the node.
+func (n *Node) URL() *url.URL {
+	u, _ := url.Parse(n.server.URL)
+
+	return u
+}
+
+// Close stops the node.
+func (n *Node) Close() {
+	n.server.Close()
+}
+
+// Head returns the number of the last block.
+func (n *Node) Head() int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return int64(len(n.blocks) - 1)
+}
+
+// Block returns the mined block.
+func (n *Node) Block(number int64) (Block, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if number < 0 || number >= int64(len(n.blocks)) {
+		return Block{}, false
+	}
+
+	return n.blocks[number], true
+}
+
+// Send adds the transaction to the pool, it is included in the next
+// mined block. It returns the hash of the transaction.
+func (n *Node) Send(transaction Transaction) string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if transaction.Hash == "" {
+		n.txs++
+		transaction.Hash = fmt.Sprintf("0x%064x", n.txs)
+	}
+
+	if transaction.ChainId == "" {
+		transaction.ChainId = fmt.Sprintf("0x%x", n.chainID)
+	}
+
+	if transaction.Value == "" {
+		transaction.Value = "0x0"
+	}
+
+	n.pending = append(n.pending, transaction)
+
+	return transaction.Hash
+}
+
+// Mine mines the blocks, the first one includes the pooled transactions.
+// It returns the new head.
+func (n *Node) Mine(blocks int) int64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for i := 0; i < blocks; i++ {
+		n.mine()
+	}
+
+	return int64(len(n.blocks) - 1)
+}
+
+// Reorg replaces the last depth blocks by the ones with the other hashes, the
+// new blocks are empty. It returns the transactions of the replaced blocks,
+// they can be sent again to include them in the other block.
+func (n *Node) Reorg(depth int) []Transaction {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	// genesis block stays
+	depth = min(depth, len(n.blocks)-1)
+	n.forks++
+
+	var dropped []Transaction
+	for _, block := range n.blocks[len(n.blocks)-depth:] {
+		for _, transaction := range block.Transactions {
+			transaction.BlockHash = ""
+			transaction.BlockNumber = ""
+			transaction.TransactionIndex = ""
+			dropped = append(dropped, transaction)
+		}
+	}
+
+	n.blocks = n.blocks[:len(n.blocks)-depth]
+
+	// pool isn't part of the reorganized blocks
+	pending := n.pending
+	n.pending = nil
+	for i := 0; i < depth; i++ {
+		n.mine()
+	}
+	n.pending = pending
+
+	return dropped
+}
+
+// FailHTTP makes the next times requests of the method fail with the http
+// status, empty method matches all of them.
+func (n *Node) FailHTTP(method string, times int, status int) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.faults = append(n.faults, fault{method: method, times: times, status: status})
+}
+
+// FailRPC makes the next times requests of the method return the JSON-RPC
+// error, empty method matches all of them.
+func (n *Node) FailRPC(method string, times int, code int, message string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.faults = append(n.faults, fault{method: method, times: times, code: code, message: message})
+}
+
+// SetLatency delays every response, zero disables it.
+func (n *Node) SetLatency(latency time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.latency = latency
+}
+
+// SetRateLimit makes node respond with 429 Too Many Requests to the requests
+// over the limit in the sliding window, zero limit disables it.
+func (n *Node) SetRateLimit(limit int, window time.Duration) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	n.limit = limit
+	n.window = window
+	n.served = nil
+}
+
+// Requests returns the number of the requests of the method, including
+// the failed ones. Empty method returns all of them.
+func (n *Node) Requests(method string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if method != "" {
+		return n.requests[method]
+	}
+
+	total := 0
+	for _, count := range n.requests {
+		total += count
+	}
+
+	return total
+}
+
+// mine appends the block with the pooled transactions, n.mu has to be locked.
+func (n *Node) mine() {
+	number := int64(len(n.blocks))
+	block := Block{
+		Number:     number,
+		Hash:       n.blockHash(number),
+		ParentHash: n.blocks[number-1].Hash,
+		// block every 12 seconds since the genesis
+		Timestamp: n.blocks[0].Timestamp + 12*number,
+	}
+
+	for i, transaction := range n.pending {
+		transaction.BlockHash = block.Hash
+		transaction.BlockNumber = fmt.Sprintf("0x%x", number)
+		transaction.TransactionIndex = fmt.Sprintf("0x%x", i)
+		block.Transactions = append(block.Transactions, transaction)
+	}
+
+	n.pending = nil
+	n.blocks = append(n.blocks, block)
+}
+
+// blockHash is unique for the block number and the fork.
+func (n *Node) blockHash(number int64) string {
+	return fmt.Sprintf("0x%032x%032x", n.forks, number)
+}
+
+type request struct {
+	ID     json.RawMessage   `json:"id"`
+	Method string            `json:"method"`
+	Params []json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type response struct {
+	ID      json.RawMessage `json:"id"`
+	JSONRpc string          `json:"jsonrpc"`
+	Result  any             `json:"result"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+func (n *Node) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	var req request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeResponse(w, response{Error: &rpcError{Code: -32700, Message: "parse error"}})
+		return
+	}
+
+	n.mu.Lock()
+	n.requests[req.Method]++
+	latency := n.latency
+	limited := n.rateLimited(time.Now())
+	f, failed := n.fault(req.Method)
+	n.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	switch {
+	case limited:
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	case failed && f.status != 0:
+		http.Error(w, http.StatusText(f.status), f.status)
+	case failed:
+		writeResponse(w, response{ID: req.ID, Error: &rpcError{Code: f.code, Message: f.message}})
+	default:
+		result, err := n.call(req.Method, req.Params)
+		writeResponse(w, response{ID: req.ID, Result: result, Error: err})
+	}
+}
+
+// rateLimited records the request and checks the limit, n.mu has to be locked.
+func (n *Node) rateLimited(now time.Time) bool {
+	if n.limit <= 0 {
+		return false
+	}
+
+	served := n.served[:0]
+	for _, at := range n.served {
+		if now.Sub(at) < n.window {
+			served = append(served, at)
+		}
+	}
+	n.served = served
+
+	if len(n.served) >= n.limit {
+		return true
+	}
+
+	n.served = append(n.served, now)
+
+	return false
+}
+
+// fault takes the first fault of the method, n.mu has to be locked.
+func (n *Node) fault(method string) (fault, bool) {
+	for i, f := range n.faults {
+		if f.method != "" && f.method != method {
+			continue
+		}
+
+		n.faults[i].times--
+		if n.faults[i].times <= 0 {
+			n.faults = append(n.faults[:i], n.faults[i+1:]...)
+		}
+
+		return f, true
+	}
+
+	return fault{}, false
+}
+
+// call executes the method, it returns the result or the error.
+func (n *Node) call(method string, params []json.RawMessage) (any, *rpcError) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	switch method {
+	case "eth_chainId":
+		return fmt.Sprintf("0x%x", n.chainID), nil
+	case "eth_blockNumber":
+		return fmt.Sprintf("0x%x", len(n.blocks)-1), nil
+	case "eth_getBlockByNumber":
+		if len(params) == 0 {
+			return nil, &rpcError{Code: -32602, Message: "missing block number"}
+		}
+
+		number, err := n.blockNumber(params[0])
+		if err != nil {
+			return nil, &rpcError{Code: -32602, Message: err.Error()}
+		}
+
+		if number < 0 || number >= int64(len(n.blocks)) {
+			// block which wasn't mined yet is null
+			return nil, nil
+		}
+
+		block := n.blocks[number]
+		transactions := block.Transactions
+		if transactions == nil {
+			transactions = []Transaction{}
+		}
+
+		return map[string]any{
+			"number":       fmt.Sprintf("0x%x", block.Number),
+			"hash":         block.Hash,
+			"parentHash":   block.ParentHash,
+			"timestamp":    fmt.Sprintf("0x%x", block.Timestamp),
+			"transactions": transactions,
+		}, nil
+	case "eth_getTransactionByHash":
+		var hash string
+		if len(params) == 0 || json.Unmarshal(params[0], &hash) != nil {
+			return nil, &rpcError{Code: -32602, Message: "invalid transaction hash"}
+		}
+
+		for _, block := range n.blocks {
+			for _, transaction := range block.Transactions {
+				if transaction.Hash == hash {
+					return transaction, nil
+				}
+			}
+		}
+
+		return nil, nil
+	default:
+		return nil, &rpcError{Code: -32601, Message: fmt.Sprintf("the method %s does not exist/is not available", method)}
+	}
+}
+
+// blockNumber parses the hex number or the tag of the block, n.mu has to be locked.
+func (n *Node) blockNumber(param json.RawMessage) (int64, error) {
+	var tag string
+	if err := json.Unmarshal(param, &tag); err != nil {
+		return 0, fmt.Errorf("invalid block number: %w", err)
+	}
+
+	switch tag {
+	case "latest", "safe", "finalized", "pending":
+		return int64(len(n.blocks) - 1), nil
+	case "earliest":
+		return 0, nil
+	}
+
+	// like the real nodes, leading zeros are rejected
+	number, err := hexutil.DecodeInt64(tag)
+	if err != nil {
+		return 0, fmt.Errorf("invalid block number: %w", err)
+	}
+
+	return number, nil
+}
+
+func writeResponse(w http.ResponseWriter, res response) {
+	res.JSONRpc = "2.0"
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(res)
+}
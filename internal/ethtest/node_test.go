@@ -0,0 +1,144 @@
+package ethtest_test
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"tw/internal/ethereum"
+	"tw/internal/ethtest"
+)
+
+func TestNode(t *testing.T) {
+	node := ethtest.NewNode(1)
+	defer node.Close()
+
+	api := ethereum.NewEthApiWrapper(node.URL())
+
+	chainID, err := api.GetChainID(http.DefaultClient)
+	if err != nil || chainID != "0x1" {
+		t.Fatalf("GetChainID() = %v, %v, want 0x1", chainID, err)
+	}
+
+	hash := node.Send(ethtest.Transaction{From: "0x1", To: "0x2"})
+	if head := node.Mine(3); head != 3 {
+		t.Errorf("Mine() = %v, want 3", head)
+	}
+
+	current, err := api.GetCurrentBlock(http.DefaultClient)
+	if err != nil || current != "0x3" {
+		t.Fatalf("GetCurrentBlock() = %v, %v, want 0x3", current, err)
+	}
+
+	transactions, err := api.GetTransactionsForBlock(http.DefaultClient, "1")
+	if err != nil {
+		t.Fatalf("GetTransactionsForBlock() error = %v", err)
+	}
+
+	block, _ := node.Block(1)
+	if len(transactions) != 1 || transactions[0].Hash != hash || transactions[0].BlockHash != block.Hash || transactions[0].ChainId != "0x1" {
+		t.Errorf("GetTransactionsForBlock() = %+v, want %v in block %v", transactions, hash, block.Hash)
+	}
+
+	// block which wasn't mined yet has no transactions
+	if transactions, err := api.GetTransactionsForBlock(http.DefaultClient, "10"); err != nil || len(transactions) != 0 {
+		t.Errorf("GetTransactionsForBlock() of future block = %v, %v, want none", transactions, err)
+	}
+}
+
+func TestNode_Reorg(t *testing.T) {
+	node := ethtest.NewNode(1)
+	defer node.Close()
+
+	hash := node.Send(ethtest.Transaction{To: "0x2"})
+	node.Mine(3)
+
+	old, _ := node.Block(1)
+
+	dropped := node.Reorg(3)
+	if len(dropped) != 1 || dropped[0].Hash != hash || dropped[0].BlockHash != "" {
+		t.Fatalf("Reorg() = %+v, want %v without block", dropped, hash)
+	}
+
+	if head := node.Head(); head != 3 {
+		t.Errorf("Head() = %v, want 3", head)
+	}
+
+	reorganized, _ := node.Block(1)
+	if reorganized.Hash == old.Hash || len(reorganized.Transactions) != 0 {
+		t.Errorf("Block(1) = %+v, want empty block with other hash than %v", reorganized, old.Hash)
+	}
+
+	parent, _ := node.Block(0)
+	if reorganized.ParentHash != parent.Hash {
+		t.Errorf("Block(1) parent = %v, want %v", reorganized.ParentHash, parent.Hash)
+	}
+}
+
+func TestNode_Faults(t *testing.T) {
+	tests := []struct {
+		name    string
+		setup   func(node *ethtest.Node)
+		wantErr []string
+	}{
+		{
+			name:    "http status once",
+			setup:   func(node *ethtest.Node) { node.FailHTTP("eth_blockNumber", 1, http.StatusBadGateway) },
+			wantErr: []string{"invalid response status code from api: 502", ""},
+		},
+		{
+			name:    "json rpc error of all methods",
+			setup:   func(node *ethtest.Node) { node.FailRPC("", 2, -32005, "limit exceeded") },
+			wantErr: []string{"json rpc error -32005: limit exceeded", "json rpc error -32005: limit exceeded", ""},
+		},
+		{
+			name:    "other method",
+			setup:   func(node *ethtest.Node) { node.FailHTTP("eth_chainId", 1, http.StatusBadGateway) },
+			wantErr: []string{""},
+		},
+		{
+			name:    "rate limit",
+			setup:   func(node *ethtest.Node) { node.SetRateLimit(1, time.Minute) },
+			wantErr: []string{"", "invalid response status code from api: 429", "invalid response status code from api: 429"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			node := ethtest.NewNode(1)
+			defer node.Close()
+
+			tt.setup(node)
+
+			api := ethereum.NewEthApiWrapper(node.URL())
+			for i, wantErr := range tt.wantErr {
+				_, err := api.GetCurrentBlock(http.DefaultClient)
+
+				gotErr := ""
+				if err != nil {
+					gotErr = err.Error()
+				}
+
+				if gotErr != wantErr {
+					t.Errorf("GetCurrentBlock() #%d error = %q, want %q", i+1, gotErr, wantErr)
+				}
+			}
+
+			if got := node.Requests("eth_blockNumber"); got != len(tt.wantErr) {
+				t.Errorf("Requests() = %v, want %v", got, len(tt.wantErr))
+			}
+		})
+	}
+}
+
+func TestNode_Latency(t *testing.T) {
+	node := ethtest.NewNode(1)
+	defer node.Close()
+
+	node.SetLatency(50 * time.Millisecond)
+
+	client := &http.Client{Timeout: 10 * time.Millisecond}
+	if _, err := ethereum.NewEthApiWrapper(node.URL()).GetCurrentBlock(client); err == nil {
+		t.Error("GetCurrentBlock() error = nil, want timeout")
+	}
+}
@@ -0,0 +1,167 @@
+package export
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"tw/internal/ethereum"
+	"tw/internal/hexutil"
+)
+
+// ErrUnknownColumn is returned by ParseColumns for the column which doesn't exist.
+var ErrUnknownColumn = errors.New("unknown column")
+
+// kind is the type of the column value.
+type kind int
+
+const (
+	// kindString values are strings, decimal wei too, they don't fit int64.
+	kindString kind = iota
+	kindInt
+	kindTime
+)
+
+// Column is the exported field of the transaction. Values are decoded from the
+// hex quantities, the empty ones are null.
+type Column struct {
+	Name string
+
+	kind  kind
+	value func(row ethereum.SerializableTransaction) (any, error)
+}
+
+// Columns are all the columns, in the default order.
+var Columns = []Column{
+	{Name: "address", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return text(r.Address), nil }},
+	{Name: "block_number", kind: kindInt, value: func(r ethereum.SerializableTransaction) (any, error) { return integer(r.BlockNumber) }},
+	{Name: "block_hash", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return text(r.BlockHash), nil }},
+	{Name: "time", kind: kindTime, value: func(r ethereum.SerializableTransaction) (any, error) { return blockTime(r.Transaction) }},
+	{Name: "transaction_index", kind: kindInt, value: func(r ethereum.SerializableTransaction) (any, error) { return integer(r.TransactionIndex) }},
+	{Name: "hash", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return text(r.Hash), nil }},
+	{Name: "from", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return text(r.From), nil }},
+	{Name: "to", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return text(r.To), nil }},
+	{Name: "value", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return decimal(r.Value) }},
+	{Name: "value_ether", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return ether(r.Value) }},
+	{Name: "gas", kind: kindInt, value: func(r ethereum.SerializableTransaction) (any, error) { return integer(r.Gas) }},
+	{Name: "gas_price", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return decimal(r.GasPrice) }},
+	{Name: "max_fee_per_gas", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return decimal(r.MaxFeePerGas) }},
+	{Name: "max_priority_fee_per_gas", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return decimal(r.MaxPriorityFeePerGas) }},
+	{Name: "nonce", kind: kindInt, value: func(r ethereum.SerializableTransaction) (any, error) { return integer(r.Nonce) }},
+	{Name: "type", kind: kindInt, value: func(r ethereum.SerializableTransaction) (any, error) { return integer(r.Type) }},
+	{Name: "chain_id", kind: kindInt, value: func(r ethereum.SerializableTransaction) (any, error) { return integer(r.ChainId) }},
+	{Name: "input", kind: kindString, value: func(r ethereum.SerializableTransaction) (any, error) { return text(r.Input), nil }},
+}
+
+// ParseColumns returns the columns of the names, all of them if there are none.
+func ParseColumns(names []string) ([]Column, error) {
+	if len(names) == 0 {
+		return Columns, nil
+	}
+
+	var columns []Column
+	for _, name := range names {
+		i := indexOf(name)
+		if i < 0 {
+			return nil, fmt.Errorf("%w %q", ErrUnknownColumn, name)
+		}
+
+		for _, column := range columns {
+			if column.Name == name {
+				return nil, fmt.Errorf("duplicate column %q", name)
+			}
+		}
+
+		columns = append(columns, Columns[i])
+	}
+
+	return columns, nil
+}
+
+func indexOf(name string) int {
+	for i, column := range Columns {
+		if column.Name == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// values returns the values of the row, in the order of the columns.
+func values(columns []Column, row ethereum.SerializableTransaction) ([]any, error) {
+	values := make([]any, len(columns))
+	for i, column := range columns {
+		value, err := column.value(row)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %s %s: %w", row.Hash, column.Name, err)
+		}
+
+		values[i] = value
+	}
+
+	return values, nil
+}
+
+func text(s string) any {
+	if s == "" {
+		return nil
+	}
+
+	return s
+}
+
+func integer(s string) (any, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	return hexutil.DecodeInt64(s)
+}
+
+func decimal(s string) (any, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	n, err := hexutil.DecodeBig(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return n.String(), nil
+}
+
+var weiPerEther = new(big.Int).Exp(big.NewInt(10), big.NewInt(18), nil)
+
+// ether converts wei to the exact decimal of ether, without the trailing zeros.
+func ether(s string) (any, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	wei, err := hexutil.DecodeBig(s)
+	if err != nil {
+		return nil, err
+	}
+
+	value := new(big.Rat).SetFrac(wei, weiPerEther).FloatString(18)
+	value = strings.TrimSuffix(strings.TrimRight(value, "0"), ".")
+
+	return value, nil
+}
+
+func blockTime(t ethereum.Transaction) (any, error) {
+	if t.BlockTimestamp == "" {
+		return nil, nil
+	}
+
+	seconds, err := hexutil.DecodeInt64(t.BlockTimestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	return time.Unix(seconds, 0).UTC(), nil
+}
@@ -0,0 +1,148 @@
+// Package export writes the stored transactions to CSV, JSON Lines and
+// Parquet files, for the spreadsheets and the warehouses.
+package export
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"tw/internal/ethereum"
+	"tw/internal/hexutil"
+)
+
+// ErrUnknownFormat is returned by NewWriter for the format which isn't supported.
+var ErrUnknownFormat = errors.New("unknown format")
+
+// ErrAddressesNotListed is returned by Export without the addresses, when
+// the storage can't list them.
+var ErrAddressesNotListed = errors.New("storage doesn't list its addresses")
+
+// Format of the exported file.
+type Format string
+
+const (
+	CSV     Format = "csv"
+	JSONL   Format = "jsonl"
+	Parquet Format = "parquet"
+)
+
+// Writer writes the rows of the export. Close writes what is buffered, it
+// doesn't close the underlying writer.
+type Writer interface {
+	Write(row ethereum.SerializableTransaction) error
+	Close() error
+}
+
+// NewWriter returns the writer of the format.
+func NewWriter(format Format, w io.Writer, columns []Column) (Writer, error) {
+	switch format {
+	case CSV:
+		return NewCSVWriter(w, columns), nil
+	case JSONL:
+		return NewJSONLWriter(w, columns), nil
+	case Parquet:
+		return NewParquetWriter(w, columns), nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrUnknownFormat, format)
+	}
+}
+
+// Filter selects the exported transactions. Blocks are inclusive and zero
+// ToBlock has no limit. Since is inclusive, Until exclusive, zero ones have
+// no limit; transactions without the block timestamp (stored before it was
+// kept) are skipped when any of them is set, Stats counts them.
+type Filter struct {
+	FromBlock int64
+	ToBlock   int64
+	Since     time.Time
+	Until     time.Time
+}
+
+// match checks if the transaction is selected by the filter.
+func (f Filter) match(t ethereum.Transaction) (bool, error) {
+	if f.FromBlock > 0 || f.ToBlock > 0 {
+		number, err := hexutil.DecodeInt64(t.BlockNumber)
+		if err != nil {
+			return false, fmt.Errorf("transaction %s block number: %w", t.Hash, err)
+		}
+
+		if number < f.FromBlock || (f.ToBlock > 0 && number > f.ToBlock) {
+			return false, nil
+		}
+	}
+
+	if !f.timed() {
+		return true, nil
+	}
+
+	// stored before the timestamp was kept, Export counts them
+	if t.BlockTimestamp == "" {
+		return false, nil
+	}
+
+	seconds, err := hexutil.DecodeInt64(t.BlockTimestamp)
+	if err != nil {
+		return false, fmt.Errorf("transaction %s block timestamp: %w", t.Hash, err)
+	}
+
+	blockTime := time.Unix(seconds, 0)
+
+	return !blockTime.Before(f.Since) && (f.Until.IsZero() || blockTime.Before(f.Until)), nil
+}
+
+// timed checks if the filter selects the block time.
+func (f Filter) timed() bool {
+	return !f.Since.IsZero() || !f.Until.IsZero()
+}
+
+// Stats are the numbers of the export.
+type Stats struct {
+	// Rows is the number of written rows.
+	Rows int
+	// WithoutTime is the number of transactions which were skipped, because
+	// the filter selects the time and they don't have the block timestamp.
+	WithoutTime int
+}
+
+// Export writes the transactions of the addresses which match the filter, in the
+// stored order. Without the addresses, all the addresses of the storage are
+// exported, if it is ethereum.AddressLister.
+func Export(storage ethereum.TransactionsStorage, addresses []string, filter Filter, writer Writer) (Stats, error) {
+	var stats Stats
+
+	if len(addresses) == 0 {
+		lister, ok := storage.(ethereum.AddressLister)
+		if !ok {
+			return stats, ErrAddressesNotListed
+		}
+
+		addresses = lister.Addresses()
+	}
+
+	for _, address := range addresses {
+		for _, transaction := range storage.GetTransactionsForAddress(address) {
+			ok, err := filter.match(transaction)
+			if err != nil {
+				return stats, err
+			}
+
+			if !ok {
+				if filter.timed() && transaction.BlockTimestamp == "" {
+					stats.WithoutTime++
+				}
+
+				continue
+			}
+
+			if err := writer.Write(ethereum.SerializableTransaction{Address: address, Transaction: transaction}); err != nil {
+				return stats, err
+			}
+
+			stats.Rows++
+		}
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,478 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"tw/internal/ethereum"
+	"tw/internal/hexutil"
+	"tw/internal/memory"
+)
+
+const (
+	firstAddress  = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+	secondAddress = "0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+)
+
+// testStorage has 3 transactions of the first address, in the blocks 1..3
+// 12 seconds apart, the last one without the timestamp, and one of the second.
+func testStorage() *memory.TransactionMemoryStorage {
+	storage := memory.NewMemoryTransactionStorage()
+	for _, transaction := range []ethereum.SerializableTransaction{
+		{Address: firstAddress, Transaction: ethereum.Transaction{Hash: "0xa1", BlockNumber: "0x1", BlockTimestamp: "0x65920080", Value: "0xde0b6b3a7640000", GasPrice: "0x3b9aca00"}},
+		{Address: firstAddress, Transaction: ethereum.Transaction{Hash: "0xa2", BlockNumber: "0x2", BlockTimestamp: "0x6592008c", Value: "0x0", To: firstAddress}},
+		{Address: firstAddress, Transaction: ethereum.Transaction{Hash: "0xa3", BlockNumber: "0x3", Value: "0x1"}},
+		{Address: secondAddress, Transaction: ethereum.Transaction{Hash: "0xb1", BlockNumber: "0x2", BlockTimestamp: "0x6592008c"}},
+	} {
+		_ = storage.SerializeTransaction(transaction)
+	}
+
+	return storage
+}
+
+func TestExport(t *testing.T) {
+	genesis := time.Unix(0x65920080, 0)
+
+	tests := []struct {
+		name      string
+		addresses []string
+		filter    Filter
+		want      []string
+		// withoutTime were skipped, because they don't have the timestamp
+		withoutTime int
+	}{
+		{name: "all addresses", want: []string{"0xb1", "0xa1", "0xa2", "0xa3"}},
+		{name: "address", addresses: []string{firstAddress}, want: []string{"0xa1", "0xa2", "0xa3"}},
+		{name: "from block", addresses: []string{firstAddress}, filter: Filter{FromBlock: 2}, want: []string{"0xa2", "0xa3"}},
+		{name: "block range", filter: Filter{FromBlock: 2, ToBlock: 2}, want: []string{"0xb1", "0xa2"}},
+		{name: "since", addresses: []string{firstAddress}, filter: Filter{Since: genesis.Add(time.Second)}, want: []string{"0xa2"}, withoutTime: 1},
+		{name: "until", addresses: []string{firstAddress}, filter: Filter{Until: genesis.Add(12 * time.Second)}, want: []string{"0xa1"}, withoutTime: 1},
+		{name: "nothing", addresses: []string{firstAddress}, filter: Filter{FromBlock: 4}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			columns, _ := ParseColumns([]string{"hash"})
+			writer := NewCSVWriter(&out, columns)
+
+			stats, err := Export(testStorage(), tt.addresses, tt.filter, writer)
+			if err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+
+			_ = writer.Close()
+
+			got := strings.Fields(out.String())[1:]
+			if !reflect.DeepEqual(got, append([]string{}, tt.want...)) {
+				t.Errorf("Export() rows = %v, want %v", got, tt.want)
+			}
+
+			if want := (Stats{Rows: len(tt.want), WithoutTime: tt.withoutTime}); stats != want {
+				t.Errorf("Export() = %+v, want %+v", stats, want)
+			}
+		})
+	}
+}
+
+func TestExport_Errors(t *testing.T) {
+	if _, err := Export(&listlessStorage{}, nil, Filter{}, NewJSONLWriter(&bytes.Buffer{}, Columns)); !errors.Is(err, ErrAddressesNotListed) {
+		t.Errorf("Export() error = %v, want %v", err, ErrAddressesNotListed)
+	}
+
+	storage := memory.NewMemoryTransactionStorage()
+	_ = storage.SerializeTransaction(ethereum.SerializableTransaction{Address: firstAddress, Transaction: ethereum.Transaction{Hash: "0xa1", BlockNumber: "0x1", Value: "0x01"}})
+
+	if _, err := Export(storage, nil, Filter{}, NewJSONLWriter(&bytes.Buffer{}, Columns)); !errors.Is(err, hexutil.ErrLeadingZero) {
+		t.Errorf("Export() error = %v, want %v", err, hexutil.ErrLeadingZero)
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns([]string{"hash", "value_ether"})
+	if err != nil || len(columns) != 2 || columns[0].Name != "hash" || columns[1].Name != "value_ether" {
+		t.Errorf("ParseColumns() = %v, %v", columns, err)
+	}
+
+	if _, err := ParseColumns([]string{"hash", "fee"}); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("ParseColumns() error = %v, want %v", err, ErrUnknownColumn)
+	}
+
+	if _, err := ParseColumns([]string{"hash", "hash"}); err == nil {
+		t.Error("ParseColumns() of duplicate columns error = nil")
+	}
+}
+
+func TestWriters(t *testing.T) {
+	columns, _ := ParseColumns([]string{"hash", "block_number", "time", "to", "value", "value_ether", "gas_price"})
+
+	tests := []struct {
+		format Format
+		want   string
+	}{
+		{
+			format: CSV,
+			want: `hash,block_number,time,to,value,value_ether,gas_price
+0xa1,1,2024-01-01T00:00:00Z,,1000000000000000000,1,1000000000
+0xa2,2,2024-01-01T00:00:12Z,0xdAC17F958D2ee523a2206206994597C13D831ec7,0,0,
+0xa3,3,,,1,0.000000000000000001,
+`,
+		},
+		{
+			format: JSONL,
+			want: `{"hash":"0xa1","block_number":1,"time":"2024-01-01T00:00:00Z","to":null,"value":"1000000000000000000","value_ether":"1","gas_price":"1000000000"}
+{"hash":"0xa2","block_number":2,"time":"2024-01-01T00:00:12Z","to":"0xdAC17F958D2ee523a2206206994597C13D831ec7","value":"0","value_ether":"0","gas_price":null}
+{"hash":"0xa3","block_number":3,"time":null,"to":null,"value":"1","value_ether":"0.000000000000000001","gas_price":null}
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.format), func(t *testing.T) {
+			var out bytes.Buffer
+			writer, err := NewWriter(tt.format, &out, columns)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := Export(testStorage(), []string{firstAddress}, Filter{}, writer); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+
+			if err := writer.Close(); err != nil {
+				t.Fatalf("Close() error = %v", err)
+			}
+
+			if out.String() != tt.want {
+				t.Errorf("output = %s, want %s", out.String(), tt.want)
+			}
+		})
+	}
+
+	if _, err := NewWriter("xlsx", &bytes.Buffer{}, columns); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("NewWriter() error = %v, want %v", err, ErrUnknownFormat)
+	}
+}
+
+func TestParquetWriter(t *testing.T) {
+	columns, _ := ParseColumns([]string{"hash", "block_number", "time", "to"})
+
+	for _, rows := range []int{0, 4} {
+		var out bytes.Buffer
+		writer := NewParquetWriter(&out, columns)
+		// the last row group isn't full
+		writer.groupRows = 3
+
+		if rows > 0 {
+			if _, err := Export(testStorage(), nil, Filter{}, writer); err != nil {
+				t.Fatalf("Export() error = %v", err)
+			}
+		}
+
+		if err := writer.Close(); err != nil {
+			t.Fatalf("Close() error = %v", err)
+		}
+
+		got, err := readParquet(out.Bytes())
+		if err != nil {
+			t.Fatalf("readParquet() error = %v", err)
+		}
+
+		want := map[string][]any{
+			"hash":         {"0xb1", "0xa1", "0xa2", "0xa3"},
+			"block_number": {int64(2), int64(1), int64(2), int64(3)},
+			"time":         {int64(0x6592008c * 1000), int64(0x65920080 * 1000), int64(0x6592008c * 1000), nil},
+			"to":           {nil, nil, firstAddress, nil},
+		}
+		if rows == 0 {
+			want = map[string][]any{"hash": nil, "block_number": nil, "time": nil, "to": nil}
+		}
+
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("parquet of %d rows = %v, want %v", rows, got, want)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite the golden files")
+
+// goldenParquet is the parquet of testStorage with all the columns, in two row
+// groups. TestParquetWriter_RealReaders checks it with pyarrow and DuckDB.
+const goldenParquet = "testdata/transactions.parquet"
+
+func TestParquetWriter_Golden(t *testing.T) {
+	var out bytes.Buffer
+	writer := NewParquetWriter(&out, Columns)
+	writer.groupRows = 3
+
+	if _, err := Export(testStorage(), nil, Filter{}, writer); err != nil {
+		t.Fatalf("Export() error = %v", err)
+	}
+
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	if *update {
+		if err := os.WriteFile(goldenParquet, out.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(goldenParquet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(out.Bytes(), want) {
+		t.Errorf("parquet differs from %s, check the new one with the real readers and rewrite it with -update", goldenParquet)
+	}
+}
+
+// pyarrowRows prints the rows of the parquet file as json, times as unix millis.
+const pyarrowRows = `
+import json, sys
+import pyarrow.parquet as pq
+
+rows = pq.read_table(sys.argv[1]).to_pylist()
+for row in rows:
+    for name, value in row.items():
+        if hasattr(value, "timestamp"):
+            row[name] = round(value.timestamp() * 1000)
+
+print(json.dumps(rows))
+`
+
+// TestParquetWriter_RealReaders reads the golden file with the readers the
+// warehouses use, the ones which aren't installed are skipped.
+func TestParquetWriter_RealReaders(t *testing.T) {
+	readers := []struct {
+		name    string
+		check   []string
+		command []string
+	}{
+		{
+			name:    "pyarrow",
+			check:   []string{"python3", "-c", "import pyarrow.parquet"},
+			command: []string{"python3", "-c", pyarrowRows, goldenParquet},
+		},
+		{
+			name:    "duckdb",
+			check:   []string{"duckdb", "-version"},
+			command: []string{"duckdb", "-json", "-c", fmt.Sprintf(`select * replace (epoch_ms("time") as "time") from read_parquet('%s')`, goldenParquet)},
+		},
+	}
+
+	// rows of the golden file, in the order of the addresses
+	storage := testStorage()
+
+	var rows []map[string]any
+	for _, address := range storage.Addresses() {
+		for _, transaction := range storage.GetTransactionsForAddress(address) {
+			values, err := values(Columns, ethereum.SerializableTransaction{Address: address, Transaction: transaction})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			row := make(map[string]any)
+			for i, value := range values {
+				if v, ok := value.(time.Time); ok {
+					value = v.UnixMilli()
+				}
+
+				row[Columns[i].Name] = value
+			}
+
+			rows = append(rows, row)
+		}
+	}
+
+	var want []map[string]any
+	encoded, _ := json.Marshal(rows)
+	_ = json.Unmarshal(encoded, &want)
+
+	for _, reader := range readers {
+		t.Run(reader.name, func(t *testing.T) {
+			if err := exec.Command(reader.check[0], reader.check[1:]...).Run(); err != nil {
+				t.Skipf("%s isn't installed", reader.name)
+			}
+
+			output, err := exec.Command(reader.command[0], reader.command[1:]...).Output()
+			if err != nil {
+				t.Fatalf("%s error = %v", reader.name, err)
+			}
+
+			var got []map[string]any
+			if err := json.Unmarshal(output, &got); err != nil {
+				t.Fatalf("%s output = %s, error = %v", reader.name, output, err)
+			}
+
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%s rows = %v, want %v", reader.name, got, want)
+			}
+		})
+	}
+}
+
+type listlessStorage struct{}
+
+func (listlessStorage) SerializeTransaction(ethereum.SerializableTransaction) error { return nil }
+
+func (listlessStorage) GetTransactionsForAddress(string) []ethereum.Transaction { return nil }
+
+// readParquet reads the columns of the file written by ParquetWriter, by the
+// footer and the page headers, so the metadata is checked too.
+func readParquet(file []byte) (map[string][]any, error) {
+	if len(file) < 12 || string(file[:4]) != parquetMagic || string(file[len(file)-4:]) != parquetMagic {
+		return nil, errors.New("no magic")
+	}
+
+	size := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footer := &thriftReader{b: file[len(file)-8-size : len(file)-8]}
+	metadata := footer.readStruct()
+
+	if len(footer.b) != 0 || metadata[1] != int64(1) {
+		return nil, errors.New("invalid footer")
+	}
+
+	schema := metadata[2].([]any)
+	columns := make(map[string][]any)
+	names := make([]string, 0, len(schema)-1)
+	for _, element := range schema[1:] {
+		name := element.(map[int16]any)[4].(string)
+		names = append(names, name)
+		columns[name] = nil
+	}
+
+	var rows int64
+	for _, group := range metadata[4].([]any) {
+		group := group.(map[int16]any)
+		rows += group[3].(int64)
+
+		for i, chunk := range group[1].([]any) {
+			meta := chunk.(map[int16]any)[3].(map[int16]any)
+
+			page := &thriftReader{b: file[meta[9].(int64):]}
+			header := page.readStruct()
+			values := page.b[:header[3].(int64)]
+
+			if int64(len(file)-len(page.b))-meta[9].(int64)+header[3].(int64) != meta[7].(int64) {
+				return nil, errors.New("invalid column chunk size")
+			}
+
+			// definition levels, then the values of the defined ones
+			levels := values[4 : 4+binary.LittleEndian.Uint32(values)]
+			values = values[4+len(levels):]
+
+			for len(levels) > 0 {
+				run, n := binary.Uvarint(levels)
+				defined := levels[n] == 1
+				levels = levels[n+1:]
+
+				for j := uint64(0); j < run>>1; j++ {
+					if !defined {
+						columns[names[i]] = append(columns[names[i]], nil)
+						continue
+					}
+
+					if schema[i+1].(map[int16]any)[1] == int64(parquetInt64) {
+						columns[names[i]] = append(columns[names[i]], int64(binary.LittleEndian.Uint64(values)))
+						values = values[8:]
+
+						continue
+					}
+
+					length := binary.LittleEndian.Uint32(values)
+					columns[names[i]] = append(columns[names[i]], string(values[4:4+length]))
+					values = values[4+length:]
+				}
+			}
+		}
+	}
+
+	if metadata[3] != rows {
+		return nil, errors.New("invalid number of rows")
+	}
+
+	return columns, nil
+}
+
+// thriftReader decodes the compact protocol, structs are maps of the field ids.
+type thriftReader struct {
+	b []byte
+}
+
+func (r *thriftReader) readStruct() map[int16]any {
+	fields := make(map[int16]any)
+
+	var id int16
+	for {
+		header := r.b[0]
+		r.b = r.b[1:]
+
+		if header == 0 {
+			return fields
+		}
+
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, n := binary.Varint(r.b)
+			id = int16(v)
+			r.b = r.b[n:]
+		}
+
+		fields[id] = r.value(header & 0x0f)
+	}
+}
+
+func (r *thriftReader) value(valueType byte) any {
+	switch valueType {
+	case thriftTrue:
+		return true
+	case thriftFalse:
+		return false
+	case thriftI32, thriftI64:
+		v, n := binary.Varint(r.b)
+		r.b = r.b[n:]
+
+		return v
+	case thriftBinary:
+		length, n := binary.Uvarint(r.b)
+		s := string(r.b[n : n+int(length)])
+		r.b = r.b[n+int(length):]
+
+		return s
+	case thriftList:
+		header := r.b[0]
+		r.b = r.b[1:]
+
+		size := uint64(header >> 4)
+		if size == 15 {
+			var n int
+			size, n = binary.Uvarint(r.b)
+			r.b = r.b[n:]
+		}
+
+		elements := make([]any, size)
+		for i := range elements {
+			elements[i] = r.value(header & 0x0f)
+		}
+
+		return elements
+	case thriftStruct:
+		return r.readStruct()
+	default:
+		panic("unexpected thrift type")
+	}
+}
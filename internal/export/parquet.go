@@ -0,0 +1,303 @@
+package export
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+// Parquet format values, see parquet.thrift of the format.
+const (
+	parquetMagic = "PAR1"
+
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetOptional = 1
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetPlain = 0
+	parquetRLE   = 3
+
+	parquetDataPage = 0
+)
+
+// Row group is written when it has that many rows or bytes of values.
+const (
+	parquetRowGroupRows  = 64 << 10
+	parquetRowGroupBytes = 64 << 20
+)
+
+// ParquetWriter writes the parquet file: uncompressed row groups with one plain
+// encoded data page per column. All the columns are optional, integers are
+// INT64, time is INT64 TIMESTAMP(MILLIS, UTC) and the rest are UTF8 strings.
+// Rows are buffered until the row group is full, Close writes the last one
+// and the footer.
+type ParquetWriter struct {
+	w       io.Writer
+	columns []Column
+	chunks  []parquetChunk
+	rows    int64
+	// groupRows is the size of the row group, tests make it smaller
+	groupRows int64
+
+	offset int64
+	groups []parquetRowGroup
+	err    error
+}
+
+var _ Writer = (*ParquetWriter)(nil)
+
+// parquetChunk is the buffered column of the row group.
+type parquetChunk struct {
+	defined []bool
+	values  bytes.Buffer
+}
+
+// parquetRowGroup is the written row group, for the footer.
+type parquetRowGroup struct {
+	rows    int64
+	offsets []int64
+	sizes   []int64
+}
+
+// NewParquetWriter writes the columns to w.
+func NewParquetWriter(w io.Writer, columns []Column) *ParquetWriter {
+	return &ParquetWriter{w: w, columns: columns, chunks: make([]parquetChunk, len(columns)), groupRows: parquetRowGroupRows}
+}
+
+// Write buffers the row, the row group is written when it is full.
+func (p *ParquetWriter) Write(row ethereum.SerializableTransaction) error {
+	if p.err != nil {
+		return p.err
+	}
+
+	values, err := values(p.columns, row)
+	if err != nil {
+		return err
+	}
+
+	buffered := 0
+	for i, value := range values {
+		chunk := &p.chunks[i]
+		chunk.defined = append(chunk.defined, value != nil)
+
+		switch v := value.(type) {
+		case string:
+			_ = binary.Write(&chunk.values, binary.LittleEndian, uint32(len(v)))
+			chunk.values.WriteString(v)
+		case int64:
+			_ = binary.Write(&chunk.values, binary.LittleEndian, v)
+		case time.Time:
+			_ = binary.Write(&chunk.values, binary.LittleEndian, v.UnixMilli())
+		}
+
+		buffered += chunk.values.Len()
+	}
+
+	p.rows++
+
+	if p.rows >= p.groupRows || buffered >= parquetRowGroupBytes {
+		p.writeRowGroup()
+	}
+
+	return p.err
+}
+
+// Close writes the buffered row group and the footer.
+func (p *ParquetWriter) Close() error {
+	p.writeRowGroup()
+	p.writeMagic()
+
+	footer := p.footer()
+	p.write(footer)
+	p.write(binary.LittleEndian.AppendUint32(nil, uint32(len(footer))))
+	p.write([]byte(parquetMagic))
+
+	return p.err
+}
+
+// writeRowGroup writes the page of every column.
+func (p *ParquetWriter) writeRowGroup() {
+	if p.rows == 0 {
+		return
+	}
+
+	p.writeMagic()
+
+	group := parquetRowGroup{rows: p.rows}
+	for i := range p.chunks {
+		chunk := &p.chunks[i]
+
+		page := definitionLevels(chunk.defined)
+		page = append(page, chunk.values.Bytes()...)
+
+		header := pageHeader(len(page), p.rows)
+
+		group.offsets = append(group.offsets, p.offset)
+		group.sizes = append(group.sizes, int64(len(header)+len(page)))
+
+		p.write(header)
+		p.write(page)
+
+		chunk.defined = chunk.defined[:0]
+		chunk.values.Reset()
+	}
+
+	p.groups = append(p.groups, group)
+	p.rows = 0
+}
+
+func (p *ParquetWriter) writeMagic() {
+	if p.offset == 0 {
+		p.write([]byte(parquetMagic))
+	}
+}
+
+func (p *ParquetWriter) write(b []byte) {
+	if p.err != nil {
+		return
+	}
+
+	n, err := p.w.Write(b)
+	p.offset += int64(n)
+	p.err = err
+}
+
+// definitionLevels encodes 1 for the defined value and 0 for null with the
+// RLE hybrid encoding, as runs of the same levels, prefixed by the length.
+func definitionLevels(defined []bool) []byte {
+	levels := make([]byte, 4)
+	for i := 0; i < len(defined); {
+		j := i
+		for j < len(defined) && defined[j] == defined[i] {
+			j++
+		}
+
+		levels = binary.AppendUvarint(levels, uint64(j-i)<<1)
+		if defined[i] {
+			levels = append(levels, 1)
+		} else {
+			levels = append(levels, 0)
+		}
+
+		i = j
+	}
+
+	binary.LittleEndian.PutUint32(levels, uint32(len(levels)-4))
+
+	return levels
+}
+
+// pageHeader is the header of the data page of size bytes.
+func pageHeader(size int, rows int64) []byte {
+	t := newThrift()
+	t.i32(1, parquetDataPage)
+	t.i32(2, int32(size))
+	t.i32(3, int32(size))
+	t.beginStruct(5)
+	t.i32(1, int32(rows))
+	t.i32(2, parquetPlain)
+	t.i32(3, parquetRLE)
+	t.i32(4, parquetRLE)
+	t.endStruct()
+
+	return t.end()
+}
+
+// footer is the file metadata: the schema and the row groups.
+func (p *ParquetWriter) footer() []byte {
+	var total int64
+	for _, group := range p.groups {
+		total += group.rows
+	}
+
+	t := newThrift()
+	t.i32(1, 1)
+
+	t.beginList(2, thriftStruct, len(p.columns)+1)
+	t.beginElement()
+	t.binary(4, "schema")
+	t.i32(5, int32(len(p.columns)))
+	t.endStruct()
+
+	for _, column := range p.columns {
+		t.beginElement()
+		t.i32(1, column.parquetType())
+		t.i32(3, parquetOptional)
+		t.binary(4, column.Name)
+
+		switch column.kind {
+		case kindString:
+			t.i32(6, parquetUTF8)
+			t.beginStruct(10)
+			t.beginStruct(1)
+			t.endStruct()
+			t.endStruct()
+		case kindTime:
+			t.i32(6, parquetTimestampMillis)
+			t.beginStruct(10)
+			t.beginStruct(8)
+			t.boolean(1, true)
+			t.beginStruct(2)
+			t.beginStruct(1)
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+			t.endStruct()
+		}
+
+		t.endStruct()
+	}
+
+	t.i64(3, total)
+
+	t.beginList(4, thriftStruct, len(p.groups))
+	for _, group := range p.groups {
+		var size int64
+
+		t.beginElement()
+		t.beginList(1, thriftStruct, len(p.columns))
+		for i, column := range p.columns {
+			size += group.sizes[i]
+
+			t.beginElement()
+			t.i64(2, group.offsets[i])
+			t.beginStruct(3)
+			t.i32(1, column.parquetType())
+			t.beginList(2, thriftI32, 2)
+			t.listI32(parquetPlain)
+			t.listI32(parquetRLE)
+			t.beginList(3, thriftBinary, 1)
+			t.listBinary(column.Name)
+			t.i32(4, 0)
+			t.i64(5, group.rows)
+			t.i64(6, group.sizes[i])
+			t.i64(7, group.sizes[i])
+			t.i64(9, group.offsets[i])
+			t.endStruct()
+			t.endStruct()
+		}
+
+		t.i64(2, size)
+		t.i64(3, group.rows)
+		t.endStruct()
+	}
+
+	t.binary(6, "tw")
+
+	return t.end()
+}
+
+func (c Column) parquetType() int32 {
+	if c.kind == kindString {
+		return parquetByteArray
+	}
+
+	return parquetInt64
+}
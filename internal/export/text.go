@@ -0,0 +1,138 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+// CSVWriter writes the header, then a record per row. Nulls are empty and
+// times are RFC 3339, so the spreadsheets can read them.
+type CSVWriter struct {
+	w       *csv.Writer
+	columns []Column
+	header  bool
+}
+
+var _ Writer = (*CSVWriter)(nil)
+
+// NewCSVWriter writes the columns to w.
+func NewCSVWriter(w io.Writer, columns []Column) *CSVWriter {
+	return &CSVWriter{w: csv.NewWriter(w), columns: columns}
+}
+
+// Write writes the record of the row, the header before the first one.
+func (c *CSVWriter) Write(row ethereum.SerializableTransaction) error {
+	values, err := values(c.columns, row)
+	if err != nil {
+		return err
+	}
+
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	record := make([]string, len(values))
+	for i, value := range values {
+		switch v := value.(type) {
+		case string:
+			record[i] = v
+		case int64:
+			record[i] = strconv.FormatInt(v, 10)
+		case time.Time:
+			record[i] = v.Format(time.RFC3339)
+		}
+	}
+
+	return c.w.Write(record)
+}
+
+// Close writes the header, if there were no rows, and flushes the records.
+func (c *CSVWriter) Close() error {
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	c.w.Flush()
+
+	return c.w.Error()
+}
+
+func (c *CSVWriter) writeHeader() error {
+	if c.header {
+		return nil
+	}
+
+	c.header = true
+
+	names := make([]string, len(c.columns))
+	for i, column := range c.columns {
+		names[i] = column.Name
+	}
+
+	return c.w.Write(names)
+}
+
+// JSONLWriter writes a json object per row, with the keys in the order of the
+// columns. Integers are numbers, wei and ether are strings, so they stay exact.
+type JSONLWriter struct {
+	w       *bufio.Writer
+	columns []Column
+	line    bytes.Buffer
+}
+
+var _ Writer = (*JSONLWriter)(nil)
+
+// NewJSONLWriter writes the columns to w.
+func NewJSONLWriter(w io.Writer, columns []Column) *JSONLWriter {
+	return &JSONLWriter{w: bufio.NewWriter(w), columns: columns}
+}
+
+// Write writes the line of the row.
+func (j *JSONLWriter) Write(row ethereum.SerializableTransaction) error {
+	values, err := values(j.columns, row)
+	if err != nil {
+		return err
+	}
+
+	j.line.Reset()
+	j.line.WriteByte('{')
+
+	for i, value := range values {
+		if i > 0 {
+			j.line.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(j.columns[i].Name)
+		j.line.Write(key)
+		j.line.WriteByte(':')
+
+		if t, ok := value.(time.Time); ok {
+			value = t.Format(time.RFC3339)
+		}
+
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+
+		j.line.Write(encoded)
+	}
+
+	j.line.WriteString("}\n")
+
+	_, err = j.w.Write(j.line.Bytes())
+
+	return err
+}
+
+// Close flushes the lines.
+func (j *JSONLWriter) Close() error {
+	return j.w.Flush()
+}
@@ -0,0 +1,103 @@
+package export
+
+import "encoding/binary"
+
+// Thrift compact protocol types.
+const (
+	thriftTrue   = 1
+	thriftFalse  = 2
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thrift encodes the struct with the thrift compact protocol, which is used by
+// the parquet metadata. Fields have to be written in the order of their ids.
+type thrift struct {
+	b []byte
+	// last are the ids of the last fields of the nested structs
+	last []int16
+}
+
+func newThrift() *thrift {
+	return &thrift{last: []int16{0}}
+}
+
+// end ends the top struct and returns the encoding.
+func (t *thrift) end() []byte {
+	return append(t.b, 0)
+}
+
+func (t *thrift) field(id int16, fieldType byte) {
+	last := &t.last[len(t.last)-1]
+	if delta := id - *last; delta > 0 && delta <= 15 {
+		t.b = append(t.b, byte(delta)<<4|fieldType)
+	} else {
+		t.b = append(t.b, fieldType)
+		t.b = binary.AppendVarint(t.b, int64(id))
+	}
+
+	*last = id
+}
+
+func (t *thrift) i32(id int16, v int32) {
+	t.field(id, thriftI32)
+	t.b = binary.AppendVarint(t.b, int64(v))
+}
+
+func (t *thrift) i64(id int16, v int64) {
+	t.field(id, thriftI64)
+	t.b = binary.AppendVarint(t.b, v)
+}
+
+func (t *thrift) binary(id int16, s string) {
+	t.field(id, thriftBinary)
+	t.listBinary(s)
+}
+
+func (t *thrift) boolean(id int16, v bool) {
+	if v {
+		t.field(id, thriftTrue)
+	} else {
+		t.field(id, thriftFalse)
+	}
+}
+
+func (t *thrift) beginStruct(id int16) {
+	t.field(id, thriftStruct)
+	t.beginElement()
+}
+
+// beginElement begins the struct which is the element of the list.
+func (t *thrift) beginElement() {
+	t.last = append(t.last, 0)
+}
+
+func (t *thrift) endStruct() {
+	t.b = append(t.b, 0)
+	t.last = t.last[:len(t.last)-1]
+}
+
+// beginList writes the header of the list of size elements,
+// they are written after it with list*, or as structs.
+func (t *thrift) beginList(id int16, elementType byte, size int) {
+	t.field(id, thriftList)
+
+	if size < 15 {
+		t.b = append(t.b, byte(size)<<4|elementType)
+	} else {
+		t.b = append(t.b, 0xf0|elementType)
+		t.b = binary.AppendUvarint(t.b, uint64(size))
+	}
+}
+
+func (t *thrift) listI32(v int32) {
+	t.b = binary.AppendVarint(t.b, int64(v))
+}
+
+func (t *thrift) listBinary(s string) {
+	t.b = binary.AppendUvarint(t.b, uint64(len(s)))
+	t.b = append(t.b, s...)
+}
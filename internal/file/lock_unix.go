@@ -0,0 +1,27 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package file
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"tw/internal/ethereum"
+)
+
+// lockFile takes the exclusive flock of the file, the kernel
+// releases it when the file is closed or the process dies.
+func lockFile(file *os.File) error {
+	err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX|syscall.LOCK_NB)
+	if errors.Is(err, syscall.EWOULDBLOCK) {
+		return fmt.Errorf("%w: %s", ethereum.ErrStorageLocked, file.Name())
+	}
+
+	if err != nil {
+		return fmt.Errorf("lock storage file: %w", err)
+	}
+
+	return nil
+}
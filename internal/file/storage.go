@@ -0,0 +1,224 @@
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+	"tw/internal/metrics"
+)
+
+var fileSize = metrics.Default.NewGauge("tw_storage_file_size_bytes", "Size of the storage file, updated when it is flushed.", "path")
+
+// TransactionFileStorage keeps transactions in memory and appends them
+// to the json lines file, which is read back when storage is opened.
+// Every write reaches the file right away, so history and export of the
+// other processes see it, Flush syncs the file to the disk.
+type TransactionFileStorage struct {
+	transactionsMap map[string][]ethereum.Transaction
+
+	path   string
+	file   *os.File
+	writer *bufio.Writer
+
+	// repair ends the last line of the file which was cut off by the crash
+	// in the middle of the append, it is done before the next write
+	repair func() error
+
+	mu sync.Mutex
+}
+
+var _ ethereum.TransactionsStorage = (*TransactionFileStorage)(nil)
+var _ ethereum.Flusher = (*TransactionFileStorage)(nil)
+var _ ethereum.AddressLister = (*TransactionFileStorage)(nil)
+var _ ethereum.Locker = (*TransactionFileStorage)(nil)
+var _ io.Closer = (*TransactionFileStorage)(nil)
+
+// NewFileTransactionStorage opens (or creates) the file and loads transactions from it.
+func NewFileTransactionStorage(path string) (*TransactionFileStorage, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("open storage file: %w", err)
+	}
+
+	fs := &TransactionFileStorage{
+		transactionsMap: make(map[string][]ethereum.Transaction),
+		path:            path,
+		file:            file,
+		writer:          bufio.NewWriter(file),
+	}
+
+	if err := fs.load(); err != nil {
+		_ = file.Close()
+
+		return nil, fmt.Errorf("load storage file %s: %w", path, err)
+	}
+
+	fs.updateSize()
+
+	return fs, nil
+}
+
+// SerializeTransaction stores transaction in memory and appends it to the file.
+func (fs *TransactionFileStorage) SerializeTransaction(serializableTransaction ethereum.SerializableTransaction) error {
+	line, err := json.Marshal(serializableTransaction)
+	if err != nil {
+		return fmt.Errorf("json marshal transaction: %w", err)
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.repairLastLine(); err != nil {
+		return err
+	}
+
+	if _, err := fs.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write storage file: %w", err)
+	}
+
+	if err := fs.writer.Flush(); err != nil {
+		return fmt.Errorf("write storage file: %w", err)
+	}
+
+	fs.transactionsMap[serializableTransaction.Address] = append(fs.transactionsMap[serializableTransaction.Address], serializableTransaction.Transaction)
+
+	return nil
+}
+
+func (fs *TransactionFileStorage) GetTransactionsForAddress(address string) []ethereum.Transaction {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.transactionsMap[address]
+}
+
+// Addresses returns the stored addresses, sorted.
+func (fs *TransactionFileStorage) Addresses() []string {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	addresses := make([]string, 0, len(fs.transactionsMap))
+	for address := range fs.transactionsMap {
+		addresses = append(addresses, address)
+	}
+
+	sort.Strings(addresses)
+
+	return addresses
+}
+
+// Flush syncs the written transactions to the disk.
+func (fs *TransactionFileStorage) Flush() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.writer.Flush(); err != nil {
+		return fmt.Errorf("flush storage file: %w", err)
+	}
+
+	defer fs.updateSize()
+
+	return fs.file.Sync()
+}
+
+// updateSize sets the size metric of the file.
+func (fs *TransactionFileStorage) updateSize() {
+	if info, err := fs.file.Stat(); err == nil {
+		fileSize.Set(float64(info.Size()), fs.path)
+	}
+}
+
+// Lock locks the file until it is closed, so that the other processes
+// can't write to it. Readers don't lock it, history and export can
+// read the file of the running daemon. The last line cut off by the
+// crash is repaired once the file is locked.
+func (fs *TransactionFileStorage) Lock() error {
+	if err := lockFile(fs.file); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.repairLastLine()
+}
+
+// repairLastLine repairs the last line cut off by the crash, if there is one,
+// fs.mu has to be locked. Readers don't repair it, the writer may be appending it.
+func (fs *TransactionFileStorage) repairLastLine() error {
+	if fs.repair == nil {
+		return nil
+	}
+
+	if err := fs.repair(); err != nil {
+		return fmt.Errorf("repair last line of storage file: %w", err)
+	}
+
+	fs.repair = nil
+
+	return nil
+}
+
+// Close flushes and closes the file, which releases the lock.
+func (fs *TransactionFileStorage) Close() error {
+	return errors.Join(fs.Flush(), fs.file.Close())
+}
+
+// load reads the transactions of the file. Corrupt lines are errors, except
+// the last one without the newline: the crash in the middle of the append
+// cuts it off, so it is skipped and cut off the file before the next write.
+func (fs *TransactionFileStorage) load() error {
+	info, err := fs.file.Stat()
+	if err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(fs.file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	var offset int64
+	for scanner.Scan() {
+		line++
+
+		start := offset
+		offset += int64(len(scanner.Bytes())) + 1
+		// scanner drops the newline, the last line may not have one
+		last := offset > info.Size()
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var transaction ethereum.SerializableTransaction
+		if err := json.Unmarshal(scanner.Bytes(), &transaction); err != nil {
+			if !last {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+
+			clogger.Logger.Warn("storage file ends with incomplete line, it is skipped", "path", fs.path, "line", line, "err", err)
+			fs.repair = func() error { return fs.file.Truncate(start) }
+
+			break
+		}
+
+		if last {
+			fs.repair = func() error {
+				_, err := fs.file.Write([]byte{'\n'})
+				return err
+			}
+		}
+
+		fs.transactionsMap[transaction.Address] = append(fs.transactionsMap[transaction.Address], transaction.Transaction)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,165 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"tw/internal/ethereum"
+)
+
+func TestTransactionFileStorage_Reopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.jsonl")
+
+	storage, err := NewFileTransactionStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileTransactionStorage() error = %v", err)
+	}
+
+	want := []ethereum.Transaction{{Hash: "0x1"}, {Hash: "0x2"}}
+	for _, transaction := range want {
+		_ = storage.SerializeTransaction(ethereum.SerializableTransaction{Address: "addr", Transaction: transaction})
+	}
+
+	if err := storage.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	reopened, err := NewFileTransactionStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileTransactionStorage() error = %v", err)
+	}
+	defer reopened.Close()
+
+	if got := reopened.GetTransactionsForAddress("addr"); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetTransactionsForAddress() = %v, want %v", got, want)
+	}
+}
+
+func TestTransactionFileStorage_WriteReachesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "transactions.jsonl")
+
+	storage, err := NewFileTransactionStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileTransactionStorage() error = %v", err)
+	}
+	defer storage.Close()
+
+	if err := storage.SerializeTransaction(ethereum.SerializableTransaction{Address: "addr", Transaction: ethereum.Transaction{Hash: "0x1"}}); err != nil {
+		t.Fatalf("SerializeTransaction() error = %v", err)
+	}
+
+	// without Flush or Close, like history reading the file of the running daemon
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(content), `"0x1"`) {
+		t.Errorf("file = %q, want the written transaction", content)
+	}
+}
+
+func TestTransactionFileStorage_Lock(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("storage file isn't locked on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "transactions.jsonl")
+
+	daemon, err := NewFileTransactionStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileTransactionStorage() error = %v", err)
+	}
+
+	if err := daemon.Lock(); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+
+	reader, err := NewFileTransactionStorage(path)
+	if err != nil {
+		t.Fatalf("NewFileTransactionStorage() of the locked file error = %v, want readers to open it", err)
+	}
+	defer reader.Close()
+
+	if err := reader.Lock(); !errors.Is(err, ethereum.ErrStorageLocked) {
+		t.Fatalf("Lock() of the locked file error = %v, want %v", err, ethereum.ErrStorageLocked)
+	}
+
+	_ = daemon.Close()
+
+	if err := reader.Lock(); err != nil {
+		t.Errorf("Lock() after close error = %v", err)
+	}
+}
+
+func TestTransactionFileStorage_CutOffLastLine(t *testing.T) {
+	line := func(hash string) string {
+		return `{"address":"addr","transaction":{"hash":"` + hash + `"}}` + "\n"
+	}
+
+	tests := []struct {
+		name    string
+		content string
+		want    []string
+		wantErr bool
+	}{
+		{name: "cut off line is skipped", content: line("0x1") + line("0x2") + `{"address":"addr","transaction":{"ha`, want: []string{"0x1", "0x2"}},
+		{name: "line without newline is kept", content: line("0x1") + strings.TrimSuffix(line("0x2"), "\n"), want: []string{"0x1", "0x2"}},
+		{name: "corrupt line in the middle", content: line("0x1") + `{"address":"addr","transaction":{"ha` + "\n" + line("0x2"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "transactions.jsonl")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			storage, err := NewFileTransactionStorage(path)
+			if tt.wantErr {
+				if err == nil {
+					_ = storage.Close()
+					t.Fatal("NewFileTransactionStorage() error = nil, want the corrupt line")
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("NewFileTransactionStorage() error = %v", err)
+			}
+
+			if got := hashes(storage.GetTransactionsForAddress("addr")); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetTransactionsForAddress() = %v, want %v", got, tt.want)
+			}
+
+			// the next write repairs the last line
+			_ = storage.SerializeTransaction(ethereum.SerializableTransaction{Address: "addr", Transaction: ethereum.Transaction{Hash: "0x3"}})
+			_ = storage.Close()
+
+			reopened, err := NewFileTransactionStorage(path)
+			if err != nil {
+				t.Fatalf("NewFileTransactionStorage() after the write error = %v", err)
+			}
+			defer reopened.Close()
+
+			if got, want := hashes(reopened.GetTransactionsForAddress("addr")), append(tt.want, "0x3"); !reflect.DeepEqual(got, want) {
+				t.Errorf("GetTransactionsForAddress() after the write = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func hashes(transactions []ethereum.Transaction) []string {
+	var hashes []string
+	for _, transaction := range transactions {
+		hashes = append(hashes, transaction.Hash)
+	}
+
+	return hashes
+}
@@ -0,0 +1,254 @@
+// Package grpcapi serves the parsers over gRPC, see twpb/tw.proto.
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"tw/internal/ethereum"
+	"tw/internal/grpcapi/twpb"
+	"tw/internal/service"
+)
+
+// Server implements the Parser service, one parser for each network.
+type Server struct {
+	twpb.UnimplementedParserServer
+
+	networks       map[string]service.Backend
+	defaultNetwork string
+	feed           *service.Feed
+	logger         *slog.Logger
+}
+
+var _ twpb.ParserServer = (*Server)(nil)
+
+// NewServer creates a new instance of Server, default network has to be one of
+// the networks. Feed can be shared with the http api, nil means a new one.
+func NewServer(networks map[string]service.Backend, defaultNetwork string, feed *service.Feed, logger *slog.Logger) (*Server, error) {
+	if _, ok := networks[defaultNetwork]; !ok {
+		return nil, fmt.Errorf("default network %q is not one of the networks", defaultNetwork)
+	}
+
+	if feed == nil {
+		feed = service.NewFeed()
+	}
+
+	return &Server{
+		networks:       networks,
+		defaultNetwork: defaultNetwork,
+		feed:           feed,
+		logger:         logger,
+	}, nil
+}
+
+// Register registers the service to the grpc server.
+func (s *Server) Register(registrar grpc.ServiceRegistrar) {
+	twpb.RegisterParserServer(registrar, s)
+}
+
+func (s *Server) GetCurrentBlock(ctx context.Context, req *twpb.GetCurrentBlockRequest) (*twpb.GetCurrentBlockResponse, error) {
+	backend, network, err := s.backend(req.GetNetwork())
+	if err != nil {
+		return nil, err
+	}
+
+	// parser returns 0 when api doesn't respond
+	block := backend.GetCurrentBlock()
+	if block == 0 {
+		return nil, status.Error(codes.Unavailable, "current block is not available")
+	}
+
+	return &twpb.GetCurrentBlockResponse{Network: network, Block: int64(block)}, nil
+}
+
+func (s *Server) Subscribe(ctx context.Context, req *twpb.SubscribeRequest) (*twpb.SubscribeResponse, error) {
+	backend, network, err := s.backend(req.GetNetwork())
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := ethereum.NormalizeAddress(req.GetAddress())
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+
+	res := &twpb.SubscribeResponse{Network: network, Address: address}
+	for _, subscribed := range backend.Subscriptions() {
+		if subscribed == address {
+			res.AlreadySubscribed = true
+		}
+	}
+
+	if err := backend.SubscribeAddress(address); err != nil {
+		return nil, s.statusError(err)
+	}
+
+	return res, nil
+}
+
+func (s *Server) Unsubscribe(ctx context.Context, req *twpb.UnsubscribeRequest) (*twpb.UnsubscribeResponse, error) {
+	backend, _, err := s.backend(req.GetNetwork())
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := ethereum.NormalizeAddress(req.GetAddress())
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+
+	if err := backend.Unsubscribe(address); err != nil {
+		return nil, s.statusError(err)
+	}
+
+	return &twpb.UnsubscribeResponse{}, nil
+}
+
+func (s *Server) GetTransactions(ctx context.Context, req *twpb.GetTransactionsRequest) (*twpb.GetTransactionsResponse, error) {
+	backend, network, err := s.backend(req.GetNetwork())
+	if err != nil {
+		return nil, err
+	}
+
+	address, err := ethereum.NormalizeAddress(req.GetAddress())
+	if err != nil {
+		return nil, s.statusError(err)
+	}
+
+	filter := service.NewFilter()
+	filter.From = req.GetFrom()
+	filter.Offset = int(req.GetOffset())
+
+	if req.Limit != 0 {
+		filter.Limit = int(req.GetLimit())
+	}
+
+	if req.FromBlock != nil {
+		filter.FromBlock = req.GetFromBlock()
+	}
+
+	if req.ToBlock != nil {
+		filter.ToBlock = req.GetToBlock()
+	}
+
+	if err := filter.Validate(); err != nil {
+		return nil, s.statusError(err)
+	}
+
+	page := service.GetPage(backend, address, filter)
+
+	res := &twpb.GetTransactionsResponse{
+		Network:      network,
+		Address:      address,
+		Transactions: make([]*twpb.Transaction, len(page.Transactions)),
+		Total:        int32(page.Total),
+	}
+
+	for i, transaction := range page.Transactions {
+		res.Transactions[i] = toProto(transaction)
+	}
+
+	if page.NextOffset != nil {
+		nextOffset := int32(*page.NextOffset)
+		res.NextOffset = &nextOffset
+	}
+
+	return res, nil
+}
+
+func (s *Server) Watch(req *twpb.WatchRequest, stream grpc.ServerStreamingServer[twpb.WatchResponse]) error {
+	backend, network, err := s.backend(req.GetNetwork())
+	if err != nil {
+		return err
+	}
+
+	feedReq, err := service.NewFeedRequest(network, backend, req.GetAddresses(), req.GetLastEventId())
+	if err != nil {
+		return s.statusError(err)
+	}
+
+	// feed only follows the subscribed addresses, Watch subscribes them
+	for _, address := range feedReq.Addresses {
+		if err := backend.SubscribeAddress(address); err != nil {
+			return s.statusError(err)
+		}
+	}
+
+	client, err := s.feed.Subscribe(feedReq)
+	if err != nil {
+		return s.statusError(err)
+	}
+	defer s.feed.Unsubscribe(feedReq, client)
+
+	send := func(event service.FeedEvent) error {
+		return stream.Send(&twpb.WatchResponse{
+			Id:          event.ID,
+			Address:     event.Address,
+			Transaction: toProto(event.Transaction),
+		})
+	}
+
+	// grpc has its own keepalive, so there is no ping
+	err = service.Stream(stream.Context(), feedReq, client, send, nil)
+	if errors.Is(err, service.ErrSlowClient) {
+		return status.Error(codes.ResourceExhausted, "client is not keeping up, resume with last_event_id")
+	}
+
+	return err
+}
+
+// backend returns parser of the network, or the default one when network is empty.
+func (s *Server) backend(network string) (service.Backend, string, error) {
+	if network == "" {
+		network = s.defaultNetwork
+	}
+
+	backend, ok := s.networks[network]
+	if !ok {
+		return nil, "", status.Errorf(codes.NotFound, "unknown network %q", network)
+	}
+
+	return backend, network, nil
+}
+
+// statusError maps the errors to the grpc status, like the http api does with the http status.
+func (s *Server) statusError(err error) error {
+	switch {
+	case errors.Is(err, ethereum.ErrInvalidAddress), errors.Is(err, ethereum.ErrInvalidAddressChecksum),
+		errors.Is(err, service.ErrInvalidFilter):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, ethereum.ErrNotSubscribed):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ethereum.ErrClosed):
+		return status.Error(codes.Unavailable, "parser is shutting down")
+	default:
+		s.logger.Error("grpc api error", "err", err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+func toProto(t ethereum.Transaction) *twpb.Transaction {
+	return &twpb.Transaction{
+		Hash:                 t.Hash,
+		BlockHash:            t.BlockHash,
+		BlockNumber:          t.BlockNumber,
+		TransactionIndex:     t.TransactionIndex,
+		From:                 t.From,
+		To:                   t.To,
+		Value:                t.Value,
+		Input:                t.Input,
+		Nonce:                t.Nonce,
+		Gas:                  t.Gas,
+		GasPrice:             t.GasPrice,
+		MaxFeePerGas:         t.MaxFeePerGas,
+		MaxPriorityFeePerGas: t.MaxPriorityFeePerGas,
+		Type:                 t.Type,
+		ChainId:              t.ChainId,
+	}
+}
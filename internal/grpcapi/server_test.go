@@ -0,0 +1,210 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+	"tw/internal/grpcapi/twpb"
+	"tw/internal/service"
+)
+
+const testAddress = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+
+type mockBackend struct {
+	getCurrentBlockFunc func() int
+	addHandlerFunc      func(address string, handler ethereum.EventHandler) (func(), <-chan struct{}, error)
+	unsubscribeFunc     func(address string) error
+	getTransactionsFunc func(address string) []ethereum.Transaction
+}
+
+func (m *mockBackend) GetCurrentBlock() int {
+	if m.getCurrentBlockFunc != nil {
+		return m.getCurrentBlockFunc()
+	}
+
+	return 0
+}
+
+func (m *mockBackend) SubscribeAddress(address string) error {
+	return nil
+}
+
+func (m *mockBackend) AddHandler(address string, handler ethereum.EventHandler, opts ...ethereum.HandlerOption) (func(), <-chan struct{}, error) {
+	if m.addHandlerFunc != nil {
+		return m.addHandlerFunc(address, handler)
+	}
+
+	return func() {}, nil, nil
+}
+
+func (m *mockBackend) Unsubscribe(address string) error {
+	if m.unsubscribeFunc != nil {
+		return m.unsubscribeFunc(address)
+	}
+
+	return nil
+}
+
+func (m *mockBackend) Subscriptions() []string {
+	return nil
+}
+
+func (m *mockBackend) GetTransactions(address string) []ethereum.Transaction {
+	if m.getTransactionsFunc != nil {
+		return m.getTransactionsFunc(address)
+	}
+
+	return nil
+}
+
+// newClient serves the backend over the in-process connection.
+func newClient(t *testing.T, backend service.Backend) twpb.ParserClient {
+	server, err := NewServer(map[string]service.Backend{"mainnet": backend}, "mainnet", nil, clogger.Logger)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := grpc.NewServer()
+	server.Register(grpcServer)
+
+	go func() { _ = grpcServer.Serve(listener) }()
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("grpc.NewClient() error = %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return twpb.NewParserClient(conn)
+}
+
+func TestServer(t *testing.T) {
+	client := newClient(t, &mockBackend{
+		getCurrentBlockFunc: func() int { return 16 },
+		unsubscribeFunc: func(address string) error {
+			return ethereum.ErrNotSubscribed
+		},
+		getTransactionsFunc: func(address string) []ethereum.Transaction {
+			return []ethereum.Transaction{{Hash: "0x1", BlockNumber: "0x1"}, {Hash: "0x2", BlockNumber: "0x2"}, {Hash: "0x3", BlockNumber: "0x3"}}
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name     string
+		call     func() error
+		wantCode codes.Code
+	}{
+		{
+			name: "current block",
+			call: func() error {
+				res, err := client.GetCurrentBlock(ctx, &twpb.GetCurrentBlockRequest{})
+				if err == nil && (res.GetBlock() != 16 || res.GetNetwork() != "mainnet") {
+					t.Errorf("GetCurrentBlock() = %v, want block 16 of mainnet", res)
+				}
+
+				return err
+			},
+			wantCode: codes.OK,
+		},
+		{
+			name: "unknown network",
+			call: func() error {
+				_, err := client.GetCurrentBlock(ctx, &twpb.GetCurrentBlockRequest{Network: "sepolia"})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "subscribe invalid address",
+			call: func() error {
+				_, err := client.Subscribe(ctx, &twpb.SubscribeRequest{Address: "0x123"})
+				return err
+			},
+			wantCode: codes.InvalidArgument,
+		},
+		{
+			name: "unsubscribe not subscribed",
+			call: func() error {
+				_, err := client.Unsubscribe(ctx, &twpb.UnsubscribeRequest{Address: testAddress})
+				return err
+			},
+			wantCode: codes.NotFound,
+		},
+		{
+			name: "transactions page",
+			call: func() error {
+				fromBlock := int64(2)
+				res, err := client.GetTransactions(ctx, &twpb.GetTransactionsRequest{Address: testAddress, FromBlock: &fromBlock, Limit: 1})
+				if err == nil && (res.GetTotal() != 2 || res.GetTransactions()[0].GetHash() != "0x2" || res.GetNextOffset() != 1) {
+					t.Errorf("GetTransactions() = %v, want 0x2 of 2 with next offset 1", res)
+				}
+
+				return err
+			},
+			wantCode: codes.OK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if code := status.Code(tt.call()); code != tt.wantCode {
+				t.Errorf("code = %v, want %v", code, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestServer_Watch(t *testing.T) {
+	handlers := make(chan ethereum.EventHandler, 1)
+	client := newClient(t, &mockBackend{
+		addHandlerFunc: func(address string, handler ethereum.EventHandler) (func(), <-chan struct{}, error) {
+			handlers <- handler
+			return func() {}, nil, nil
+		},
+		getTransactionsFunc: func(address string) []ethereum.Transaction {
+			return []ethereum.Transaction{{Hash: "0x1", BlockNumber: "0x1"}, {Hash: "0x2", BlockNumber: "0x2"}}
+		},
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &twpb.WatchRequest{Addresses: []string{testAddress}, LastEventId: "1-0"})
+	if err != nil {
+		t.Fatalf("Watch() error = %v", err)
+	}
+
+	// stored transaction after the last event id is sent first
+	res, err := stream.Recv()
+	if err != nil || res.GetId() != "2-0" {
+		t.Fatalf("Recv() = %v, %v, want replayed 2-0", res, err)
+	}
+
+	handler := <-handlers
+	_ = handler(ctx, ethereum.TransactionObserved{Address: testAddress, Transaction: ethereum.Transaction{Hash: "0x3", BlockNumber: "0x3"}})
+
+	res, err = stream.Recv()
+	if err != nil || res.GetId() != "3-0" || res.GetTransaction().GetHash() != "0x3" || res.GetAddress() != testAddress {
+		t.Fatalf("Recv() = %v, %v, want observed 3-0", res, err)
+	}
+}
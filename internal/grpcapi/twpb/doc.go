@@ -0,0 +1,5 @@
+// Package twpb is generated from tw.proto, run go generate after changing it.
+// It needs protoc with protoc-gen-go v1.34.2 and protoc-gen-go-grpc v1.5.1.
+package twpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative tw.proto
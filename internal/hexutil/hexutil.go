@@ -0,0 +1,162 @@
+// Package hexutil decodes and encodes the hex values of the JSON-RPC api
+// strictly, as the spec describes them: quantities are 0x prefixed numbers
+// without leading zeros (zero is 0x0), data is 0x prefixed even number of
+// hex digits. Malformed value is the error, never the zero.
+package hexutil
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+)
+
+var (
+	ErrEmpty         = errors.New("empty hex string")
+	ErrMissingPrefix = errors.New("hex string without 0x prefix")
+	ErrEmptyNumber   = errors.New("hex string \"0x\"")
+	ErrLeadingZero   = errors.New("hex number with leading zero digits")
+	ErrSyntax        = errors.New("invalid hex string")
+	ErrOddLength     = errors.New("hex string of odd length")
+	ErrRange         = errors.New("hex number out of range")
+)
+
+// maxBigBits is the size of the largest quantity, the EVM word.
+const maxBigBits = 256
+
+// DecodeUint64 decodes the hex quantity.
+func DecodeUint64(s string) (uint64, error) {
+	digits, err := quantityDigits(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(digits) > 16 {
+		return 0, wrap(s, ErrRange)
+	}
+
+	n, err := strconv.ParseUint(digits, 16, 64)
+	if err != nil {
+		return 0, wrap(s, ErrSyntax)
+	}
+
+	return n, nil
+}
+
+// DecodeInt64 decodes the hex quantity which has to fit into int64,
+// i.e. block numbers and chain ids.
+func DecodeInt64(s string) (int64, error) {
+	n, err := DecodeUint64(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if n > math.MaxInt64 {
+		return 0, wrap(s, ErrRange)
+	}
+
+	return int64(n), nil
+}
+
+// DecodeBig decodes the hex quantity of at most 256 bits, i.e. values.
+func DecodeBig(s string) (*big.Int, error) {
+	digits, err := quantityDigits(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(digits) > maxBigBits/4 {
+		return nil, wrap(s, ErrRange)
+	}
+
+	n, ok := new(big.Int).SetString(digits, 16)
+	if !ok {
+		return nil, wrap(s, ErrSyntax)
+	}
+
+	return n, nil
+}
+
+// Decode decodes the hex data.
+func Decode(s string) ([]byte, error) {
+	if s == "" {
+		return nil, ErrEmpty
+	}
+
+	if !hasPrefix(s) {
+		return nil, wrap(s, ErrMissingPrefix)
+	}
+
+	if len(s)%2 != 0 {
+		return nil, wrap(s, ErrOddLength)
+	}
+
+	b, err := hex.DecodeString(s[2:])
+	if err != nil {
+		return nil, wrap(s, ErrSyntax)
+	}
+
+	return b, nil
+}
+
+// EncodeUint64 encodes the number as hex quantity.
+func EncodeUint64(n uint64) string {
+	return "0x" + strconv.FormatUint(n, 16)
+}
+
+// EncodeBig encodes the non-negative number as hex quantity.
+func EncodeBig(n *big.Int) string {
+	return "0x" + n.Text(16)
+}
+
+// Encode encodes the bytes as hex data.
+func Encode(b []byte) string {
+	return "0x" + hex.EncodeToString(b)
+}
+
+// quantityDigits returns the digits of the quantity without the prefix.
+func quantityDigits(s string) (string, error) {
+	if s == "" {
+		return "", ErrEmpty
+	}
+
+	if !hasPrefix(s) {
+		return "", wrap(s, ErrMissingPrefix)
+	}
+
+	digits := s[2:]
+	if digits == "" {
+		return "", ErrEmptyNumber
+	}
+
+	for i := 0; i < len(digits); i++ {
+		if !isHexDigit(digits[i]) {
+			return "", wrap(s, ErrSyntax)
+		}
+	}
+
+	if len(digits) > 1 && digits[0] == '0' {
+		return "", wrap(s, ErrLeadingZero)
+	}
+
+	return digits, nil
+}
+
+func hasPrefix(s string) bool {
+	return len(s) >= 2 && s[0] == '0' && (s[1] == 'x' || s[1] == 'X')
+}
+
+func isHexDigit(c byte) bool {
+	return '0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F'
+}
+
+// wrap adds the value to the error, long values are cut.
+func wrap(s string, err error) error {
+	if len(s) > 80 {
+		s = s[:77] + "..."
+	}
+
+	return fmt.Errorf("%w: %q", err, s)
+}
@@ -0,0 +1,217 @@
+package hexutil
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+func TestDecodeUint64(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    uint64
+		wantErr error
+	}{
+		{input: "0x0", want: 0},
+		{input: "0x1", want: 1},
+		{input: "0x10", want: 16},
+		{input: "0xAbC", want: 0xabc},
+		{input: "0X1f", want: 0x1f},
+		{input: "0xffffffffffffffff", want: math.MaxUint64},
+		{input: "", wantErr: ErrEmpty},
+		{input: "0x", wantErr: ErrEmptyNumber},
+		{input: "10", wantErr: ErrMissingPrefix},
+		{input: "x10", wantErr: ErrMissingPrefix},
+		{input: "0x00", wantErr: ErrLeadingZero},
+		{input: "0x01", wantErr: ErrLeadingZero},
+		{input: "0xg", wantErr: ErrSyntax},
+		{input: "0x-1", wantErr: ErrSyntax},
+		{input: "0x+1", wantErr: ErrSyntax},
+		{input: "0x1_0", wantErr: ErrSyntax},
+		{input: " 0x1", wantErr: ErrMissingPrefix},
+		{input: "0x1 ", wantErr: ErrSyntax},
+		{input: "0x10000000000000000", wantErr: ErrRange},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := DecodeUint64(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeUint64() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got != tt.want {
+				t.Errorf("DecodeUint64() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeInt64(t *testing.T) {
+	if got, err := DecodeInt64("0x7fffffffffffffff"); err != nil || got != math.MaxInt64 {
+		t.Errorf("DecodeInt64() = %v, %v, want %v", got, err, int64(math.MaxInt64))
+	}
+
+	if _, err := DecodeInt64("0x8000000000000000"); !errors.Is(err, ErrRange) {
+		t.Errorf("DecodeInt64() error = %v, want %v", err, ErrRange)
+	}
+}
+
+func TestDecodeBig(t *testing.T) {
+	maxWord := "0x" + strings.Repeat("f", 64)
+
+	tests := []struct {
+		input   string
+		want    string
+		wantErr error
+	}{
+		{input: "0x0", want: "0"},
+		{input: "0xde0b6b3a7640000", want: "1000000000000000000"},
+		{input: maxWord, want: new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1)).String()},
+		{input: "0x1" + strings.Repeat("0", 64), wantErr: ErrRange},
+		{input: "0x00", wantErr: ErrLeadingZero},
+		{input: "0x", wantErr: ErrEmptyNumber},
+		{input: "1", wantErr: ErrMissingPrefix},
+		{input: "0xz", wantErr: ErrSyntax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := DecodeBig(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("DecodeBig() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if err == nil && got.String() != tt.want {
+				t.Errorf("DecodeBig() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    []byte
+		wantErr error
+	}{
+		{input: "0x", want: []byte{}},
+		{input: "0x00", want: []byte{0}},
+		{input: "0x0aFF", want: []byte{0x0a, 0xff}},
+		{input: "", wantErr: ErrEmpty},
+		{input: "00", wantErr: ErrMissingPrefix},
+		{input: "0x0", wantErr: ErrOddLength},
+		{input: "0xzz", wantErr: ErrSyntax},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Decode(tt.input)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Decode() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("Decode() = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	uint64RoundTrip := func(n uint64) bool {
+		got, err := DecodeUint64(EncodeUint64(n))
+		return err == nil && got == n
+	}
+
+	bigRoundTrip := func(b [32]byte) bool {
+		n := new(big.Int).SetBytes(b[:])
+		got, err := DecodeBig(EncodeBig(n))
+		return err == nil && got.Cmp(n) == 0
+	}
+
+	bytesRoundTrip := func(b []byte) bool {
+		got, err := Decode(Encode(b))
+		return err == nil && bytes.Equal(got, b)
+	}
+
+	for name, property := range map[string]any{
+		"uint64": uint64RoundTrip,
+		"big":    bigRoundTrip,
+		"bytes":  bytesRoundTrip,
+	} {
+		if err := quick.Check(property, nil); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func FuzzDecodeUint64(f *testing.F) {
+	for _, seed := range []string{"0x0", "0x1", "0x00", "0xffffffffffffffff", "0x10000000000000000", "0x", "", "1", "0xG", "0x-1"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := DecodeUint64(s)
+		if err != nil {
+			if n != 0 {
+				t.Errorf("DecodeUint64(%q) = %v with error %v", s, n, err)
+			}
+
+			return
+		}
+
+		// valid quantity is canonical, it is encoded back the same
+		if got := EncodeUint64(n); !strings.EqualFold(got, s) {
+			t.Errorf("EncodeUint64(DecodeUint64(%q)) = %q", s, got)
+		}
+
+		// and it is what the lenient parser reads too
+		if want, err := strconv.ParseUint(s[2:], 16, 64); err != nil || want != n {
+			t.Errorf("DecodeUint64(%q) = %v, ParseUint = %v, %v", s, n, want, err)
+		}
+	})
+}
+
+func FuzzDecodeBig(f *testing.F) {
+	for _, seed := range []string{"0x0", "0x1", "0x01", "0x" + strings.Repeat("f", 64), "0x1" + strings.Repeat("0", 64), "0x"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		n, err := DecodeBig(s)
+		if err != nil {
+			return
+		}
+
+		if n.Sign() < 0 || n.BitLen() > maxBigBits {
+			t.Errorf("DecodeBig(%q) = %v out of range", s, n)
+		}
+
+		if got := EncodeBig(n); !strings.EqualFold(got, s) {
+			t.Errorf("EncodeBig(DecodeBig(%q)) = %q", s, got)
+		}
+	})
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, seed := range []string{"0x", "0x00", "0x0", "0xabCD", "ab"} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, s string) {
+		b, err := Decode(s)
+		if err != nil {
+			return
+		}
+
+		if got := Encode(b); !strings.EqualFold(got, s) {
+			t.Errorf("Encode(Decode(%q)) = %q", s, got)
+		}
+	})
+}
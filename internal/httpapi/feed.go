@@ -0,0 +1,106 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"tw/internal/service"
+)
+
+var feedParams = []Param{
+	{Name: "address", In: "query", Description: "watched address, repeated or comma separated", Type: "string", Required: true},
+	{Name: "lastEventId", In: "query", Description: "resume after the event, Last-Event-ID header takes precedence", Type: "string"},
+}
+
+func (s *Server) feedRoutes() []Route {
+	return []Route{
+		{
+			Method:    http.MethodGet,
+			Path:      "/feed",
+			Summary:   "Server-sent events of the transactions observed for the addresses, text/event-stream",
+			Params:    feedParams,
+			Responses: map[int]any{http.StatusOK: nil, http.StatusBadRequest: ErrorResponse{}},
+			Handler:   s.serveSSE,
+		},
+		{
+			Method:    http.MethodGet,
+			Path:      "/feed/ws",
+			Summary:   "WebSocket with the transactions observed for the addresses, one json text message each",
+			Params:    feedParams,
+			Responses: map[int]any{http.StatusSwitchingProtocols: nil, http.StatusBadRequest: ErrorResponse{}},
+			Handler:   s.serveWebSocket,
+		},
+	}
+}
+
+func (s *Server) parseFeedRequest(r *http.Request) (service.FeedRequest, error) {
+	backend, network, err := s.backend(r)
+	if err != nil {
+		return service.FeedRequest{}, err
+	}
+
+	var addresses []string
+	for _, param := range r.URL.Query()["address"] {
+		addresses = append(addresses, strings.Split(param, ",")...)
+	}
+
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		lastID = r.URL.Query().Get("lastEventId")
+	}
+
+	return service.NewFeedRequest(network, backend, addresses, lastID)
+}
+
+func (s *Server) serveSSE(w http.ResponseWriter, r *http.Request) {
+	req, err := s.parseFeedRequest(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	client, err := s.feed.Subscribe(req)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	defer s.feed.Unsubscribe(req, client)
+
+	rc := http.NewResponseController(w)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// nginx buffers the responses otherwise
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	send := func(event service.FeedEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		if event.ID != "" {
+			fmt.Fprintf(w, "id: %s\n", event.ID)
+		}
+
+		fmt.Fprintf(w, "event: transaction\ndata: %s\n\n", data)
+
+		return rc.Flush()
+	}
+
+	ping := func() error {
+		fmt.Fprint(w, ": ping\n\n")
+
+		return rc.Flush()
+	}
+
+	// client reconnects with Last-Event-ID when the stream ends
+	_ = service.Stream(r.Context(), req, client, send, ping)
+}
@@ -0,0 +1,410 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+	"tw/internal/service"
+)
+
+// feedBackend stores three transactions of testAddress and
+// sends the feed handler to the channel when subscribed.
+func feedBackend() (*mockBackend, chan ethereum.EventHandler) {
+	handlers := make(chan ethereum.EventHandler, 1)
+
+	return &mockBackend{
+		addHandlerFunc: func(address string, handler ethereum.EventHandler) (func(), <-chan struct{}, error) {
+			handlers <- handler
+			return func() {}, nil, nil
+		},
+		getTransactionsFunc: func(address string) []ethereum.Transaction {
+			return []ethereum.Transaction{
+				{Hash: "0x1", BlockNumber: "0x1", TransactionIndex: "0x0"},
+				{Hash: "0x3", BlockNumber: "0x2", TransactionIndex: "0x1"},
+				{Hash: "0x2", BlockNumber: "0x2", TransactionIndex: "0x0"},
+			}
+		},
+	}, handlers
+}
+
+func newFeedServer(t *testing.T, backend service.Backend) *httptest.Server {
+	server, err := NewServer(map[string]service.Backend{"mainnet": backend}, "mainnet", clogger.Logger)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	httpServer := httptest.NewServer(server)
+	t.Cleanup(httpServer.Close)
+
+	return httpServer
+}
+
+func TestServer_SSEResume(t *testing.T) {
+	backend, handlers := feedBackend()
+	httpServer := newFeedServer(t, backend)
+
+	req, _ := http.NewRequest(http.MethodGet, httpServer.URL+"/feed?address="+testAddress, nil)
+	req.Header.Set("Last-Event-ID", "1-0")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /feed error = %v", err)
+	}
+	defer res.Body.Close()
+
+	if ct := res.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %v, want text/event-stream", ct)
+	}
+
+	handler := <-handlers
+	// replayed one is skipped, the new one is sent
+	for _, transaction := range []ethereum.Transaction{
+		{Hash: "0x3", BlockNumber: "0x2", TransactionIndex: "0x1"},
+		{Hash: "0x4", BlockNumber: "0x4"},
+	} {
+		_ = handler(context.Background(), ethereum.TransactionObserved{Address: testAddress, Transaction: transaction})
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(res.Body)
+	for len(ids) < 3 && scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	if want := []string{"2-0", "2-1", "4-0"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+}
+
+func TestServer_SSEInterleavedAddresses(t *testing.T) {
+	const otherAddress = "0x0000000000000000000000000000000000000001"
+
+	backend, _ := feedBackend()
+	handlers := make(map[string]ethereum.EventHandler)
+	added := make(chan struct{}, 2)
+	backend.addHandlerFunc = func(address string, handler ethereum.EventHandler) (func(), <-chan struct{}, error) {
+		handlers[address] = handler
+		added <- struct{}{}
+		return func() {}, nil, nil
+	}
+	stored := backend.getTransactionsFunc
+	backend.getTransactionsFunc = func(address string) []ethereum.Transaction {
+		if address == testAddress {
+			return stored(address)
+		}
+
+		return nil
+	}
+
+	httpServer := newFeedServer(t, backend)
+
+	// skipped events would make the scanner wait for them
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/feed?address="+testAddress+","+otherAddress, nil)
+	req.Header.Set("Last-Event-ID", "1-0")
+
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /feed error = %v", err)
+	}
+	defer res.Body.Close()
+
+	<-added
+	<-added
+
+	// the other address is behind the replayed ones and the live ones of testAddress,
+	// its events are sent anyway, only the replayed 2-1 of testAddress is skipped
+	for _, event := range []ethereum.TransactionObserved{
+		{Address: otherAddress, Transaction: ethereum.Transaction{Hash: "0x5", BlockNumber: "0x1", TransactionIndex: "0x1"}},
+		{Address: testAddress, Transaction: ethereum.Transaction{Hash: "0x3", BlockNumber: "0x2", TransactionIndex: "0x1"}},
+		{Address: testAddress, Transaction: ethereum.Transaction{Hash: "0x6", BlockNumber: "0x3"}},
+		{Address: otherAddress, Transaction: ethereum.Transaction{Hash: "0x7", BlockNumber: "0x2", TransactionIndex: "0x9"}},
+	} {
+		_ = handlers[event.Address](context.Background(), event)
+	}
+
+	var ids []string
+	scanner := bufio.NewScanner(res.Body)
+	for len(ids) < 5 && scanner.Scan() {
+		if id, ok := strings.CutPrefix(scanner.Text(), "id: "); ok {
+			ids = append(ids, id)
+		}
+	}
+
+	if want := []string{"2-0", "2-1", "1-1", "3-0", "2-9"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+}
+
+func TestServer_FeedDoesNotSubscribe(t *testing.T) {
+	added := make(chan struct{}, 1)
+	removed := make(chan struct{})
+	var subscribed atomic.Bool
+
+	backend := &mockBackend{
+		subscribeAddressFunc: func(address string) error {
+			subscribed.Store(true)
+			return nil
+		},
+		addHandlerFunc: func(address string, handler ethereum.EventHandler) (func(), <-chan struct{}, error) {
+			if address != testAddress {
+				return nil, nil, fmt.Errorf("%w: %s", ethereum.ErrNotSubscribed, address)
+			}
+
+			added <- struct{}{}
+			return func() { close(removed) }, nil, nil
+		},
+	}
+	httpServer := newFeedServer(t, backend)
+
+	res, err := http.Get(httpServer.URL + "/feed?address=0x0000000000000000000000000000000000000001")
+	if err != nil {
+		t.Fatalf("GET /feed error = %v", err)
+	}
+	_ = res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("status of not subscribed address = %v, want %v", res.StatusCode, http.StatusNotFound)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/feed?address="+testAddress, nil)
+
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET /feed error = %v", err)
+	}
+	<-added
+
+	// the last client leaves, handler is removed
+	cancel()
+	_ = res.Body.Close()
+
+	select {
+	case <-removed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler was not removed")
+	}
+
+	if subscribed.Load() {
+		t.Error("feed subscribed the address")
+	}
+}
+
+func TestServer_FeedAddsHandlerRemovedByBackend(t *testing.T) {
+	added := make(chan chan struct{}, 2)
+
+	backend := &mockBackend{
+		addHandlerFunc: func(address string, handler ethereum.EventHandler) (func(), <-chan struct{}, error) {
+			removed := make(chan struct{})
+			added <- removed
+			return func() {}, removed, nil
+		},
+	}
+	httpServer := newFeedServer(t, backend)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	connect := func() {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, httpServer.URL+"/feed?address="+testAddress, nil)
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("GET /feed error = %v", err)
+		}
+		t.Cleanup(func() { _ = res.Body.Close() })
+	}
+
+	connect()
+	// address was unsubscribed through the other api, backend removed the handler
+	close(<-added)
+
+	connect()
+
+	select {
+	case <-added:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler removed by the backend was not added again")
+	}
+}
+
+func TestServer_WebSocketOrigin(t *testing.T) {
+	tests := []struct {
+		name       string
+		allowed    []string
+		origin     string
+		wantStatus int
+	}{
+		{
+			name:       "not a browser",
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "same host",
+			origin:     "http://HOST",
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "allowed origin",
+			allowed:    []string{"https://app.example.com"},
+			origin:     "https://app.example.com",
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+		{
+			name:       "other origin",
+			allowed:    []string{"https://app.example.com"},
+			origin:     "https://evil.example.com",
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "all origins",
+			allowed:    []string{"*"},
+			origin:     "https://evil.example.com",
+			wantStatus: http.StatusSwitchingProtocols,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, err := NewServer(map[string]service.Backend{"mainnet": &mockBackend{}}, "mainnet", clogger.Logger)
+			if err != nil {
+				t.Fatalf("NewServer() error = %v", err)
+			}
+			server.SetAllowedOrigins(tt.allowed)
+
+			httpServer := httptest.NewServer(server)
+			defer httpServer.Close()
+
+			for _, path := range []string{"/feed/ws?address=" + testAddress, "/rpc/ws"} {
+				req, _ := http.NewRequest(http.MethodGet, httpServer.URL+path, nil)
+				req.Header.Set("Connection", "Upgrade")
+				req.Header.Set("Upgrade", "websocket")
+				req.Header.Set("Sec-WebSocket-Version", "13")
+				req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+				if tt.origin != "" {
+					req.Header.Set("Origin", strings.Replace(tt.origin, "HOST", httpServer.Listener.Addr().String(), 1))
+				}
+
+				res, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatalf("GET %s error = %v", path, err)
+				}
+				_ = res.Body.Close()
+
+				if res.StatusCode != tt.wantStatus {
+					t.Errorf("GET %s status = %v, want %v", path, res.StatusCode, tt.wantStatus)
+				}
+			}
+		})
+	}
+}
+
+func TestServer_WebSocket(t *testing.T) {
+	backend, handlers := feedBackend()
+	httpServer := newFeedServer(t, backend)
+
+	conn, reader := dialWebSocket(t, httpServer, "/feed/ws?address="+testAddress)
+
+	handler := <-handlers
+	_ = handler(context.Background(), ethereum.TransactionObserved{
+		Address:     testAddress,
+		Transaction: ethereum.Transaction{Hash: "0x5", BlockNumber: "0x5", TransactionIndex: "0x2"},
+	})
+
+	opcode, payload := readServerFrame(t, reader)
+
+	var event service.FeedEvent
+	if err := json.Unmarshal(payload, &event); opcode != opText || err != nil {
+		t.Fatalf("frame = %v %s, want text message", opcode, payload)
+	}
+
+	if event.ID != "5-2" || event.Transaction.Hash != "0x5" {
+		t.Errorf("event = %+v, want id 5-2 and hash 0x5", event)
+	}
+
+	writeClientFrame(t, conn, opClose, binary.BigEndian.AppendUint16(nil, closeNormal))
+
+	if opcode, _ := readServerFrame(t, reader); opcode != opClose {
+		t.Errorf("opcode = %v, want close", opcode)
+	}
+}
+
+// dialWebSocket connects to the path and checks the handshake.
+func dialWebSocket(t *testing.T, httpServer *httptest.Server, path string) (net.Conn, *bufio.Reader) {
+	conn, err := net.Dial("tcp", httpServer.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	// key and accept key are the example from RFC 6455
+	_, _ = io.WriteString(conn, "GET "+path+" HTTP/1.1\r\n"+
+		"Host: tw\r\nUpgrade: websocket\r\nConnection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n")
+
+	reader := bufio.NewReader(conn)
+	res, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("read handshake error = %v", err)
+	}
+
+	if res.StatusCode != http.StatusSwitchingProtocols || res.Header.Get("Sec-WebSocket-Accept") != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("handshake = %v %v", res.Status, res.Header)
+	}
+
+	return conn, reader
+}
+
+// writeClientFrame writes the masked frame with the payload shorter than 126 bytes.
+func writeClientFrame(t *testing.T, conn net.Conn, opcode byte, payload []byte) {
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload)), mask[0], mask[1], mask[2], mask[3]}
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("write frame error = %v", err)
+	}
+}
+
+func readServerFrame(t *testing.T, reader *bufio.Reader) (byte, []byte) {
+	var head [2]byte
+	if _, err := io.ReadFull(reader, head[:]); err != nil {
+		t.Fatalf("read frame error = %v", err)
+	}
+
+	length := int(head[1] & 0x7F)
+	if length == 126 {
+		var ext [2]byte
+		_, _ = io.ReadFull(reader, ext[:])
+		length = int(binary.BigEndian.Uint16(ext[:]))
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		t.Fatalf("read payload error = %v", err)
+	}
+
+	return head[0] & 0x0F, payload
+}
@@ -0,0 +1,81 @@
+package httpapi
+
+import (
+	"net/http"
+	"sort"
+
+	"tw/internal/ethereum"
+	"tw/internal/service"
+)
+
+const (
+	HealthOK        = "ok"
+	HealthUnhealthy = "unhealthy"
+)
+
+// HealthResponse is the health of all the networks, status is unhealthy
+// if any of them is.
+type HealthResponse struct {
+	Status   string                     `json:"status"`
+	Networks map[string]ethereum.Health `json:"networks"`
+}
+
+func (s *Server) healthRoutes() []Route {
+	responses := map[int]any{http.StatusOK: HealthResponse{}, http.StatusServiceUnavailable: HealthResponse{}}
+
+	return []Route{
+		{
+			Method:    http.MethodGet,
+			Path:      "/healthz",
+			Summary:   "Liveness: 503 when a parser is stuck (doesn't poll or doesn't process blocks)",
+			Responses: responses,
+			Handler:   s.healthHandler(func(health ethereum.Health) bool { return health.Live }),
+		},
+		{
+			Method:    http.MethodGet,
+			Path:      "/readyz",
+			Summary:   "Readiness: 503 when a parser is behind the head, all its endpoints fail or storage writes fail",
+			Responses: responses,
+			Handler:   s.healthHandler(func(health ethereum.Health) bool { return health.Ready }),
+		},
+	}
+}
+
+// SetHealthConfig sets the thresholds of the health endpoints, default is ethereum.DefaultHealthConfig.
+func (s *Server) SetHealthConfig(config ethereum.HealthConfig) {
+	s.healthConfig = config
+}
+
+// healthHandler checks all the networks, ok tells if the network is healthy.
+func (s *Server) healthHandler(ok func(health ethereum.Health) bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		names := make([]string, 0, len(s.networks))
+		for name := range s.networks {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+
+		res := HealthResponse{Status: HealthOK, Networks: make(map[string]ethereum.Health)}
+		for _, name := range names {
+			checker, isChecker := s.networks[name].(service.HealthChecker)
+			if !isChecker {
+				continue
+			}
+
+			health := checker.Health(s.healthConfig)
+			if !ok(health) {
+				res.Status = HealthUnhealthy
+			}
+
+			res.Networks[name] = health
+		}
+
+		status := http.StatusOK
+		if res.Status != HealthOK {
+			status = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, status, res)
+	}
+}
@@ -0,0 +1,59 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+	"tw/internal/service"
+)
+
+type healthBackend struct {
+	mockBackend
+	health ethereum.Health
+}
+
+func (h *healthBackend) Health(config ethereum.HealthConfig) ethereum.Health {
+	return h.health
+}
+
+func TestServer_Health(t *testing.T) {
+	backends := map[string]service.Backend{
+		"mainnet": &healthBackend{health: ethereum.Health{Live: true, Ready: true}},
+		// backfilling network is live, but not ready
+		"sepolia": &healthBackend{health: ethereum.Health{Live: true, Problems: []string{"50 blocks behind the head"}}},
+		// backend that doesn't check its health is skipped
+		"devnet": &mockBackend{},
+	}
+	server, _ := NewServer(backends, "mainnet", clogger.Logger)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		want       string
+	}{
+		{path: "/healthz", wantStatus: http.StatusOK, want: HealthOK},
+		{path: "/readyz", wantStatus: http.StatusServiceUnavailable, want: HealthUnhealthy},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			var res HealthResponse
+			_ = json.Unmarshal(rec.Body.Bytes(), &res)
+
+			if rec.Code != tt.wantStatus || res.Status != tt.want {
+				t.Errorf("status = %v %v, want %v %v", rec.Code, res.Status, tt.wantStatus, tt.want)
+			}
+
+			if len(res.Networks) != 2 {
+				t.Errorf("networks = %v, want mainnet and sepolia", res.Networks)
+			}
+		})
+	}
+}
@@ -0,0 +1,504 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"tw/internal/ethereum"
+	"tw/internal/service"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	// rpcServerError is the parser error, i.e. it's shutting down.
+	rpcServerError = -32000
+)
+
+// maxBatchSize limits requests of the one batch.
+const maxBatchSize = 100
+
+// TransactionsFilter is the optional second param of tw_getTransactions, its
+// fields are the same as the query parameters of the REST api.
+type TransactionsFilter struct {
+	From      string `json:"from,omitempty"`
+	FromBlock *int64 `json:"fromBlock,omitempty"`
+	ToBlock   *int64 `json:"toBlock,omitempty"`
+	Offset    *int   `json:"offset,omitempty"`
+	Limit     *int   `json:"limit,omitempty"`
+}
+
+// SubscriptionNotification are the params of the tw_subscription notification.
+type SubscriptionNotification struct {
+	Subscription string            `json:"subscription"`
+	Result       service.FeedEvent `json:"result"`
+}
+
+func (s *Server) rpcRoutes() []Route {
+	return []Route{
+		{
+			Method: http.MethodPost,
+			Path:   "/rpc",
+			Summary: "JSON-RPC 2.0, single or batch: tw_currentBlock, tw_subscribe, tw_unsubscribe " +
+				"and tw_getTransactions, responds with 204 when all the requests are notifications",
+			Request:   ethereum.RPCRequest{},
+			Responses: map[int]any{http.StatusOK: ethereum.RPCResponse{}, http.StatusNoContent: nil},
+			Handler:   s.serveRPC,
+		},
+		{
+			Method: http.MethodGet,
+			Path:   "/rpc/ws",
+			Summary: "JSON-RPC 2.0 over WebSocket, tw_subscribe returns the subscription id " +
+				"and observed transactions are pushed as tw_subscription notifications",
+			Responses: map[int]any{http.StatusSwitchingProtocols: nil, http.StatusBadRequest: ErrorResponse{}},
+			Handler:   s.serveRPCWebSocket,
+		},
+	}
+}
+
+func (s *Server) serveRPC(w http.ResponseWriter, r *http.Request) {
+	backend, network, err := s.backend(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		s.writeError(w, badRequest("read request body: %s", err.Error()))
+		return
+	}
+
+	session := s.newRPCSession(r.Context(), network, backend, nil)
+	defer session.close()
+
+	res := session.handle(body)
+	if res == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, res)
+}
+
+func (s *Server) serveRPCWebSocket(w http.ResponseWriter, r *http.Request) {
+	backend, network, err := s.backend(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := s.checkOrigin(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	session := s.newRPCSession(r.Context(), network, backend, conn)
+
+	// reading is unblocked by closing the connection when the server shuts down
+	go func() {
+		<-session.ctx.Done()
+		if r.Context().Err() != nil {
+			conn.close(closeGoingAway, "")
+		}
+	}()
+
+	for {
+		opcode, message, err := conn.readMessage()
+		if err != nil {
+			break
+		}
+
+		if opcode != opText {
+			continue
+		}
+
+		if res := session.handle(message); res != nil {
+			data, _ := json.Marshal(res)
+			if err := conn.writeFrame(opText, data); err != nil {
+				break
+			}
+		}
+
+		// subscriptions start to push after their id was sent
+		session.startSubscriptions()
+	}
+
+	session.close()
+	conn.close(closeNormal, "")
+}
+
+// rpcSession handles the requests of the one http request or websocket connection.
+type rpcSession struct {
+	server  *Server
+	network string
+	backend service.Backend
+	// conn is nil over http, push subscriptions need the websocket
+	conn *wsConn
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu            sync.Mutex
+	subscriptions map[string]context.CancelFunc
+	// starting are the subscriptions created by the current message
+	starting []func()
+	wg       sync.WaitGroup
+}
+
+func (s *Server) newRPCSession(ctx context.Context, network string, backend service.Backend, conn *wsConn) *rpcSession {
+	ctx, cancel := context.WithCancel(ctx)
+
+	return &rpcSession{
+		server:        s,
+		network:       network,
+		backend:       backend,
+		conn:          conn,
+		ctx:           ctx,
+		cancel:        cancel,
+		subscriptions: make(map[string]context.CancelFunc),
+	}
+}
+
+// close stops the subscriptions and waits for them. Subscriptions that
+// were not started yet end right away, they are started to clean up.
+func (rs *rpcSession) close() {
+	rs.cancel()
+	rs.startSubscriptions()
+	rs.wg.Wait()
+}
+
+// handle handles the single request or the batch, it returns nil when there is nothing
+// to respond with, i.e. all the requests were notifications.
+func (rs *rpcSession) handle(body []byte) any {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		if res := rs.handleRequest(body); res != nil {
+			return res
+		}
+
+		return nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return rpcErrorResponse(nil, rpcParseError, err.Error())
+	}
+
+	if len(batch) == 0 || len(batch) > maxBatchSize {
+		return rpcErrorResponse(nil, rpcInvalidRequest, fmt.Sprintf("batch must have between 1 and %d requests", maxBatchSize))
+	}
+
+	var responses []*ethereum.RPCResponse
+	for _, raw := range batch {
+		if res := rs.handleRequest(raw); res != nil {
+			responses = append(responses, res)
+		}
+	}
+
+	if len(responses) == 0 {
+		return nil
+	}
+
+	return responses
+}
+
+// handleRequest returns the response of the request, or nil for the notification.
+func (rs *rpcSession) handleRequest(raw []byte) *ethereum.RPCResponse {
+	var req ethereum.RPCRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return rpcErrorResponse(nil, rpcParseError, err.Error())
+		}
+
+		return rpcErrorResponse(nil, rpcInvalidRequest, err.Error())
+	}
+
+	if req.JSONRpc != ethereum.JSONRPCVersion || req.Method == "" {
+		return rpcErrorResponse(req.ID, rpcInvalidRequest, `jsonrpc must be "2.0" and method is required`)
+	}
+
+	result, err := rs.call(req.Method, req.Params)
+	if len(req.ID) == 0 {
+		return nil
+	}
+
+	if err != nil {
+		var rpcErr *ethereum.RPCError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = rs.rpcError(req.Method, err)
+		}
+
+		return rpcErrorResponse(req.ID, rpcErr.Code, rpcErr.Message)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return rpcErrorResponse(req.ID, rpcInternalError, "internal error")
+	}
+
+	return &ethereum.RPCResponse{ID: req.ID, JSONRpc: ethereum.JSONRPCVersion, Result: data}
+}
+
+func (rs *rpcSession) call(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "tw_currentBlock":
+		return rs.currentBlock(params)
+	case "tw_subscribe":
+		return rs.subscribe(params)
+	case "tw_unsubscribe":
+		return rs.unsubscribe(params)
+	case "tw_getTransactions":
+		return rs.getTransactions(params)
+	default:
+		return nil, &ethereum.RPCError{Code: rpcMethodNotFound, Message: fmt.Sprintf("method %s does not exist", method)}
+	}
+}
+
+// currentBlock returns the block as hex quantity, like eth_blockNumber.
+func (rs *rpcSession) currentBlock(params json.RawMessage) (any, error) {
+	if err := decodeParams(params, 0); err != nil {
+		return nil, err
+	}
+
+	block := rs.backend.GetCurrentBlock()
+	if block == 0 {
+		return nil, &ethereum.RPCError{Code: rpcServerError, Message: "current block is not available"}
+	}
+
+	return "0x" + strconv.FormatInt(int64(block), 16), nil
+}
+
+// subscribe subscribes the address and returns it. Over websocket it returns the
+// subscription id instead, observed transactions are pushed with tw_subscription.
+// Optional second param is the id of the last event, to resume like the feed.
+func (rs *rpcSession) subscribe(params json.RawMessage) (any, error) {
+	var address, lastEventID string
+	if err := decodeParams(params, 1, &address, &lastEventID); err != nil {
+		return nil, err
+	}
+
+	normalized, err := ethereum.NormalizeAddress(address)
+	if err != nil {
+		return nil, invalidParams(err.Error())
+	}
+
+	if err := rs.backend.SubscribeAddress(normalized); err != nil {
+		return nil, err
+	}
+
+	if rs.conn == nil {
+		return normalized, nil
+	}
+
+	req, err := service.NewFeedRequest(rs.network, rs.backend, []string{normalized}, lastEventID)
+	if err != nil {
+		return nil, invalidParams(err.Error())
+	}
+
+	client, err := rs.server.feed.Subscribe(req)
+	if err != nil {
+		return nil, err
+	}
+
+	id := newSubscriptionID()
+	ctx, cancel := context.WithCancel(rs.ctx)
+
+	rs.mu.Lock()
+	rs.subscriptions[id] = cancel
+	rs.starting = append(rs.starting, func() {
+		defer rs.wg.Done()
+		defer rs.server.feed.Unsubscribe(req, client)
+
+		send := func(event service.FeedEvent) error {
+			notification, _ := json.Marshal(SubscriptionNotification{Subscription: id, Result: event})
+			data, _ := json.Marshal(ethereum.RPCResponse{JSONRpc: ethereum.JSONRPCVersion, Method: "tw_subscription", Params: notification})
+
+			return rs.conn.writeFrame(opText, data)
+		}
+
+		ping := func() error {
+			return rs.conn.writeFrame(opPing, nil)
+		}
+
+		if errors.Is(service.Stream(ctx, req, client, send, ping), service.ErrSlowClient) {
+			rs.conn.close(closeTryAgain, "client is not keeping up, resume with lastEventId")
+		}
+	})
+	rs.mu.Unlock()
+
+	return id, nil
+}
+
+// startSubscriptions starts pushing of the subscriptions created by the last message.
+func (rs *rpcSession) startSubscriptions() {
+	rs.mu.Lock()
+	starting := rs.starting
+	rs.starting = nil
+	rs.mu.Unlock()
+
+	rs.wg.Add(len(starting))
+	for _, start := range starting {
+		go start()
+	}
+}
+
+// unsubscribe cancels the subscription of the connection by its id. Otherwise the
+// param is the address, which is unsubscribed in the parser. It returns false when
+// there is nothing to unsubscribe, like eth_unsubscribe.
+func (rs *rpcSession) unsubscribe(params json.RawMessage) (any, error) {
+	var idOrAddress string
+	if err := decodeParams(params, 1, &idOrAddress); err != nil {
+		return nil, err
+	}
+
+	rs.mu.Lock()
+	cancel, ok := rs.subscriptions[idOrAddress]
+	delete(rs.subscriptions, idOrAddress)
+	rs.mu.Unlock()
+
+	if ok {
+		cancel()
+		return true, nil
+	}
+
+	address, err := ethereum.NormalizeAddress(idOrAddress)
+	if err != nil {
+		return nil, invalidParams("unknown subscription id and " + err.Error())
+	}
+
+	if err := rs.backend.Unsubscribe(address); err != nil {
+		if errors.Is(err, ethereum.ErrNotSubscribed) {
+			return false, nil
+		}
+
+		return nil, err
+	}
+
+	return true, nil
+}
+
+func (rs *rpcSession) getTransactions(params json.RawMessage) (any, error) {
+	var (
+		address string
+		filter  TransactionsFilter
+	)
+	if err := decodeParams(params, 1, &address, &filter); err != nil {
+		return nil, err
+	}
+
+	normalized, err := ethereum.NormalizeAddress(address)
+	if err != nil {
+		return nil, invalidParams(err.Error())
+	}
+
+	transactionFilter := service.NewFilter()
+	transactionFilter.From = filter.From
+
+	if filter.FromBlock != nil {
+		transactionFilter.FromBlock = *filter.FromBlock
+	}
+
+	if filter.ToBlock != nil {
+		transactionFilter.ToBlock = *filter.ToBlock
+	}
+
+	if filter.Offset != nil {
+		transactionFilter.Offset = *filter.Offset
+	}
+
+	if filter.Limit != nil {
+		transactionFilter.Limit = *filter.Limit
+	}
+
+	if err := transactionFilter.Validate(); err != nil {
+		return nil, invalidParams(err.Error())
+	}
+
+	return newTransactionsResponse(rs.network, normalized, service.GetPage(rs.backend, normalized, transactionFilter)), nil
+}
+
+// rpcError maps the errors of the backend to the rpc errors,
+// like writeError does with the http statuses.
+func (rs *rpcSession) rpcError(method string, err error) *ethereum.RPCError {
+	var apiErr *apiError
+
+	switch {
+	case errors.As(err, &apiErr) && apiErr.status == http.StatusBadRequest:
+		return invalidParams(apiErr.message)
+	case errors.Is(err, ethereum.ErrInvalidAddress), errors.Is(err, ethereum.ErrInvalidAddressChecksum),
+		errors.Is(err, service.ErrInvalidFilter):
+		return invalidParams(err.Error())
+	case errors.Is(err, ethereum.ErrClosed):
+		return &ethereum.RPCError{Code: rpcServerError, Message: "parser is shutting down"}
+	default:
+		rs.server.logger.Error("json rpc error", "method", method, "err", err)
+		return &ethereum.RPCError{Code: rpcInternalError, Message: "internal error"}
+	}
+}
+
+func invalidParams(message string) *ethereum.RPCError {
+	return &ethereum.RPCError{Code: rpcInvalidParams, Message: message}
+}
+
+// decodeParams decodes positional params into the targets, the first required are mandatory.
+func decodeParams(params json.RawMessage, required int, targets ...any) error {
+	var positional []json.RawMessage
+	if len(params) > 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, &positional); err != nil {
+			return invalidParams("params must be an array")
+		}
+	}
+
+	if len(positional) < required || len(positional) > len(targets) {
+		return invalidParams(fmt.Sprintf("want %d to %d params, got %d", required, len(targets), len(positional)))
+	}
+
+	for i, raw := range positional {
+		if err := json.Unmarshal(raw, targets[i]); err != nil {
+			return invalidParams(fmt.Sprintf("param %d: %s", i+1, err.Error()))
+		}
+	}
+
+	return nil
+}
+
+func rpcErrorResponse(id json.RawMessage, code int, message string) *ethereum.RPCResponse {
+	if len(id) == 0 {
+		// id is null when it couldn't be read
+		id = json.RawMessage("null")
+	}
+
+	return &ethereum.RPCResponse{
+		ID:      id,
+		JSONRpc: ethereum.JSONRPCVersion,
+		Error:   &ethereum.RPCError{Code: code, Message: message},
+	}
+}
+
+func newSubscriptionID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return "0x" + hex.EncodeToString(b)
+}
@@ -0,0 +1,144 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+	"tw/internal/service"
+)
+
+func TestServer_JSONRPC(t *testing.T) {
+	backend := &mockBackend{
+		getCurrentBlockFunc: func() int { return 16 },
+		unsubscribeFunc: func(address string) error {
+			return ethereum.ErrNotSubscribed
+		},
+		getTransactionsFunc: func(address string) []ethereum.Transaction {
+			return []ethereum.Transaction{{Hash: "0x1", BlockNumber: "0x1"}, {Hash: "0x2", BlockNumber: "0x2"}}
+		},
+	}
+
+	server, err := NewServer(map[string]service.Backend{"mainnet": backend}, "mainnet", clogger.Logger)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "current block",
+			body:       `{"jsonrpc":"2.0","id":1,"method":"tw_currentBlock"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":1,"jsonrpc":"2.0","result":"0x10"}`,
+		},
+		{
+			name:       "subscribe over http returns the address",
+			body:       `{"jsonrpc":"2.0","id":"a","method":"tw_subscribe","params":["` + strings.ToLower(testAddress) + `"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":"a","jsonrpc":"2.0","result":"` + testAddress + `"}`,
+		},
+		{
+			name:       "unsubscribe not subscribed",
+			body:       `{"jsonrpc":"2.0","id":2,"method":"tw_unsubscribe","params":["` + testAddress + `"]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":2,"jsonrpc":"2.0","result":false}`,
+		},
+		{
+			name:       "transactions with filter",
+			body:       `{"jsonrpc":"2.0","id":3,"method":"tw_getTransactions","params":["` + testAddress + `",{"fromBlock":2}]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `"total":1,"nextOffset":null}}`,
+		},
+		{
+			name:       "invalid params",
+			body:       `{"jsonrpc":"2.0","id":4,"method":"tw_getTransactions","params":[]}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":4,"jsonrpc":"2.0","error":{"code":-32602,"message":"want 1 to 2 params, got 0"}}`,
+		},
+		{
+			name:       "batch without notification",
+			body:       `[{"jsonrpc":"2.0","method":"tw_currentBlock"},{"jsonrpc":"2.0","id":5,"method":"eth_call"}]`,
+			wantStatus: http.StatusOK,
+			wantBody:   `[{"id":5,"jsonrpc":"2.0","error":{"code":-32601,"message":"method eth_call does not exist"}}]`,
+		},
+		{
+			name:       "only notifications",
+			body:       `{"jsonrpc":"2.0","method":"tw_currentBlock"}`,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "parse error",
+			body:       `{"jsonrpc":`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":null,"jsonrpc":"2.0","error":{"code":-32700`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/rpc", strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v", rec.Code, tt.wantStatus)
+			}
+
+			if body := strings.TrimSpace(rec.Body.String()); !strings.Contains(body, tt.wantBody) {
+				t.Errorf("body = %v, want %v", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServer_JSONRPCSubscription(t *testing.T) {
+	backend, handlers := feedBackend()
+	httpServer := newFeedServer(t, backend)
+
+	conn, reader := dialWebSocket(t, httpServer, "/rpc/ws")
+
+	writeClientFrame(t, conn, opText, []byte(`{"jsonrpc":"2.0","id":1,"method":"tw_subscribe","params":["`+testAddress+`"]}`))
+
+	var res ethereum.RPCResponse
+	if _, payload := readServerFrame(t, reader); json.Unmarshal(payload, &res) != nil || res.Error != nil {
+		t.Fatalf("tw_subscribe response = %s", payload)
+	}
+
+	var id string
+	_ = json.Unmarshal(res.Result, &id)
+
+	handler := <-handlers
+	_ = handler(context.Background(), ethereum.TransactionObserved{
+		Address:     testAddress,
+		Transaction: ethereum.Transaction{Hash: "0x7", BlockNumber: "0x7"},
+	})
+
+	_, payload := readServerFrame(t, reader)
+
+	var notification struct {
+		Method string                   `json:"method"`
+		Params SubscriptionNotification `json:"params"`
+	}
+	if err := json.Unmarshal(payload, &notification); err != nil {
+		t.Fatalf("notification = %s, error = %v", payload, err)
+	}
+
+	if notification.Method != "tw_subscription" || notification.Params.Subscription != id || notification.Params.Result.ID != "7-0" {
+		t.Errorf("notification = %+v, want tw_subscription of %v with id 7-0", notification, id)
+	}
+
+	writeClientFrame(t, conn, opText, []byte(`{"jsonrpc":"2.0","id":2,"method":"tw_unsubscribe","params":["`+id+`"]}`))
+
+	if _, payload := readServerFrame(t, reader); string(payload) != `{"id":2,"jsonrpc":"2.0","result":true}` {
+		t.Errorf("tw_unsubscribe response = %s, want true", payload)
+	}
+}
@@ -0,0 +1,171 @@
+package httpapi
+
+import (
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIVersion is the version of the OpenAPI specification of the document.
+const OpenAPIVersion = "3.0.3"
+
+var pathParamRegexp = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPI returns the OpenAPI document of the registered routes. It is generated
+// from the routes, so it can be marshalled to json as is.
+func (s *Server) OpenAPI() map[string]any {
+	paths := make(map[string]any)
+	schemas := make(map[string]any)
+
+	for _, r := range s.routes {
+		path, _ := paths[r.Path].(map[string]any)
+		if path == nil {
+			path = make(map[string]any)
+			paths[r.Path] = path
+		}
+
+		path[strings.ToLower(r.Method)] = s.operation(r, schemas)
+	}
+
+	return map[string]any{
+		"openapi": OpenAPIVersion,
+		"info": map[string]any{
+			"title":   "tw",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+		},
+	}
+}
+
+func (s *Server) operation(r Route, schemas map[string]any) map[string]any {
+	params := []any{map[string]any{
+		"name":        "network",
+		"in":          "query",
+		"description": "network name, default is " + s.defaultNetwork,
+		"schema":      map[string]any{"type": "string", "enum": s.networkNames()},
+	}}
+	for _, p := range r.Params {
+		params = append(params, map[string]any{
+			"name":        p.Name,
+			"in":          p.In,
+			"description": p.Description,
+			"required":    p.Required,
+			"schema":      map[string]any{"type": p.Type},
+		})
+	}
+
+	responses := make(map[string]any)
+	for status, body := range r.Responses {
+		response := map[string]any{"description": http.StatusText(status)}
+		if body != nil {
+			response["content"] = jsonContent(schemaRef(reflect.TypeOf(body), schemas))
+		}
+
+		responses[strconv.Itoa(status)] = response
+	}
+
+	op := map[string]any{
+		"operationId": operationID(r),
+		"summary":     r.Summary,
+		"parameters":  params,
+		"responses":   responses,
+	}
+
+	if r.Request != nil {
+		op["requestBody"] = map[string]any{
+			"required": true,
+			"content":  jsonContent(schemaRef(reflect.TypeOf(r.Request), schemas)),
+		}
+	}
+
+	return op
+}
+
+// operationID is derived from the method and path, i.e. deleteSubscriptionsAddress.
+func operationID(r Route) string {
+	id := strings.ToLower(r.Method)
+	for _, part := range strings.Split(pathParamRegexp.ReplaceAllString(r.Path, "$1"), "/") {
+		for _, word := range strings.FieldsFunc(part, func(c rune) bool { return c == '_' || c == '-' }) {
+			id += strings.ToUpper(word[:1]) + word[1:]
+		}
+	}
+
+	return id
+}
+
+func jsonContent(schema map[string]any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+// schemaRef returns the json schema of the type, named structs are
+// added to the schemas and referenced.
+func schemaRef(t reflect.Type, schemas map[string]any) map[string]any {
+	// time is marshalled as RFC 3339 string
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		schema := schemaRef(t.Elem(), schemas)
+		schema["nullable"] = true
+
+		return schema
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaRef(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaRef(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+
+		if _, ok := schemas[t.Name()]; !ok {
+			// placeholder stops the recursion of the self referencing types
+			schemas[t.Name()] = nil
+			schemas[t.Name()] = structSchema(t, schemas)
+		}
+
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
+
+func structSchema(t reflect.Type, schemas map[string]any) map[string]any {
+	properties := make(map[string]any)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = schemaRef(field.Type, schemas)
+	}
+
+	return map[string]any{"type": "object", "properties": properties}
+}
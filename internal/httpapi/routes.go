@@ -0,0 +1,281 @@
+package httpapi
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"tw/internal/ethereum"
+	"tw/internal/service"
+)
+
+// Route is the handler with its description, which is used to generate
+// the OpenAPI document, so the document always matches the handlers.
+type Route struct {
+	Method  string
+	Path    string
+	Summary string
+	Params  []Param
+	// Request is the zero value of the request body type, nil means no body.
+	Request any
+	// Responses are the zero values of the response body types by status, nil means no body.
+	Responses map[int]any
+	Handler   http.HandlerFunc
+}
+
+// Param is path or query parameter.
+type Param struct {
+	Name        string
+	In          string
+	Description string
+	// Type is the json schema type: string, integer or boolean.
+	Type     string
+	Required bool
+}
+
+type CurrentBlockResponse struct {
+	Network string `json:"network"`
+	Block   int    `json:"block"`
+}
+
+type SubscriptionRequest struct {
+	Address string `json:"address"`
+}
+
+type SubscriptionResponse struct {
+	Network string `json:"network"`
+	Address string `json:"address"`
+}
+
+type SubscriptionsResponse struct {
+	Network   string   `json:"network"`
+	Addresses []string `json:"addresses"`
+}
+
+type TransactionsResponse struct {
+	Network      string                 `json:"network"`
+	Address      string                 `json:"address"`
+	Transactions []ethereum.Transaction `json:"transactions"`
+	// Total is the number of transactions matching the filters.
+	Total int `json:"total"`
+	// NextOffset is the offset of the next page, it's null on the last page.
+	NextOffset *int `json:"nextOffset"`
+}
+
+var addressParam = Param{Name: "address", In: "path", Description: "ethereum address, any casing", Type: "string", Required: true}
+
+func (s *Server) apiRoutes() []Route {
+	return []Route{
+		{
+			Method:    http.MethodGet,
+			Path:      "/blocks/current",
+			Summary:   "Current block of the network",
+			Responses: map[int]any{http.StatusOK: CurrentBlockResponse{}, http.StatusBadGateway: ErrorResponse{}},
+			Handler:   s.getCurrentBlock,
+		},
+		{
+			Method:    http.MethodGet,
+			Path:      "/subscriptions",
+			Summary:   "List subscribed addresses",
+			Responses: map[int]any{http.StatusOK: SubscriptionsResponse{}},
+			Handler:   s.listSubscriptions,
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/subscriptions",
+			Summary: "Subscribe the address, transactions to it are stored from now on",
+			Request: SubscriptionRequest{},
+			Responses: map[int]any{
+				http.StatusCreated:    SubscriptionResponse{},
+				http.StatusOK:         SubscriptionResponse{},
+				http.StatusBadRequest: ErrorResponse{},
+			},
+			Handler: s.subscribe,
+		},
+		{
+			Method:  http.MethodDelete,
+			Path:    "/subscriptions/{address}",
+			Summary: "Unsubscribe the address, stored transactions are kept",
+			Params:  []Param{addressParam},
+			Responses: map[int]any{
+				http.StatusNoContent:  nil,
+				http.StatusBadRequest: ErrorResponse{},
+				http.StatusNotFound:   ErrorResponse{},
+			},
+			Handler: s.unsubscribe,
+		},
+		{
+			Method:  http.MethodGet,
+			Path:    "/addresses/{address}/transactions",
+			Summary: "Stored transactions of the address, oldest first",
+			Params: []Param{
+				addressParam,
+				{Name: "from", In: "query", Description: "only transactions sent from the address", Type: "string"},
+				{Name: "fromBlock", In: "query", Description: "only transactions from this block on", Type: "integer"},
+				{Name: "toBlock", In: "query", Description: "only transactions up to this block, inclusive", Type: "integer"},
+				{Name: "offset", In: "query", Description: "number of transactions to skip, default 0", Type: "integer"},
+				{Name: "limit", In: "query", Description: "page size, default 50, at most 1000", Type: "integer"},
+			},
+			Responses: map[int]any{http.StatusOK: TransactionsResponse{}, http.StatusBadRequest: ErrorResponse{}},
+			Handler:   s.getTransactions,
+		},
+	}
+}
+
+func (s *Server) getCurrentBlock(w http.ResponseWriter, r *http.Request) {
+	backend, network, err := s.backend(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	// parser returns 0 when api doesn't respond
+	block := backend.GetCurrentBlock()
+	if block == 0 {
+		s.writeError(w, &apiError{status: http.StatusBadGateway, message: "current block is not available"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, CurrentBlockResponse{Network: network, Block: block})
+}
+
+func (s *Server) listSubscriptions(w http.ResponseWriter, r *http.Request) {
+	backend, network, err := s.backend(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, SubscriptionsResponse{Network: network, Addresses: backend.Subscriptions()})
+}
+
+func (s *Server) subscribe(w http.ResponseWriter, r *http.Request) {
+	backend, network, err := s.backend(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	var req SubscriptionRequest
+	if err := readJSON(w, r, &req); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	address, err := ethereum.NormalizeAddress(req.Address)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	status := http.StatusCreated
+	for _, subscribed := range backend.Subscriptions() {
+		if subscribed == address {
+			status = http.StatusOK
+		}
+	}
+
+	if err := backend.SubscribeAddress(address); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, status, SubscriptionResponse{Network: network, Address: address})
+}
+
+func (s *Server) unsubscribe(w http.ResponseWriter, r *http.Request) {
+	backend, _, err := s.backend(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	address, err := ethereum.NormalizeAddress(r.PathValue("address"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	if err := backend.Unsubscribe(address); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) getTransactions(w http.ResponseWriter, r *http.Request) {
+	backend, network, err := s.backend(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	address, err := ethereum.NormalizeAddress(r.PathValue("address"))
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	filter, err := parseFilter(r.URL.Query())
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newTransactionsResponse(network, address, service.GetPage(backend, address, filter)))
+}
+
+func newTransactionsResponse(network, address string, page service.Page) TransactionsResponse {
+	return TransactionsResponse{
+		Network:      network,
+		Address:      address,
+		Transactions: page.Transactions,
+		Total:        page.Total,
+		NextOffset:   page.NextOffset,
+	}
+}
+
+// parseFilter parses the query parameters of the transactions list.
+func parseFilter(query url.Values) (service.Filter, error) {
+	filter := service.NewFilter()
+	filter.From = query.Get("from")
+
+	integers := []struct {
+		name   string
+		target *int64
+	}{
+		{"fromBlock", &filter.FromBlock},
+		{"toBlock", &filter.ToBlock},
+	}
+	for _, param := range integers {
+		if v := query.Get(param.name); v != "" {
+			n, err := strconv.ParseInt(v, 10, 64)
+			if err != nil || n < 0 {
+				return filter, badRequest("%s must be non negative integer, got %q", param.name, v)
+			}
+
+			*param.target = n
+		}
+	}
+
+	ints := []struct {
+		name   string
+		target *int
+	}{
+		{"offset", &filter.Offset},
+		{"limit", &filter.Limit},
+	}
+	for _, param := range ints {
+		if v := query.Get(param.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return filter, badRequest("%s must be integer, got %q", param.name, v)
+			}
+
+			*param.target = n
+		}
+	}
+
+	return filter, filter.Validate()
+}
@@ -0,0 +1,164 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"tw/internal/ethereum"
+	"tw/internal/metrics"
+	"tw/internal/service"
+)
+
+// maxBodyBytes limits size of the request bodies.
+const maxBodyBytes = 1 << 20
+
+// Server exposes parsers over http, one for each network. Every route
+// accepts ?network= query parameter, default network is used without it.
+type Server struct {
+	networks       map[string]service.Backend
+	defaultNetwork string
+	logger         *slog.Logger
+
+	feed           *service.Feed
+	healthConfig   ethereum.HealthConfig
+	allowedOrigins []string
+	routes         []Route
+	mux            *http.ServeMux
+}
+
+var _ http.Handler = (*Server)(nil)
+
+// NewServer creates a new instance of Server, default network has to be one of the networks.
+func NewServer(networks map[string]service.Backend, defaultNetwork string, logger *slog.Logger) (*Server, error) {
+	if _, ok := networks[defaultNetwork]; !ok {
+		return nil, fmt.Errorf("default network %q is not one of the networks", defaultNetwork)
+	}
+
+	s := &Server{
+		networks:       networks,
+		defaultNetwork: defaultNetwork,
+		logger:         logger,
+		feed:           service.NewFeed(),
+		healthConfig:   ethereum.DefaultHealthConfig,
+		mux:            http.NewServeMux(),
+	}
+
+	routes := append(append(append(s.apiRoutes(), s.feedRoutes()...), s.rpcRoutes()...), s.healthRoutes()...)
+	for _, r := range routes {
+		s.Handle(r)
+	}
+
+	s.mux.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.OpenAPI())
+	})
+
+	// metrics are in the Prometheus text format, they aren't part of the OpenAPI document
+	s.mux.Handle("GET /metrics", metrics.Default.Handler())
+
+	return s, nil
+}
+
+// SetAllowedOrigins sets the origins of the browser pages which can open the
+// websockets, besides the pages of the api host. "*" allows all of them.
+func (s *Server) SetAllowedOrigins(origins []string) {
+	s.allowedOrigins = origins
+}
+
+// Handle adds the route to the server and to the OpenAPI document.
+func (s *Server) Handle(r Route) {
+	s.routes = append(s.routes, r)
+	s.mux.HandleFunc(r.Method+" "+r.Path, r.Handler)
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// backend returns parser of the network from the query, or the default one.
+func (s *Server) backend(r *http.Request) (service.Backend, string, error) {
+	network := r.URL.Query().Get("network")
+	if network == "" {
+		network = s.defaultNetwork
+	}
+
+	backend, ok := s.networks[network]
+	if !ok {
+		return nil, "", &apiError{status: http.StatusNotFound, message: fmt.Sprintf("unknown network %q, known: %v", network, s.networkNames())}
+	}
+
+	return backend, network, nil
+}
+
+func (s *Server) networkNames() []string {
+	names := make([]string, 0, len(s.networks))
+	for name := range s.networks {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// apiError is the error with the http status.
+type apiError struct {
+	status  int
+	message string
+}
+
+func (e *apiError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...any) error {
+	return &apiError{status: http.StatusBadRequest, message: fmt.Sprintf(format, args...)}
+}
+
+// ErrorResponse is returned with every status other than 2xx.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// writeError writes the error response, errors other than
+// apiError are internal, so they are logged and not returned.
+func (s *Server) writeError(w http.ResponseWriter, err error) {
+	var apiErr *apiError
+
+	switch {
+	case errors.As(err, &apiErr):
+		writeJSON(w, apiErr.status, ErrorResponse{Error: apiErr.message})
+	case errors.Is(err, ethereum.ErrInvalidAddress), errors.Is(err, ethereum.ErrInvalidAddressChecksum),
+		errors.Is(err, service.ErrInvalidFilter):
+		writeJSON(w, http.StatusBadRequest, ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ethereum.ErrNotSubscribed):
+		writeJSON(w, http.StatusNotFound, ErrorResponse{Error: err.Error()})
+	case errors.Is(err, ethereum.ErrClosed):
+		writeJSON(w, http.StatusServiceUnavailable, ErrorResponse{Error: "parser is shutting down"})
+	default:
+		s.logger.Error("http api error", "err", err)
+		writeJSON(w, http.StatusInternalServerError, ErrorResponse{Error: "internal error"})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// readJSON decodes the request body, unknown fields are rejected.
+func readJSON(w http.ResponseWriter, r *http.Request, target any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(target); err != nil {
+		return badRequest("invalid request body: %s", err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,231 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+	"tw/internal/service"
+)
+
+const testAddress = "0xdAC17F958D2ee523a2206206994597C13D831ec7"
+
+type mockBackend struct {
+	getCurrentBlockFunc  func() int
+	subscribeAddressFunc func(address string) error
+	addHandlerFunc       func(address string, handler ethereum.EventHandler) (func(), <-chan struct{}, error)
+	unsubscribeFunc      func(address string) error
+	subscriptionsFunc    func() []string
+	getTransactionsFunc  func(address string) []ethereum.Transaction
+}
+
+func (m *mockBackend) GetCurrentBlock() int {
+	if m.getCurrentBlockFunc != nil {
+		return m.getCurrentBlockFunc()
+	}
+
+	return 0
+}
+
+func (m *mockBackend) SubscribeAddress(address string) error {
+	if m.subscribeAddressFunc != nil {
+		return m.subscribeAddressFunc(address)
+	}
+
+	return nil
+}
+
+func (m *mockBackend) AddHandler(address string, handler ethereum.EventHandler, opts ...ethereum.HandlerOption) (func(), <-chan struct{}, error) {
+	if m.addHandlerFunc != nil {
+		return m.addHandlerFunc(address, handler)
+	}
+
+	return func() {}, nil, nil
+}
+
+func (m *mockBackend) Unsubscribe(address string) error {
+	if m.unsubscribeFunc != nil {
+		return m.unsubscribeFunc(address)
+	}
+
+	return nil
+}
+
+func (m *mockBackend) Subscriptions() []string {
+	if m.subscriptionsFunc != nil {
+		return m.subscriptionsFunc()
+	}
+
+	return nil
+}
+
+func (m *mockBackend) GetTransactions(address string) []ethereum.Transaction {
+	if m.getTransactionsFunc != nil {
+		return m.getTransactionsFunc(address)
+	}
+
+	return nil
+}
+
+func TestServer(t *testing.T) {
+	backend := &mockBackend{
+		getCurrentBlockFunc: func() int { return 16 },
+		subscribeAddressFunc: func(address string) error {
+			if address != testAddress {
+				t.Errorf("SubscribeAddress(%v), want normalized %v", address, testAddress)
+			}
+
+			return nil
+		},
+		unsubscribeFunc: func(address string) error {
+			if !strings.EqualFold(address, testAddress) {
+				return ethereum.ErrNotSubscribed
+			}
+
+			return nil
+		},
+		subscriptionsFunc: func() []string { return []string{testAddress} },
+		getTransactionsFunc: func(address string) []ethereum.Transaction {
+			return []ethereum.Transaction{
+				{Hash: "0x1", BlockNumber: "0x1", From: testAddress},
+				{Hash: "0x2", BlockNumber: "0x2"},
+				{Hash: "0x3", BlockNumber: "0x3", From: testAddress},
+			}
+		},
+	}
+
+	server, err := NewServer(map[string]service.Backend{"mainnet": backend}, "mainnet", clogger.Logger)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		method     string
+		target     string
+		body       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "current block",
+			method:     http.MethodGet,
+			target:     "/blocks/current",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"network":"mainnet","block":16}`,
+		},
+		{
+			name:       "unknown network",
+			method:     http.MethodGet,
+			target:     "/blocks/current?network=sepolia",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"unknown network \"sepolia\", known: [mainnet]"}`,
+		},
+		{
+			name:       "subscribe already subscribed",
+			method:     http.MethodPost,
+			target:     "/subscriptions",
+			body:       `{"address":"` + strings.ToLower(testAddress) + `"}`,
+			wantStatus: http.StatusOK,
+			wantBody:   `{"network":"mainnet","address":"` + testAddress + `"}`,
+		},
+		{
+			name:       "subscribe invalid address",
+			method:     http.MethodPost,
+			target:     "/subscriptions",
+			body:       `{"address":"0x123"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "subscribe unknown field",
+			method:     http.MethodPost,
+			target:     "/subscriptions",
+			body:       `{"addr":"` + testAddress + `"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unsubscribe",
+			method:     http.MethodDelete,
+			target:     "/subscriptions/" + testAddress,
+			wantStatus: http.StatusNoContent,
+		},
+		{
+			name:       "unsubscribe not subscribed",
+			method:     http.MethodDelete,
+			target:     "/subscriptions/0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+			wantStatus: http.StatusNotFound,
+			wantBody:   `{"error":"address not subscribed"}`,
+		},
+		{
+			name:       "transactions page",
+			method:     http.MethodGet,
+			target:     "/addresses/" + testAddress + "/transactions?limit=1&offset=1",
+			wantStatus: http.StatusOK,
+			wantBody:   `"total":3,"nextOffset":2}`,
+		},
+		{
+			name:       "transactions filtered",
+			method:     http.MethodGet,
+			target:     "/addresses/" + testAddress + "/transactions?from=" + testAddress + "&fromBlock=2",
+			wantStatus: http.StatusOK,
+			wantBody:   `"total":1,"nextOffset":null}`,
+		},
+		{
+			name:       "transactions invalid limit",
+			method:     http.MethodGet,
+			target:     "/addresses/" + testAddress + "/transactions?limit=1001",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			server.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body)))
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %v, want %v, body %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+
+			if body := strings.TrimSpace(rec.Body.String()); !strings.HasSuffix(body, tt.wantBody) {
+				t.Errorf("body = %v, want suffix %v", body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestServer_OpenAPI(t *testing.T) {
+	server, _ := NewServer(map[string]service.Backend{"mainnet": &mockBackend{}}, "mainnet", clogger.Logger)
+
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI string                               `json:"openapi"`
+		Paths   map[string]map[string]map[string]any `json:"paths"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("unmarshal openapi.json error = %v", err)
+	}
+
+	// every route is documented
+	for _, r := range server.routes {
+		op, ok := doc.Paths[r.Path][strings.ToLower(r.Method)]
+		if !ok {
+			t.Errorf("%s %s is not documented", r.Method, r.Path)
+			continue
+		}
+
+		if len(op["responses"].(map[string]any)) != len(r.Responses) {
+			t.Errorf("%s %s responses = %v, want %v", r.Method, r.Path, op["responses"], len(r.Responses))
+		}
+	}
+
+	if doc.OpenAPI != OpenAPIVersion {
+		t.Errorf("openapi = %v, want %v", doc.OpenAPI, OpenAPIVersion)
+	}
+}
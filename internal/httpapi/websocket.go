@@ -0,0 +1,334 @@
+package httpapi
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"tw/internal/service"
+)
+
+// websocketGUID is appended to the key of the handshake, see RFC 6455 section 1.3.
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const (
+	closeNormal     = 1000
+	closeGoingAway  = 1001
+	closeTryAgain   = 1013
+	wsWriteTimeout  = 10 * time.Second
+	wsMaxFrameBytes = 1 << 16
+)
+
+// wsConn is the server side of the WebSocket connection. Messages are
+// written and read whole, control frames are handled by readMessage.
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+
+	// mu guards the writes, reading goroutine answers the pings
+	mu     sync.Mutex
+	closed bool
+}
+
+// upgradeWebSocket checks the handshake and takes over the connection.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, badRequest("websocket upgrade is required")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		return nil, &apiError{status: http.StatusUpgradeRequired, message: "websocket version 13 is required"}
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, badRequest("invalid Sec-WebSocket-Key")
+	}
+
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("hijack connection: %w", err)
+	}
+
+	c := &wsConn{conn: conn, rw: rw}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+
+	if err := rw.Flush(); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// checkOrigin allows the websocket of the browser pages from the same host or
+// the allowed origins, other pages could use the cookies of the user. Requests
+// without Origin don't come from the browsers, they are allowed.
+func (s *Server) checkOrigin(r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+
+	for _, allowed := range s.allowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return nil
+		}
+	}
+
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+
+	return &apiError{status: http.StatusForbidden, message: "origin is not allowed"}
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// headerContains checks the comma separated header values for the token.
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, v := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// writeFrame writes the unfragmented frame, server frames are not masked.
+func (c *wsConn) writeFrame(opcode byte, payload []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return net.ErrClosed
+	}
+
+	header := []byte{0x80 | opcode}
+	switch {
+	case len(payload) < 126:
+		header = append(header, byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(len(payload)))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(len(payload)))
+	}
+
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	_, _ = c.rw.Write(header)
+	_, _ = c.rw.Write(payload)
+
+	return c.rw.Flush()
+}
+
+// readFrame reads the next frame from the client, client frames have to be masked.
+func (c *wsConn) readFrame() (bool, byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.rw, head[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := head[0]&0x80 != 0
+	opcode := head[0] & 0x0F
+	if head[1]&0x80 == 0 {
+		return false, 0, nil, errors.New("client frame is not masked")
+	}
+
+	length := uint64(head[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.rw, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > wsMaxFrameBytes {
+		return false, 0, nil, fmt.Errorf("frame of %d bytes is too big", length)
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.rw, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(c.rw, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// readMessage returns the next text or binary message, fragments are joined.
+// It answers the pings and returns io.EOF when the client closes the connection.
+func (c *wsConn) readMessage() (byte, []byte, error) {
+	var (
+		opcode  byte
+		message []byte
+	)
+
+	for {
+		fin, op, payload, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch op {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return 0, nil, err
+			}
+
+			continue
+		case opPong:
+			continue
+		case opClose:
+			return 0, nil, io.EOF
+		case opText, opBinary:
+			opcode, message = op, payload
+		case opContinuation:
+			message = append(message, payload...)
+		default:
+			return 0, nil, fmt.Errorf("unknown opcode %d", op)
+		}
+
+		if len(message) > wsMaxFrameBytes {
+			return 0, nil, fmt.Errorf("message of %d bytes is too big", len(message))
+		}
+
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+// close sends the close frame with the status and closes the connection.
+func (c *wsConn) close(status uint16, reason string) {
+	_ = c.writeFrame(opClose, append(binary.BigEndian.AppendUint16(nil, status), reason...))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	_ = c.conn.Close()
+}
+
+func (s *Server) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	if err := s.checkOrigin(r); err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	req, err := s.parseFeedRequest(r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	client, err := s.feed.Subscribe(req)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+	defer s.feed.Unsubscribe(req, client)
+
+	conn, err := upgradeWebSocket(w, r)
+	if err != nil {
+		s.writeError(w, err)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	reading := make(chan struct{})
+	go func() {
+		defer close(reading)
+		defer cancel()
+
+		// feed doesn't take any messages, they are read to notice the close
+		for {
+			if _, _, err := conn.readMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	send := func(event service.FeedEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		return conn.writeFrame(opText, data)
+	}
+
+	ping := func() error {
+		return conn.writeFrame(opPing, nil)
+	}
+
+	err = service.Stream(ctx, req, client, send, ping)
+
+	switch {
+	case errors.Is(err, service.ErrSlowClient):
+		conn.close(closeTryAgain, "client is not keeping up, resume with lastEventId")
+	case r.Context().Err() != nil:
+		// server is shutting down
+		conn.close(closeGoingAway, "")
+	default:
+		conn.close(closeNormal, "")
+	}
+
+	// reading goroutine ends with the connection
+	<-reading
+}
@@ -0,0 +1,148 @@
+// Package keccak implements the legacy Keccak-256 hash used by ethereum. It
+// differs from the standardized SHA3-256 only by the padding byte.
+package keccak
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+const (
+	// Size is the size of Keccak-256 checksum in bytes.
+	Size = 32
+	// rate is the number of bytes absorbed per permutation (1600 - 2*256 bits).
+	rate = 136
+)
+
+var roundConstants = [24]uint64{
+	0x0000000000000001, 0x0000000000008082, 0x800000000000808A, 0x8000000080008000,
+	0x000000000000808B, 0x0000000080000001, 0x8000000080008081, 0x8000000000008009,
+	0x000000000000008A, 0x0000000000000088, 0x0000000080008009, 0x000000008000000A,
+	0x000000008000808B, 0x800000000000008B, 0x8000000000008089, 0x8000000000008003,
+	0x8000000000008002, 0x8000000000000080, 0x000000000000800A, 0x800000008000000A,
+	0x8000000080008081, 0x8000000000008080, 0x0000000080000001, 0x8000000080008008,
+}
+
+var rotations = [25]int{
+	0, 1, 62, 28, 27,
+	36, 44, 6, 55, 20,
+	3, 10, 43, 25, 39,
+	41, 45, 15, 21, 8,
+	18, 2, 61, 56, 14,
+}
+
+// Sum256 returns Keccak-256 checksum of the data.
+func Sum256(data []byte) [Size]byte {
+	d := New256()
+	d.Write(data)
+
+	var sum [Size]byte
+	d.Sum(sum[:0])
+
+	return sum
+}
+
+type digest struct {
+	state [25]uint64
+	buf   [rate]byte
+	n     int
+}
+
+var _ hash.Hash = (*digest)(nil)
+
+// New256 creates a new Keccak-256 hash.
+func New256() hash.Hash {
+	return &digest{}
+}
+
+func (d *digest) Size() int { return Size }
+
+func (d *digest) BlockSize() int { return rate }
+
+func (d *digest) Reset() {
+	*d = digest{}
+}
+
+func (d *digest) Write(p []byte) (int, error) {
+	written := len(p)
+
+	for len(p) > 0 {
+		copied := copy(d.buf[d.n:], p)
+		d.n += copied
+		p = p[copied:]
+
+		if d.n == rate {
+			d.absorb()
+		}
+	}
+
+	return written, nil
+}
+
+// Sum appends the checksum to b, it doesn't change the state of the hash.
+func (d *digest) Sum(b []byte) []byte {
+	dup := *d
+
+	// keccak padding, sha3 would use 0x06 here
+	for i := dup.n; i < rate; i++ {
+		dup.buf[i] = 0
+	}
+	dup.buf[dup.n] ^= 0x01
+	dup.buf[rate-1] ^= 0x80
+	dup.n = rate
+	dup.absorb()
+
+	var out [Size]byte
+	for i := range Size / 8 {
+		binary.LittleEndian.PutUint64(out[i*8:], dup.state[i])
+	}
+
+	return append(b, out[:]...)
+}
+
+func (d *digest) absorb() {
+	for i := range rate / 8 {
+		d.state[i] ^= binary.LittleEndian.Uint64(d.buf[i*8:])
+	}
+
+	permute(&d.state)
+
+	d.n = 0
+}
+
+// permute is the Keccak-f[1600] permutation.
+func permute(a *[25]uint64) {
+	var c [5]uint64
+	var b [25]uint64
+
+	for round := range 24 {
+		// theta
+		for x := range 5 {
+			c[x] = a[x] ^ a[x+5] ^ a[x+10] ^ a[x+15] ^ a[x+20]
+		}
+		for x := range 5 {
+			d := c[(x+4)%5] ^ bits.RotateLeft64(c[(x+1)%5], 1)
+			for y := 0; y < 25; y += 5 {
+				a[x+y] ^= d
+			}
+		}
+
+		// rho and pi
+		for x := range 5 {
+			for y := range 5 {
+				b[y+5*((2*x+3*y)%5)] = bits.RotateLeft64(a[x+5*y], rotations[x+5*y])
+			}
+		}
+
+		// chi
+		for y := 0; y < 25; y += 5 {
+			for x := range 5 {
+				a[x+y] = b[x+y] ^ (^b[(x+1)%5+y] & b[(x+2)%5+y])
+			}
+		}
+
+		// iota
+		a[0] ^= roundConstants[round]
+	}
+}
@@ -0,0 +1,39 @@
+package keccak
+
+import (
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestSum256(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "empty input",
+			input: "",
+			want:  "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470",
+		},
+		{
+			name:  "short input",
+			input: "abc",
+			want:  "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45",
+		},
+		{
+			name:  "input longer than rate",
+			input: strings.Repeat("a", 200),
+			want:  "96ea54061def936c4be90b518992fdc6f12f535068a256229aca54267b4d084d",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sum := Sum256([]byte(tt.input))
+			if got := hex.EncodeToString(sum[:]); got != tt.want {
+				t.Errorf("Sum256() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
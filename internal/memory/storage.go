@@ -1,6 +1,7 @@
 package memory
 
 import (
+	"sort"
 	"sync"
 
 	"tw/internal/ethereum"
@@ -16,6 +17,7 @@ type TransactionMemoryStorage struct {
 }
 
 var _ ethereum.TransactionsStorage = (*TransactionMemoryStorage)(nil)
+var _ ethereum.AddressLister = (*TransactionMemoryStorage)(nil)
 
 func NewMemoryTransactionStorage() *TransactionMemoryStorage {
 	return &TransactionMemoryStorage{
@@ -52,3 +54,18 @@ func (ts *TransactionMemoryStorage) GetTransactionsForAddress(address string) []
 
 	return v
 }
+
+// Addresses returns the stored addresses, sorted.
+func (ts *TransactionMemoryStorage) Addresses() []string {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	addresses := make([]string, 0, len(ts.transactionsMap))
+	for address := range ts.transactionsMap {
+		addresses = append(addresses, address)
+	}
+
+	sort.Strings(addresses)
+
+	return addresses
+}
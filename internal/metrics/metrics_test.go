@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_WriteText(t *testing.T) {
+	r := NewRegistry()
+
+	requests := r.NewCounter("tw_requests_total", "Requests by method.", "method")
+	requests.Inc("eth_blockNumber")
+	requests.Add(2, "eth_blockNumber")
+	requests.Inc(`say "hi"`)
+
+	depth := r.NewGauge("tw_queue_depth", "Queue depth.", "address")
+	depth.Set(5, "0x1")
+	depth.Set(3, "0x2")
+	depth.Delete("0x2")
+
+	duration := r.NewHistogram("tw_duration_seconds", "Duration.", []float64{1, 0.1})
+	duration.Observe(0.05)
+	duration.Observe(0.5)
+	duration.Observe(5)
+
+	var b strings.Builder
+	if err := r.WriteText(&b); err != nil {
+		t.Fatalf("WriteText() error = %v", err)
+	}
+
+	want := `# HELP tw_duration_seconds Duration.
+# TYPE tw_duration_seconds histogram
+tw_duration_seconds_bucket{le="0.1"} 1
+tw_duration_seconds_bucket{le="1"} 2
+tw_duration_seconds_bucket{le="+Inf"} 3
+tw_duration_seconds_sum 5.55
+tw_duration_seconds_count 3
+# HELP tw_queue_depth Queue depth.
+# TYPE tw_queue_depth gauge
+tw_queue_depth{address="0x1"} 5
+# HELP tw_requests_total Requests by method.
+# TYPE tw_requests_total counter
+tw_requests_total{method="eth_blockNumber"} 3
+tw_requests_total{method="say \"hi\""} 1
+`
+	if got := b.String(); got != want {
+		t.Errorf("WriteText() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("tw_blocks_total", "Blocks.").Inc()
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if got := rec.Header().Get("Content-Type"); got != ContentType {
+		t.Errorf("Content-Type = %q, want %q", got, ContentType)
+	}
+
+	if !strings.Contains(rec.Body.String(), "tw_blocks_total 1\n") {
+		t.Errorf("body = %q, want tw_blocks_total 1", rec.Body.String())
+	}
+}
+
+func TestRegistry_DuplicateName(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("tw_head_block", "Head.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("second NewCounter() didn't panic")
+		}
+	}()
+
+	r.NewCounter("tw_head_block", "Head.")
+}
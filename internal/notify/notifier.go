@@ -0,0 +1,261 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+const (
+	DefaultMaxAttempts = 10
+	DefaultBackoff     = time.Second
+	DefaultMaxBackoff  = 10 * time.Minute
+)
+
+// NotifierOption configures the retries of the Notifier.
+type NotifierOption func(n *Notifier)
+
+// WithMaxAttempts sets how many times delivery is attempted before it fails, default is 10.
+func WithMaxAttempts(maxAttempts int) NotifierOption {
+	return func(n *Notifier) {
+		n.maxAttempts = maxAttempts
+	}
+}
+
+// WithBackoff sets the time to wait before the first retry, it is doubled with
+// each attempt up to the max backoff. Default is 1s and 10m.
+func WithBackoff(backoff, maxBackoff time.Duration) NotifierOption {
+	return func(n *Notifier) {
+		n.backoff = backoff
+		n.maxBackoff = maxBackoff
+	}
+}
+
+// Notifier delivers the events to the webhook through the outbox. Handle only
+// stores the delivery, so the parser isn't held by the slow webhook, and
+// deliveries are retried with exponential backoff until they succeed or run
+// out of attempts. Pending deliveries are picked up again after the restart.
+type Notifier struct {
+	webhook     *Webhook
+	outbox      *Outbox
+	logger      *slog.Logger
+	maxAttempts int
+	backoff     time.Duration
+	maxBackoff  time.Duration
+
+	// wake tells the loop there is a new delivery
+	wake chan struct{}
+	// stop stops the loop after the current delivery
+	stop     chan struct{}
+	stopOnce sync.Once
+	// ctx of the requests, it is canceled when shutdown times out
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	startOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+var _ ethereum.Lifecycle = (*Notifier)(nil)
+
+// NewNotifier creates a new instance of Notifier, it delivers when it is started.
+func NewNotifier(webhook *Webhook, outbox *Outbox, logger *slog.Logger, opts ...NotifierOption) *Notifier {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	n := &Notifier{
+		webhook:     webhook,
+		outbox:      outbox,
+		logger:      logger,
+		maxAttempts: DefaultMaxAttempts,
+		backoff:     DefaultBackoff,
+		maxBackoff:  DefaultMaxBackoff,
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		ctx:         ctx,
+		cancel:      cancel,
+	}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	return n
+}
+
+// Outbox returns the outbox with the deliveries and their attempts.
+func (n *Notifier) Outbox() *Outbox {
+	return n.outbox
+}
+
+// Handler returns handler which stores the events of the network to be delivered,
+// it fails only when outbox can't store them. Handlers of the networks share the outbox.
+func (n *Notifier) Handler(network string) ethereum.EventHandler {
+	return func(ctx context.Context, event ethereum.Event) error {
+		payload, err := json.Marshal(NewPayload(network, event))
+		if err != nil {
+			return fmt.Errorf("json marshal event: %w", err)
+		}
+
+		now := time.Now()
+		d := Delivery{
+			ID:          newDeliveryID(),
+			Webhook:     n.webhook.String(),
+			Payload:     payload,
+			Status:      Pending,
+			CreatedAt:   now,
+			NextAttempt: now,
+		}
+
+		if err := n.outbox.save(d); err != nil {
+			return err
+		}
+
+		select {
+		case n.wake <- struct{}{}:
+		default:
+		}
+
+		return nil
+	}
+}
+
+// Start starts delivering, including the deliveries pending from the previous run.
+func (n *Notifier) Start() error {
+	n.startOnce.Do(func() {
+		n.wg.Add(1)
+		go n.run()
+	})
+
+	return nil
+}
+
+// Shutdown lets the current delivery finish, pending ones are left in the outbox.
+func (n *Notifier) Shutdown(ctx context.Context) error {
+	n.stopOnce.Do(func() { close(n.stop) })
+	defer n.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		n.cancel()
+		<-done
+
+		return fmt.Errorf("webhook %s: %w", n.webhook, ethereum.ErrShutdownTimeout)
+	}
+}
+
+func (n *Notifier) run() {
+	defer n.wg.Done()
+
+	for {
+		next, stopped := n.deliverDue()
+		if stopped {
+			return
+		}
+
+		// there is nothing to wait for, until the next Handle
+		wait := time.Hour
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-n.stop:
+			timer.Stop()
+			return
+		case <-n.wake:
+		case <-timer.C:
+		}
+
+		timer.Stop()
+	}
+}
+
+// deliverDue attempts the due deliveries in order, it returns the time of the next
+// attempt of the ones that are not due yet, zero if there aren't any. Deliveries
+// of the other webhooks are skipped, they aren't posted to this one.
+func (n *Notifier) deliverDue() (time.Time, bool) {
+	var next time.Time
+	for _, d := range n.outbox.Deliveries(Pending) {
+		select {
+		case <-n.stop:
+			return next, true
+		default:
+		}
+
+		if d.Webhook != n.webhook.String() {
+			continue
+		}
+
+		if time.Now().Before(d.NextAttempt) {
+			if next.IsZero() || d.NextAttempt.Before(next) {
+				next = d.NextAttempt
+			}
+
+			continue
+		}
+
+		d = n.attempt(d)
+		if d.Status == Pending && (next.IsZero() || d.NextAttempt.Before(next)) {
+			next = d.NextAttempt
+		}
+	}
+
+	return next, false
+}
+
+func (n *Notifier) attempt(d Delivery) Delivery {
+	start := time.Now()
+	statusCode, err := n.webhook.post(n.ctx, d.ID, d.Payload)
+	if err != nil && n.ctx.Err() != nil {
+		// request was given up by the shutdown, not by the webhook
+		return d
+	}
+
+	a := Attempt{At: start, Duration: time.Since(start), StatusCode: statusCode}
+	if err != nil {
+		a.Error = err.Error()
+	}
+
+	d.Attempts = append(d.Attempts, a)
+
+	switch {
+	case err == nil:
+		d.Status = Delivered
+	case len(d.Attempts) >= n.maxAttempts:
+		d.Status = Failed
+		n.logger.Error("webhook delivery failed", "webhook", d.Webhook, "delivery", d.ID, "attempts", len(d.Attempts), "err", err)
+	default:
+		d.NextAttempt = time.Now().Add(n.retryBackoff(len(d.Attempts)))
+	}
+
+	if err := n.outbox.save(d); err != nil {
+		n.logger.Error("save webhook delivery failed", "webhook", d.Webhook, "delivery", d.ID, "err", err)
+	}
+
+	return d
+}
+
+// retryBackoff returns the time to wait after the attempt, it doubles with each attempt.
+func (n *Notifier) retryBackoff(attempts int) time.Duration {
+	backoff := n.backoff
+	for i := 1; i < attempts && backoff < n.maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	return min(backoff, n.maxBackoff)
+}
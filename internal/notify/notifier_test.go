@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+)
+
+func TestNotifier_DeliversPendingAfterRestart(t *testing.T) {
+	secret := []byte("s3cr3t")
+
+	var (
+		mu          sync.Mutex
+		requests    int
+		deliveryIDs []string
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests++
+		deliveryIDs = append(deliveryIDs, r.Header.Get(DeliveryHeader))
+
+		if err := Verify(secret, r.Header, body, time.Minute); err != nil {
+			t.Errorf("Verify() error = %v", err)
+		}
+
+		// network is part of the signed body
+		if !strings.Contains(string(body), `"network":"mainnet"`) {
+			t.Errorf("body = %s, want the network", body)
+		}
+
+		// first attempt fails, so the delivery is retried
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	endpoint, _ := url.Parse(server.URL)
+	webhook := NewWebhook(endpoint, string(secret), server.Client())
+	dir := t.TempDir()
+
+	// first notifier is not started, the delivery stays in the outbox
+	outbox, err := OpenOutbox(dir)
+	if err != nil {
+		t.Fatalf("OpenOutbox() error = %v", err)
+	}
+
+	stopped := NewNotifier(webhook, outbox, clogger.Logger)
+	if err := stopped.Handler("mainnet")(context.Background(), ethereum.TransactionObserved{Address: "0x1"}); err != nil {
+		t.Fatalf("Handle() error = %v", err)
+	}
+
+	_ = stopped.Shutdown(context.Background())
+
+	outbox, err = OpenOutbox(dir)
+	if err != nil {
+		t.Fatalf("OpenOutbox() error = %v", err)
+	}
+
+	notifier := NewNotifier(webhook, outbox, clogger.Logger, WithBackoff(time.Millisecond, time.Millisecond))
+	_ = notifier.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(outbox.Deliveries(Delivered)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries = %+v, want delivered", outbox.Deliveries(""))
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := notifier.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() error = %v", err)
+	}
+
+	d := outbox.Deliveries(Delivered)[0]
+	if len(d.Attempts) != 2 || d.Attempts[0].StatusCode != http.StatusServiceUnavailable || d.Attempts[1].StatusCode != http.StatusOK {
+		t.Errorf("attempts = %+v, want 503 and 200", d.Attempts)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	for _, id := range deliveryIDs {
+		if id != d.ID {
+			t.Errorf("%s = %v, want %v", DeliveryHeader, id, d.ID)
+		}
+	}
+
+	// attempts are kept on the disk
+	reopened, _ := OpenOutbox(dir)
+	if got, ok := reopened.Delivery(d.ID); !ok || got.Status != Delivered || len(got.Attempts) != 2 {
+		t.Errorf("reopened delivery = %+v, want delivered with 2 attempts", got)
+	}
+}
+
+func TestNotifier_retryBackoff(t *testing.T) {
+	n := NewNotifier(nil, NewMemoryOutbox(), clogger.Logger, WithBackoff(time.Second, 5*time.Second))
+
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{attempts: 1, want: time.Second},
+		{attempts: 2, want: 2 * time.Second},
+		{attempts: 3, want: 4 * time.Second},
+		{attempts: 4, want: 5 * time.Second},
+		{attempts: 100, want: 5 * time.Second},
+	}
+
+	for _, tt := range tests {
+		if got := n.retryBackoff(tt.attempts); got != tt.want {
+			t.Errorf("retryBackoff(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestNotifier_SkipsDeliveriesOfOtherWebhooks(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+	}))
+	defer server.Close()
+
+	endpoint, _ := url.Parse(server.URL)
+	other, _ := url.Parse("https://hooks.example.com/other")
+
+	outbox, err := OpenOutbox(t.TempDir())
+	if err != nil {
+		t.Fatalf("OpenOutbox() error = %v", err)
+	}
+
+	// delivery of the other webhook in the same outbox
+	stopped := NewNotifier(NewWebhook(other, "other", server.Client()), outbox, clogger.Logger)
+	_ = stopped.Handler("mainnet")(context.Background(), ethereum.TransactionObserved{Address: "0x1"})
+
+	notifier := NewNotifier(NewWebhook(endpoint, "s3cr3t", server.Client()), outbox, clogger.Logger)
+	_ = notifier.Handler("mainnet")(context.Background(), ethereum.TransactionObserved{Address: "0x2"})
+	_ = notifier.Start()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(outbox.Deliveries(Delivered)) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("deliveries = %+v, want delivered", outbox.Deliveries(""))
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	_ = notifier.Shutdown(context.Background())
+
+	if got := requests.Load(); got != 1 {
+		t.Errorf("requests = %v, want 1", got)
+	}
+
+	if pending := outbox.Deliveries(Pending); len(pending) != 1 || pending[0].Webhook != other.String() {
+		t.Errorf("pending = %+v, want the delivery of the other webhook", pending)
+	}
+}
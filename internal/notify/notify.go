@@ -0,0 +1,42 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"tw/internal/ethereum"
+)
+
+// Payload is the json body sent to the sinks.
+type Payload struct {
+	// Network is the name of the network the event comes from, one
+	// sink gets the events of all the networks.
+	Network string `json:"network"`
+	// Type is the name of the event type, i.e. TransactionObserved.
+	Type  string         `json:"type"`
+	Event ethereum.Event `json:"event"`
+}
+
+// NewPayload wraps the event of the network, so the receiver knows its type and network.
+func NewPayload(network string, event ethereum.Event) Payload {
+	return Payload{
+		Network: network,
+		Type:    ethereum.EventName(event),
+		Event:   event,
+	}
+}
+
+// LogHandler returns handler which logs the events of the network as json.
+func LogHandler(logger *slog.Logger, network string) ethereum.EventHandler {
+	return func(ctx context.Context, event ethereum.Event) error {
+		body, err := json.Marshal(NewPayload(network, event))
+		if err != nil {
+			return err
+		}
+
+		logger.Info("event", "network", network, "type", ethereum.EventName(event), "address", event.EventAddress(), "event", json.RawMessage(body))
+
+		return nil
+	}
+}
@@ -0,0 +1,221 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultRetention is how many finished deliveries outbox keeps for debugging.
+const DefaultRetention = 1000
+
+// DeliveryStatus is the state of the delivery.
+type DeliveryStatus string
+
+const (
+	// Pending deliveries are retried until they succeed or run out of attempts.
+	Pending   DeliveryStatus = "pending"
+	Delivered DeliveryStatus = "delivered"
+	// Failed deliveries ran out of attempts, they are not retried anymore.
+	Failed DeliveryStatus = "failed"
+)
+
+// Delivery is the payload posted to the webhook with its attempts.
+type Delivery struct {
+	ID      string          `json:"id"`
+	Webhook string          `json:"webhook"`
+	Payload json.RawMessage `json:"payload"`
+	Status  DeliveryStatus  `json:"status"`
+	// Attempts are in order, the last one is the latest.
+	Attempts    []Attempt `json:"attempts"`
+	CreatedAt   time.Time `json:"createdAt"`
+	NextAttempt time.Time `json:"nextAttempt"`
+}
+
+// Attempt is the one request of the delivery.
+type Attempt struct {
+	At       time.Time     `json:"at"`
+	Duration time.Duration `json:"duration"`
+	// StatusCode is the response status, 0 when there was no response.
+	StatusCode int    `json:"statusCode"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Outbox keeps the deliveries, each in its own json file in the directory, so
+// pending deliveries survive the restarts. Finished deliveries are kept up to
+// the retention, so the attempts can be looked at later.
+type Outbox struct {
+	// dir is empty for the in memory outbox
+	dir        string
+	retention  int
+	deliveries map[string]Delivery
+
+	mu sync.Mutex
+}
+
+// NewMemoryOutbox creates outbox that doesn't survive the restarts.
+func NewMemoryOutbox() *Outbox {
+	return &Outbox{
+		retention:  DefaultRetention,
+		deliveries: make(map[string]Delivery),
+	}
+}
+
+// OpenOutbox opens (or creates) the outbox directory and loads deliveries from it.
+func OpenOutbox(dir string) (*Outbox, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("create outbox dir: %w", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	o := NewMemoryOutbox()
+	o.dir = dir
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("read outbox file: %w", err)
+		}
+
+		var d Delivery
+		if err := json.Unmarshal(data, &d); err != nil {
+			return nil, fmt.Errorf("outbox file %s: %w", filepath.Base(path), err)
+		}
+
+		o.deliveries[d.ID] = d
+	}
+
+	return o, nil
+}
+
+// Deliveries returns deliveries with the status, oldest first. Empty status means all.
+func (o *Outbox) Deliveries(status DeliveryStatus) []Delivery {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	var deliveries []Delivery
+	for _, d := range o.deliveries {
+		if status == "" || d.Status == status {
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	sortDeliveries(deliveries)
+
+	return deliveries
+}
+
+// Delivery returns the delivery by its id.
+func (o *Outbox) Delivery(id string) (Delivery, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	d, ok := o.deliveries[id]
+
+	return d, ok
+}
+
+// save stores the delivery, finished ones over the retention are removed.
+func (o *Outbox) save(d Delivery) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.write(d); err != nil {
+		return err
+	}
+
+	o.deliveries[d.ID] = d
+
+	if d.Status != Pending {
+		return o.prune()
+	}
+
+	return nil
+}
+
+// write replaces the delivery file, so it's never left half written.
+func (o *Outbox) write(d Delivery) error {
+	if o.dir == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Errorf("json marshal delivery: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(o.dir, "tmp-*")
+	if err != nil {
+		return fmt.Errorf("create outbox file: %w", err)
+	}
+
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Sync()
+	}
+
+	err = errors.Join(err, tmp.Close())
+	if err == nil {
+		err = os.Rename(tmp.Name(), o.path(d.ID))
+	}
+
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write outbox file: %w", err)
+	}
+
+	return nil
+}
+
+func (o *Outbox) prune() error {
+	var finished []Delivery
+	for _, d := range o.deliveries {
+		if d.Status != Pending {
+			finished = append(finished, d)
+		}
+	}
+
+	if len(finished) <= o.retention {
+		return nil
+	}
+
+	sortDeliveries(finished)
+
+	var errs []error
+	for _, d := range finished[:len(finished)-o.retention] {
+		delete(o.deliveries, d.ID)
+
+		if o.dir != "" {
+			if err := os.Remove(o.path(d.ID)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				errs = append(errs, fmt.Errorf("remove outbox file: %w", err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (o *Outbox) path(id string) string {
+	// ids are generated, but files are never created outside the dir
+	return filepath.Join(o.dir, strings.ReplaceAll(id, string(filepath.Separator), "_")+".json")
+}
+
+func sortDeliveries(deliveries []Delivery) {
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].ID < deliveries[j].ID
+		}
+
+		return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+	})
+}
@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+const (
+	// SignatureHeader is the header with the HMAC-SHA256 of the timestamp and the
+	// body, in the form "sha256=<hex>". It's set only when webhook has a secret.
+	SignatureHeader = "X-TW-Signature"
+	// TimestampHeader is the unix time of the request, it is signed with the body,
+	// so receivers can reject the replayed requests.
+	TimestampHeader = "X-TW-Timestamp"
+	// DeliveryHeader is the id of the delivery, it's the same for all the
+	// attempts, so receivers can skip the duplicates.
+	DeliveryHeader = "X-TW-Delivery"
+)
+
+var ErrInvalidSignature = errors.New("invalid signature")
+
+// Webhook posts events as json to the url.
+type Webhook struct {
+	endpoint   *url.URL
+	secret     []byte
+	httpClient *http.Client
+}
+
+// NewWebhook creates a new instance of Webhook, empty secret means requests are not signed.
+func NewWebhook(endpoint *url.URL, secret string, httpClient *http.Client) *Webhook {
+	return &Webhook{
+		endpoint:   endpoint,
+		secret:     []byte(secret),
+		httpClient: httpClient,
+	}
+}
+
+// String returns webhook url with the secrets redacted.
+func (w *Webhook) String() string {
+	return ethereum.RedactURL(w.endpoint)
+}
+
+// Handler returns handler which posts the events of the network, any response
+// other than 2xx is an error, so the handler is retried. Use Notifier to
+// retry the deliveries that have to survive restarts.
+func (w *Webhook) Handler(network string) ethereum.EventHandler {
+	return func(ctx context.Context, event ethereum.Event) error {
+		body, err := json.Marshal(NewPayload(network, event))
+		if err != nil {
+			return fmt.Errorf("json marshal event: %w", err)
+		}
+
+		_, err = w.post(ctx, newDeliveryID(), body)
+
+		return err
+	}
+}
+
+// post sends the body, it returns the response status, 0 if there is no response.
+func (w *Webhook) post(ctx context.Context, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.endpoint.String(), bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("new http request: %w", ethereum.RedactURLError(err, w.endpoint))
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(DeliveryHeader, deliveryID)
+	req.Header.Set(TimestampHeader, timestamp)
+
+	if len(w.secret) > 0 {
+		req.Header.Set(SignatureHeader, "sha256="+Sign(w.secret, timestamp, body))
+	}
+
+	res, err := w.httpClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("http client do request: %w", ethereum.RedactURLError(err, w.endpoint))
+	}
+	defer res.Body.Close()
+
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("webhook responded with status %d", res.StatusCode)
+	}
+
+	return res.StatusCode, nil
+}
+
+// Sign returns hex encoded HMAC-SHA256 of "<timestamp>.<body>".
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks the signature and timestamp headers of the received webhook, the
+// timestamp has to be within the tolerance from now. It's meant for the receivers.
+func Verify(secret []byte, header http.Header, body []byte, tolerance time.Duration) error {
+	timestamp := header.Get(TimestampHeader)
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid timestamp %q", ErrInvalidSignature, timestamp)
+	}
+
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("%w: timestamp is %s off", ErrInvalidSignature, age.Round(time.Second))
+	}
+
+	want := "sha256=" + Sign(secret, timestamp, body)
+	if !hmac.Equal([]byte(header.Get(SignatureHeader)), []byte(want)) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
+
+func newDeliveryID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,306 @@
+package ratelimit
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+const (
+	methodBlockNumber      = "eth_blockNumber"
+	methodGetBlockByNumber = "eth_getBlockByNumber"
+	methodChainID          = "eth_chainId"
+
+	defaultDegradeAt = 0.8
+)
+
+// ErrClosed is returned when request is done on the closed wrapper.
+var ErrClosed = errors.New("rate limited api wrapper closed")
+
+// DefaultComputeUnits are compute units used for the json rpc methods
+// when nothing else is configured. They are based on the pricing
+// of the most popular rpc providers.
+var DefaultComputeUnits = map[string]uint64{
+	methodBlockNumber:      10,
+	methodGetBlockByNumber: 16,
+	methodChainID:          0,
+}
+
+// Config describes how RateLimitedApiWrapper limits the requests.
+type Config struct {
+	// RequestsPerSecond is the sustained amount of requests done to the api.
+	// Zero disables the limit.
+	RequestsPerSecond float64
+	// Burst is the number of requests that can be done at once.
+	Burst int
+	// DailyBudget is the amount of compute units that can be used
+	// during one day. Zero disables the budget.
+	DailyBudget uint64
+	// MonthlyBudget is the amount of compute units that can be used
+	// during one month. Zero disables the budget.
+	MonthlyBudget uint64
+	// ComputeUnits are the weights of the json rpc methods. If method is
+	// missing it costs one unit. Nil means DefaultComputeUnits.
+	ComputeUnits map[string]uint64
+	// DegradeAt is the fraction of the budget after which wrapper goes into
+	// degraded mode and starts to slow down the requests, so the budget
+	// lasts till the end of the period. Zero means 0.8.
+	DegradeAt float64
+}
+
+// DefaultConfig is safe to use with the public endpoints.
+var DefaultConfig = Config{
+	RequestsPerSecond: 5,
+	Burst:             5,
+}
+
+// Metrics is the snapshot of the RateLimitedApiWrapper counters.
+type Metrics struct {
+	// Requests is the number of requests per json rpc method.
+	Requests map[string]uint64
+	// ComputeUnits is the sum of the compute units spent since start.
+	ComputeUnits uint64
+	// DailyUsed and MonthlyUsed are the compute units used in current periods.
+	DailyUsed   uint64
+	MonthlyUsed uint64
+	// Throttled is the number of requests that had to wait for a token.
+	Throttled uint64
+	// ThrottledTime is the time spent waiting for the tokens.
+	ThrottledTime time.Duration
+	// DegradedRequests is the number of requests slowed down because of the budget.
+	DegradedRequests uint64
+	// DegradedTime is the time spent waiting because of the budget.
+	DegradedTime time.Duration
+	// Degraded is true if the last request was done in degraded mode.
+	Degraded bool
+}
+
+// RateLimitedApiWrapper wraps ApiWrapper and limits the requests
+// done to the api with token bucket, as well as keeps track of
+// the daily and monthly compute units budget. Instead of failing
+// when the budget is close to be used, it slows the requests down.
+type RateLimitedApiWrapper struct {
+	apiWrapper   ethereum.ApiWrapper
+	bucket       *TokenBucket
+	budgets      []*Budget
+	computeUnits map[string]uint64
+
+	now   func() time.Time
+	sleep func(d time.Duration, closeChan <-chan struct{}) bool
+
+	metrics   Metrics
+	metricsMu sync.Mutex
+
+	closeChan chan struct{}
+	closeOnce sync.Once
+}
+
+var _ ethereum.ApiWrapper = (*RateLimitedApiWrapper)(nil)
+var _ io.Closer = (*RateLimitedApiWrapper)(nil)
+
+// NewRateLimitedApiWrapper creates a new instance of RateLimitedApiWrapper.
+func NewRateLimitedApiWrapper(apiWrapper ethereum.ApiWrapper, config Config) *RateLimitedApiWrapper {
+	return newRateLimitedApiWrapper(apiWrapper, config, time.Now, sleep)
+}
+
+func newRateLimitedApiWrapper(
+	apiWrapper ethereum.ApiWrapper,
+	config Config,
+	now func() time.Time,
+	sleep func(d time.Duration, closeChan <-chan struct{}) bool,
+) *RateLimitedApiWrapper {
+	degradeAt := config.DegradeAt
+	if degradeAt <= 0 {
+		degradeAt = defaultDegradeAt
+	}
+
+	computeUnits := config.ComputeUnits
+	if computeUnits == nil {
+		computeUnits = DefaultComputeUnits
+	}
+
+	return &RateLimitedApiWrapper{
+		apiWrapper: apiWrapper,
+		bucket:     newTokenBucket(config.RequestsPerSecond, config.Burst, now),
+		budgets: []*Budget{
+			NewBudget(Daily, config.DailyBudget, degradeAt),
+			NewBudget(Monthly, config.MonthlyBudget, degradeAt),
+		},
+		computeUnits: computeUnits,
+		now:          now,
+		sleep:        sleep,
+		metrics: Metrics{
+			Requests: make(map[string]uint64),
+		},
+		closeChan: make(chan struct{}),
+	}
+}
+
+// GetCurrentBlock waits for the limiter and calls wrapped api.
+func (r *RateLimitedApiWrapper) GetCurrentBlock(httpClient *http.Client) (string, error) {
+	if err := r.wait(methodBlockNumber); err != nil {
+		return "", err
+	}
+
+	return r.apiWrapper.GetCurrentBlock(httpClient)
+}
+
+// GetTransactionsForBlock waits for the limiter and calls wrapped api.
+func (r *RateLimitedApiWrapper) GetTransactionsForBlock(httpClient *http.Client, blockNum string) ([]ethereum.Transaction, error) {
+	if err := r.wait(methodGetBlockByNumber); err != nil {
+		return nil, err
+	}
+
+	return r.apiWrapper.GetTransactionsForBlock(httpClient, blockNum)
+}
+
+// GetChainID waits for the limiter and calls wrapped api.
+func (r *RateLimitedApiWrapper) GetChainID(httpClient *http.Client) (string, error) {
+	if err := r.wait(methodChainID); err != nil {
+		return "", err
+	}
+
+	return r.apiWrapper.GetChainID(httpClient)
+}
+
+// Metrics returns snapshot of the wrapper counters.
+func (r *RateLimitedApiWrapper) Metrics() Metrics {
+	r.metricsMu.Lock()
+	defer r.metricsMu.Unlock()
+
+	m := r.metrics
+	m.Requests = make(map[string]uint64, len(r.metrics.Requests))
+	for method, n := range r.metrics.Requests {
+		m.Requests[method] = n
+	}
+
+	now := r.now()
+	m.DailyUsed = r.budgets[Daily].Used(now)
+	m.MonthlyUsed = r.budgets[Monthly].Used(now)
+
+	return m
+}
+
+// Close wakes up all requests waiting for the limiter, they
+// and all the following requests return ErrClosed.
+func (r *RateLimitedApiWrapper) Close() error {
+	r.closeOnce.Do(func() {
+		close(r.closeChan)
+	})
+
+	return nil
+}
+
+// wait blocks until request for the given method can be done.
+func (r *RateLimitedApiWrapper) wait(method string) error {
+	select {
+	case <-r.closeChan:
+		return ErrClosed
+	default:
+	}
+
+	units, ok := r.computeUnits[method]
+	if !ok {
+		units = 1
+	}
+
+	// budget goes first, there is no point in taking token
+	// if we are going to wait for a long time anyway
+	degradedDelay, degraded, err := r.reserve(units)
+	if err != nil {
+		return err
+	}
+
+	throttledDelay := r.bucket.Reserve()
+	if throttledDelay > 0 && !r.sleep(throttledDelay, r.closeChan) {
+		r.refund(r.budgets, units)
+
+		return ErrClosed
+	}
+
+	r.metricsMu.Lock()
+	defer r.metricsMu.Unlock()
+
+	r.metrics.Requests[method]++
+	r.metrics.ComputeUnits += units
+	r.metrics.Degraded = degraded
+
+	if degradedDelay > 0 {
+		r.metrics.DegradedRequests++
+		r.metrics.DegradedTime += degradedDelay
+	}
+
+	if throttledDelay > 0 {
+		r.metrics.Throttled++
+		r.metrics.ThrottledTime += throttledDelay
+	}
+
+	return nil
+}
+
+// reserve reserves units in all the budgets and holds the request for as
+// long as they say. While any of the budgets is used, units reserved in the
+// others are given back and request waits for the next period. It returns
+// the total time request was held.
+func (r *RateLimitedApiWrapper) reserve(units uint64) (time.Duration, bool, error) {
+	var held time.Duration
+	for {
+		var delay time.Duration
+		degraded := false
+		reserved := make([]*Budget, 0, len(r.budgets))
+		for _, budget := range r.budgets {
+			budgetDelay, isDegraded, ok := budget.Delay(r.now(), units)
+			degraded = degraded || isDegraded
+			delay = max(delay, budgetDelay)
+
+			if ok {
+				reserved = append(reserved, budget)
+			}
+		}
+
+		done := len(reserved) == len(r.budgets)
+		if !done {
+			r.refund(reserved, units)
+		}
+
+		if delay > 0 && !r.sleep(delay, r.closeChan) {
+			if done {
+				r.refund(reserved, units)
+			}
+
+			return 0, false, ErrClosed
+		}
+
+		held += delay
+
+		if done {
+			return held, degraded, nil
+		}
+	}
+}
+
+// refund gives back units reserved for the request that was not done.
+func (r *RateLimitedApiWrapper) refund(budgets []*Budget, units uint64) {
+	now := r.now()
+	for _, budget := range budgets {
+		budget.Refund(now, units)
+	}
+}
+
+// sleep waits for given duration, it returns false if
+// it was woken up by the close chan.
+func sleep(d time.Duration, closeChan <-chan struct{}) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-closeChan:
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,259 @@
+package ratelimit
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	return f.now
+}
+
+func (f *fakeClock) Sleep(d time.Duration, _ <-chan struct{}) bool {
+	f.now = f.now.Add(d)
+
+	return true
+}
+
+func TestTokenBucket_Reserve(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)}
+	bucket := newTokenBucket(2, 2, clock.Now)
+
+	tests := []struct {
+		name    string
+		advance time.Duration
+		want    time.Duration
+	}{
+		{
+			name: "first token from burst, no wait",
+			want: 0,
+		},
+		{
+			name: "second token from burst, no wait",
+			want: 0,
+		},
+		{
+			name: "bucket is empty, waits for refill",
+			want: 500 * time.Millisecond,
+		},
+		{
+			name:    "bucket refilled after a second, no wait",
+			advance: 2 * time.Second,
+			want:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock.now = clock.now.Add(tt.advance)
+
+			if got := bucket.Reserve(); got != tt.want {
+				t.Errorf("Reserve() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBudget_Delay(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		limit        uint64
+		used         uint64
+		units        uint64
+		wantDelay    time.Duration
+		wantDegraded bool
+		wantUsed     uint64
+	}{
+		{
+			name:     "unlimited budget, no delay",
+			used:     1000,
+			units:    10,
+			wantUsed: 1010,
+		},
+		{
+			name:     "budget below threshold, no delay",
+			limit:    100,
+			used:     50,
+			units:    10,
+			wantUsed: 60,
+		},
+		{
+			name:         "budget above threshold, requests spread over rest of the day",
+			limit:        100,
+			used:         80,
+			units:        10,
+			wantDelay:    4 * time.Hour,
+			wantDegraded: true,
+			wantUsed:     90,
+		},
+		{
+			name:         "budget used, waits for next day",
+			limit:        100,
+			used:         95,
+			units:        10,
+			wantDelay:    12 * time.Hour,
+			wantDegraded: true,
+			wantUsed:     95,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			budget := NewBudget(Daily, tt.limit, 0.8)
+			budget.Spend(now, tt.used)
+
+			delay, degraded, reserved := budget.Delay(now, tt.units)
+			if delay != tt.wantDelay {
+				t.Errorf("Delay() delay = %v, want %v", delay, tt.wantDelay)
+			}
+
+			if degraded != tt.wantDegraded {
+				t.Errorf("Delay() degraded = %v, want %v", degraded, tt.wantDegraded)
+			}
+
+			if wantReserved := tt.wantUsed != tt.used; reserved != wantReserved {
+				t.Errorf("Delay() reserved = %v, want %v", reserved, wantReserved)
+			}
+
+			if got := budget.Used(now); got != tt.wantUsed {
+				t.Errorf("Used() = %v, want %v", got, tt.wantUsed)
+			}
+		})
+	}
+}
+
+func TestBudget_ConcurrentDelay(t *testing.T) {
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	budget := NewBudget(Daily, 100, 0.8)
+
+	var reserved atomic.Uint64
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if _, _, ok := budget.Delay(now, 3); ok {
+				reserved.Add(3)
+			}
+
+			if used := budget.Used(now); used > 100 {
+				t.Errorf("Used() = %v, want at most 100", used)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := budget.Used(now); got != reserved.Load() || got != 99 {
+		t.Errorf("Used() = %v, reserved = %v, want 99", got, reserved.Load())
+	}
+}
+
+func TestBudget_Rollover(t *testing.T) {
+	now := time.Date(2024, 5, 31, 23, 0, 0, 0, time.UTC)
+
+	budget := NewBudget(Monthly, 100, 0.8)
+	budget.Spend(now, 100)
+
+	if got := budget.Used(now.Add(2 * time.Hour)); got != 0 {
+		t.Errorf("Used() = %v, want 0 after month rollover", got)
+	}
+}
+
+func TestRateLimitedApiWrapper_DegradedModeSlowsInsteadOfFailing(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)}
+
+	calls := 0
+	apiWrapper := &mockApiWrapper{
+		getCurrentBlockFunc: func(httpClient *http.Client) (string, error) {
+			calls++
+
+			return "0x1", nil
+		},
+	}
+
+	wrapper := newRateLimitedApiWrapper(apiWrapper, Config{
+		DailyBudget: 50,
+		ComputeUnits: map[string]uint64{
+			methodBlockNumber: 10,
+		},
+	}, clock.Now, clock.Sleep)
+
+	for range 6 {
+		if _, err := wrapper.GetCurrentBlock(http.DefaultClient); err != nil {
+			t.Fatalf("GetCurrentBlock() error = %v", err)
+		}
+	}
+
+	if calls != 6 {
+		t.Errorf("calls = %v, want 6", calls)
+	}
+
+	metrics := wrapper.Metrics()
+	if metrics.DegradedRequests != 2 {
+		t.Errorf("DegradedRequests = %v, want 2", metrics.DegradedRequests)
+	}
+
+	if metrics.Requests[methodBlockNumber] != 6 {
+		t.Errorf("Requests = %v, want 6", metrics.Requests[methodBlockNumber])
+	}
+
+	// last request had to wait for the next day
+	if clock.now.Day() != 11 {
+		t.Errorf("now = %v, want next day", clock.now)
+	}
+}
+
+func TestRateLimitedApiWrapper_Close(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)}
+
+	wrapper := newRateLimitedApiWrapper(&mockApiWrapper{}, Config{
+		RequestsPerSecond: 1,
+		Burst:             1,
+	}, clock.Now, clock.Sleep)
+
+	_ = wrapper.Close()
+
+	if _, err := wrapper.GetCurrentBlock(http.DefaultClient); err != ErrClosed {
+		t.Errorf("GetCurrentBlock() error = %v, want %v", err, ErrClosed)
+	}
+}
+
+type mockApiWrapper struct {
+	getCurrentBlockFunc         func(httpClient *http.Client) (string, error)
+	getTransactionsForBlockFunc func(httpClient *http.Client, blockNum string) ([]ethereum.Transaction, error)
+	getChainIDFunc              func(httpClient *http.Client) (string, error)
+}
+
+func (m *mockApiWrapper) GetCurrentBlock(httpClient *http.Client) (string, error) {
+	if m.getCurrentBlockFunc != nil {
+		return m.getCurrentBlockFunc(httpClient)
+	}
+
+	return "", nil
+}
+
+func (m *mockApiWrapper) GetTransactionsForBlock(httpClient *http.Client, blockNum string) ([]ethereum.Transaction, error) {
+	if m.getTransactionsForBlockFunc != nil {
+		return m.getTransactionsForBlockFunc(httpClient, blockNum)
+	}
+
+	return nil, nil
+}
+
+func (m *mockApiWrapper) GetChainID(httpClient *http.Client) (string, error) {
+	if m.getChainIDFunc != nil {
+		return m.getChainIDFunc(httpClient)
+	}
+
+	return "", nil
+}
@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// TokenBucket is simple token bucket limiter. Tokens
+// are refilled with constant rate up to the burst size,
+// each request takes one token.
+type TokenBucket struct {
+	rate  float64
+	burst float64
+
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewTokenBucket creates a new instance of TokenBucket which allows
+// rate requests per second with bursts of up to burst requests.
+func NewTokenBucket(rate float64, burst int) *TokenBucket {
+	return newTokenBucket(rate, burst, time.Now)
+}
+
+func newTokenBucket(rate float64, burst int, now func() time.Time) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now(),
+		now:    now,
+	}
+}
+
+// Reserve takes one token from the bucket and returns how long
+// caller has to wait before the request can be done. Zero
+// means the request can be done right away.
+func (tb *TokenBucket) Reserve() time.Duration {
+	// rate 0 means there is no limit at all
+	if tb.rate <= 0 {
+		return 0
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	now := tb.now()
+
+	tb.tokens += now.Sub(tb.last).Seconds() * tb.rate
+	if tb.tokens > tb.burst {
+		tb.tokens = tb.burst
+	}
+
+	tb.last = now
+
+	// tokens can go below zero, which means that there are already
+	// some requests waiting for the tokens to be refilled
+	tb.tokens--
+	if tb.tokens >= 0 {
+		return 0
+	}
+
+	return time.Duration(-tb.tokens / tb.rate * float64(time.Second))
+}
@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Period is the period after which request budget is reset.
+type Period int
+
+const (
+	// Daily budget resets at midnight UTC.
+	Daily Period = iota
+	// Monthly budget resets at the first day of the month UTC.
+	Monthly
+)
+
+func (p Period) String() string {
+	switch p {
+	case Daily:
+		return "daily"
+	case Monthly:
+		return "monthly"
+	default:
+		return "unknown"
+	}
+}
+
+// start returns beginning of the period that t belongs to.
+func (p Period) start(t time.Time) time.Time {
+	t = t.UTC()
+
+	if p == Monthly {
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// end returns beginning of the next period after t.
+func (p Period) end(t time.Time) time.Time {
+	if p == Monthly {
+		return p.start(t).AddDate(0, 1, 0)
+	}
+
+	return p.start(t).AddDate(0, 0, 1)
+}
+
+// Budget tracks compute units spent in the current period. It is used
+// to not go over limits of the plan billed by the rpc provider.
+type Budget struct {
+	period Period
+	limit  uint64
+	// degradeAt is the fraction of the limit after which
+	// budget is considered as degraded.
+	degradeAt float64
+
+	used        uint64
+	periodStart time.Time
+
+	mu sync.Mutex
+}
+
+// NewBudget creates a new instance of Budget. Limit 0 means that
+// budget is unlimited.
+func NewBudget(period Period, limit uint64, degradeAt float64) *Budget {
+	return &Budget{
+		period:    period,
+		limit:     limit,
+		degradeAt: degradeAt,
+	}
+}
+
+// Spend adds given compute units to the budget used in the period of now.
+func (b *Budget) Spend(now time.Time, units uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover(now)
+
+	b.used += units
+}
+
+// Used returns compute units used in the period of now.
+func (b *Budget) Used(now time.Time) uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover(now)
+
+	return b.used
+}
+
+// Refund gives back units reserved in the period of now by the request
+// that was not done. Units reserved in the previous period are gone.
+func (b *Budget) Refund(now time.Time, units uint64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover(now)
+
+	b.used -= min(units, b.used)
+}
+
+// Delay reserves given units for the request and returns for how long it
+// should be held so the rest of the budget lasts till the end of the period.
+// It returns zero as long as budget is not degraded, otherwise requests are
+// spread evenly over what is left from the period. If there is not enough
+// units left, nothing is reserved (reserved is false) and request has to
+// wait for the next period and ask again. Check and reservation are done
+// under the same lock, so concurrent requests don't go over the limit.
+func (b *Budget) Delay(now time.Time, units uint64) (delay time.Duration, degraded, reserved bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.rollover(now)
+
+	if b.limit == 0 {
+		b.used += units
+
+		return 0, false, true
+	}
+
+	left := b.period.end(now).Sub(now)
+	degraded = float64(b.used) >= float64(b.limit)*b.degradeAt
+
+	if b.used+units > b.limit {
+		return left, degraded, false
+	}
+
+	requestsLeft := (b.limit - b.used) / max(units, 1)
+	b.used += units
+
+	if !degraded || units == 0 {
+		return 0, degraded, true
+	}
+
+	return left / time.Duration(requestsLeft+1), true, true
+}
+
+func (b *Budget) rollover(now time.Time) {
+	start := b.period.start(now)
+	if start.Equal(b.periodStart) {
+		return
+	}
+
+	b.periodStart = start
+	b.used = 0
+}
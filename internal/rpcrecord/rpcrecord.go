@@ -0,0 +1,268 @@
+// Package rpcrecord records the JSON-RPC traffic of the parser to the fixture
+// file and replays it, so the responses of the real nodes, with all their
+// quirks, can be used in the tests without the network.
+//
+// Fixture is the JSON Lines file of the exchanges. Only the bodies are
+// recorded, the endpoint and the headers can contain the secrets.
+package rpcrecord
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// ErrNotRecorded is returned by the Replayer when there is no recorded
+// exchange of the request.
+var ErrNotRecorded = errors.New("request was not recorded")
+
+// Exchange is the recorded request and response, one line of the fixture.
+type Exchange struct {
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params,omitempty"`
+	// Status is the http status code of the response.
+	Status int `json:"status"`
+	// Body is the response body as it was received.
+	Body string `json:"body"`
+}
+
+// Recorder is the http.RoundTripper which writes JSON-RPC exchanges to the
+// fixture. Other requests (i.e. webhooks) are passed through and not recorded.
+type Recorder struct {
+	next http.RoundTripper
+
+	mu   sync.Mutex
+	file *os.File
+}
+
+var _ http.RoundTripper = (*Recorder)(nil)
+var _ io.Closer = (*Recorder)(nil)
+
+// NewRecorder appends the exchanges of the requests sent through the next
+// transport (nil means http.DefaultTransport) to the fixture file.
+func NewRecorder(path string, next http.RoundTripper) (*Recorder, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open fixture: %w", err)
+	}
+
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	return &Recorder{next: next, file: file}, nil
+}
+
+// RoundTrip sends the request and records the exchange, response body is
+// read to record it and replaced by the copy.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	method, params, _, err := readRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.next.RoundTrip(req)
+	if err != nil || method == "" {
+		return res, err
+	}
+
+	body, err := io.ReadAll(res.Body)
+	_ = res.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("read response: %w", err)
+	}
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := r.write(Exchange{Method: method, Params: params, Status: res.StatusCode, Body: string(body)}); err != nil {
+		_ = res.Body.Close()
+
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// write appends the exchange to the fixture.
+func (r *Recorder) write(exchange Exchange) error {
+	line, err := json.Marshal(exchange)
+	if err != nil {
+		return fmt.Errorf("json marshal exchange: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, err := r.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("write fixture: %w", err)
+	}
+
+	return nil
+}
+
+// Close closes the fixture file.
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// Replayer is the http.RoundTripper which serves the recorded responses. The
+// requests are matched by the method and the params, the responses of the same
+// request are served in the recorded order and the last one is repeated, so
+// the chain stays at the recorded head. Id of the response is the one of the request.
+type Replayer struct {
+	mu        sync.Mutex
+	exchanges map[string][]Exchange
+}
+
+var _ http.RoundTripper = (*Replayer)(nil)
+
+// NewReplayer loads the fixture file.
+func NewReplayer(path string) (*Replayer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open fixture: %w", err)
+	}
+	defer file.Close()
+
+	replayer := &Replayer{exchanges: make(map[string][]Exchange)}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 64<<20)
+
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		var exchange Exchange
+		if err := json.Unmarshal(scanner.Bytes(), &exchange); err != nil {
+			return nil, fmt.Errorf("fixture line %d: %w", line, err)
+		}
+
+		key := exchangeKey(exchange.Method, exchange.Params)
+		replayer.exchanges[key] = append(replayer.exchanges[key], exchange)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read fixture: %w", err)
+	}
+
+	return replayer, nil
+}
+
+// RoundTrip returns the next recorded response of the request.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	method, params, body, err := readRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	key := exchangeKey(method, params)
+
+	r.mu.Lock()
+	exchanges := r.exchanges[key]
+	if len(exchanges) == 0 {
+		r.mu.Unlock()
+
+		return nil, fmt.Errorf("%w: %s %s", ErrNotRecorded, method, params)
+	}
+
+	exchange := exchanges[0]
+	if len(exchanges) > 1 {
+		r.exchanges[key] = exchanges[1:]
+	}
+	r.mu.Unlock()
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", exchange.Status, http.StatusText(exchange.Status)),
+		StatusCode:    exchange.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(withID(exchange.Body, body))),
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// readRequest returns the method and the params of the JSON-RPC request, the
+// body is replaced by the copy. Method is empty if it isn't JSON-RPC request.
+func readRequest(req *http.Request) (string, json.RawMessage, []byte, error) {
+	if req.Body == nil {
+		return "", nil, nil, nil
+	}
+
+	body, err := io.ReadAll(req.Body)
+	_ = req.Body.Close()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("read request: %w", err)
+	}
+
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	var rpcReq struct {
+		JSONRpc string          `json:"jsonrpc"`
+		Method  string          `json:"method"`
+		Params  json.RawMessage `json:"params"`
+	}
+	if err := json.Unmarshal(body, &rpcReq); err != nil || rpcReq.JSONRpc == "" {
+		return "", nil, body, nil
+	}
+
+	return rpcReq.Method, compact(rpcReq.Params), body, nil
+}
+
+// withID replaces id of the recorded response by the one of the request,
+// body which isn't JSON-RPC response is returned as it is.
+func withID(body string, reqBody []byte) []byte {
+	var rpcReq struct {
+		ID json.RawMessage `json:"id"`
+	}
+
+	var res map[string]json.RawMessage
+	if json.Unmarshal(reqBody, &rpcReq) != nil || json.Unmarshal([]byte(body), &res) != nil {
+		return []byte(body)
+	}
+
+	if _, ok := res["id"]; !ok || rpcReq.ID == nil {
+		return []byte(body)
+	}
+
+	res["id"] = rpcReq.ID
+
+	replaced, err := json.Marshal(res)
+	if err != nil {
+		return []byte(body)
+	}
+
+	return replaced
+}
+
+func exchangeKey(method string, params json.RawMessage) string {
+	return method + " " + string(compact(params))
+}
+
+// compact removes the insignificant whitespace, so that the
+// params match regardless of the formatting.
+func compact(params json.RawMessage) json.RawMessage {
+	if len(params) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, params); err != nil {
+		return params
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,133 @@
+package rpcrecord_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+	"tw/internal/ethtest"
+	"tw/internal/rpcrecord"
+)
+
+func TestRecorder_Then_Replayer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fixture.jsonl")
+
+	node := ethtest.NewNode(1)
+	hash := node.Send(ethtest.Transaction{From: "0x1", To: "0x2"})
+	node.Mine(1)
+	node.FailRPC("eth_blockNumber", 1, -32000, "header not found")
+
+	recorder, err := rpcrecord.NewRecorder(path, nil)
+	if err != nil {
+		t.Fatalf("NewRecorder() error = %v", err)
+	}
+
+	recorded := record(t, ethereum.NewEthApiWrapper(node.URL()), &http.Client{Transport: recorder})
+
+	// webhook isn't JSON-RPC request, it's not recorded
+	res, err := (&http.Client{Transport: recorder}).Post(node.URL().String(), "application/json", nil)
+	if err != nil {
+		t.Fatalf("Post() error = %v", err)
+	}
+	_ = res.Body.Close()
+
+	_ = recorder.Close()
+	node.Close()
+
+	replayer, err := rpcrecord.NewReplayer(path)
+	if err != nil {
+		t.Fatalf("NewReplayer() error = %v", err)
+	}
+
+	// endpoint doesn't matter anymore, node is closed
+	replayed := record(t, ethereum.NewEthApiWrapper(node.URL()), &http.Client{Transport: replayer})
+
+	if !reflect.DeepEqual(replayed, recorded) {
+		t.Errorf("replayed = %v, want %v", replayed, recorded)
+	}
+
+	if want := []string{"json rpc error -32000: header not found", "0x1", hash}; !reflect.DeepEqual(recorded, want) {
+		t.Errorf("recorded = %v, want %v", recorded, want)
+	}
+
+	_, err = ethereum.NewEthApiWrapper(node.URL()).GetTransactionsForBlock(&http.Client{Transport: replayer}, "2")
+	if !errors.Is(err, rpcrecord.ErrNotRecorded) {
+		t.Errorf("GetTransactionsForBlock() of not recorded block error = %v, want %v", err, rpcrecord.ErrNotRecorded)
+	}
+}
+
+// record returns the results of the requests, the errors as strings.
+func record(t *testing.T, api *ethereum.EthApiWrapper, client *http.Client) []string {
+	t.Helper()
+
+	var results []string
+	for i := 0; i < 2; i++ {
+		current, err := api.GetCurrentBlock(client)
+		if err != nil {
+			current = err.Error()
+		}
+
+		results = append(results, current)
+	}
+
+	transactions, err := api.GetTransactionsForBlock(client, "1")
+	if err != nil {
+		t.Fatalf("GetTransactionsForBlock() error = %v", err)
+	}
+
+	for _, transaction := range transactions {
+		results = append(results, transaction.Hash)
+	}
+
+	return results
+}
+
+func TestReplayer_Observer(t *testing.T) {
+	replayer, err := rpcrecord.NewReplayer(filepath.Join("testdata", "quirks.jsonl"))
+	if err != nil {
+		t.Fatalf("NewReplayer() error = %v", err)
+	}
+
+	client := &http.Client{Transport: replayer}
+	// nothing is sent to the endpoint
+	endpoint, _ := url.Parse(ethereum.Mainnet.DefaultEndpoint)
+	api := ethereum.NewEthApiWrapper(endpoint)
+
+	if err := ethereum.VerifyChainID(api, client, ethereum.Mainnet); err != nil {
+		t.Fatalf("VerifyChainID() error = %v", err)
+	}
+
+	observer := ethereum.NewJSONRpcBasedObserver(client, clogger.Logger, api, ethereum.Mainnet, ethereum.WithPollInterval(10*time.Millisecond))
+	defer observer.Close()
+
+	transactions, err := observer.ObserveAddress("0xdAC17F958D2ee523a2206206994597C13D831ec7")
+	if err != nil {
+		t.Fatalf("ObserveAddress() error = %v", err)
+	}
+
+	var hashes []string
+	for len(hashes) < 2 {
+		select {
+		case transaction := <-transactions:
+			hashes = append(hashes, transaction.Hash)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("observed = %v, want 2 transactions", hashes)
+		}
+	}
+
+	// legacy transaction without chain id and the one of the network,
+	// block that failed at first is fetched again
+	want := []string{
+		"0x0000000000000000000000000000000000000000000000000000000000000002",
+		"0x0000000000000000000000000000000000000000000000000000000000000003",
+	}
+	if !reflect.DeepEqual(hashes, want) {
+		t.Errorf("observed = %v, want %v", hashes, want)
+	}
+}
@@ -0,0 +1,376 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+const (
+	// MaxFeedAddresses limits addresses of the one feed client.
+	MaxFeedAddresses = 100
+	// feedBufferSize is how many events can wait for the slow client,
+	// client that doesn't keep up is disconnected and has to resume.
+	feedBufferSize = 256
+	// feedPingInterval keeps the idle connections open behind the proxies.
+	feedPingInterval = 30 * time.Second
+)
+
+// ErrSlowClient ends the stream of the client that doesn't keep up, it can resume.
+var ErrSlowClient = errors.New("client is not keeping up")
+
+// FeedEvent is the observed transaction sent by the feed. ID is the position of the
+// transaction in the chain, <block>-<transaction index>, it is used to resume the feed.
+type FeedEvent struct {
+	ID string `json:"id"`
+	ethereum.TransactionObserved
+}
+
+// Position is the place of the transaction in the chain, feed events are sent in its order.
+type Position struct {
+	Block int64
+	Index int64
+}
+
+func (p Position) String() string {
+	return fmt.Sprintf("%d-%d", p.Block, p.Index)
+}
+
+// After checks whether the position is after the other one.
+func (p Position) After(other Position) bool {
+	return p.Block > other.Block || p.Block == other.Block && p.Index > other.Index
+}
+
+// TransactionPosition returns position of the transaction, false if it has no block number.
+func TransactionPosition(transaction ethereum.Transaction) (Position, bool) {
+	block, err := parseHexInt(transaction.BlockNumber)
+	if err != nil {
+		return Position{}, false
+	}
+
+	// index is missing in the responses of some nodes
+	index, err := parseHexInt(transaction.TransactionIndex)
+	if err != nil {
+		index = 0
+	}
+
+	return Position{Block: block, Index: index}, true
+}
+
+// ParsePosition parses the event id.
+func ParsePosition(id string) (Position, error) {
+	block, index, ok := strings.Cut(id, "-")
+	if !ok {
+		return Position{}, fmt.Errorf("%w: invalid event id %q, want <block>-<index>", ErrInvalidFilter, id)
+	}
+
+	var p Position
+	var err1, err2 error
+	p.Block, err1 = strconv.ParseInt(block, 10, 64)
+	p.Index, err2 = strconv.ParseInt(index, 10, 64)
+	if err1 != nil || err2 != nil || p.Block < 0 || p.Index < 0 {
+		return Position{}, fmt.Errorf("%w: invalid event id %q, want <block>-<index>", ErrInvalidFilter, id)
+	}
+
+	return p, nil
+}
+
+// FeedRequest are the addresses of the network the client wants to follow.
+type FeedRequest struct {
+	Network   string
+	Backend   Backend
+	Addresses []string
+	// LastID is the id of the last event client got, nil means no resume.
+	LastID *Position
+}
+
+// NewFeedRequest normalizes and deduplicates the addresses, and parses
+// the last event id, empty one means no resume.
+func NewFeedRequest(network string, backend Backend, addresses []string, lastEventID string) (FeedRequest, error) {
+	req := FeedRequest{Network: network, Backend: backend}
+
+	seen := make(map[string]bool)
+	for _, address := range addresses {
+		normalized, err := ethereum.NormalizeAddress(strings.TrimSpace(address))
+		if err != nil {
+			return req, fmt.Errorf("address %q: %w", address, err)
+		}
+
+		if !seen[normalized] {
+			seen[normalized] = true
+			req.Addresses = append(req.Addresses, normalized)
+		}
+	}
+
+	if len(req.Addresses) == 0 || len(req.Addresses) > MaxFeedAddresses {
+		return req, fmt.Errorf("%w: between 1 and %d addresses are required, got %d", ErrInvalidFilter, MaxFeedAddresses, len(req.Addresses))
+	}
+
+	if lastEventID != "" {
+		p, err := ParsePosition(lastEventID)
+		if err != nil {
+			return req, err
+		}
+
+		req.LastID = &p
+	}
+
+	return req, nil
+}
+
+// feedKey identifies the address of the network.
+type feedKey struct {
+	network string
+	address string
+}
+
+// Feed fans out the observed transactions to the connected clients. Feed doesn't
+// change the subscriptions, clients can follow only the subscribed addresses. Handler
+// is added once for each address and removed when its last client leaves. When the
+// address is unsubscribed the backend removes the handler, the next client adds it
+// again, so any number of feeds can share the backend.
+type Feed struct {
+	// registerMu orders adding and removing of the handlers, it is not taken
+	// by the handlers, so removing can wait for them
+	registerMu sync.Mutex
+	registered map[feedKey]registration
+
+	mu      sync.Mutex
+	clients map[feedKey]map[*FeedClient]struct{}
+}
+
+// FeedClient receives the observed transactions of the addresses it's subscribed to.
+type FeedClient struct {
+	events chan ethereum.TransactionObserved
+	// overflow is closed when the events buffer is full
+	overflow chan struct{}
+	once     sync.Once
+}
+
+// registration is the handler added to the backend.
+type registration struct {
+	remove func()
+	// removed is closed once the handler is removed, by the feed or the backend
+	removed <-chan struct{}
+}
+
+// active checks whether the backend still calls the handler.
+func (r registration) active() bool {
+	select {
+	case <-r.removed:
+		return false
+	default:
+		return true
+	}
+}
+
+// NewFeed creates a new instance of Feed.
+func NewFeed() *Feed {
+	return &Feed{
+		registered: make(map[feedKey]registration),
+		clients:    make(map[feedKey]map[*FeedClient]struct{}),
+	}
+}
+
+// Subscribe adds the client of the request addresses. It returns
+// ethereum.ErrNotSubscribed if any of the addresses is not subscribed
+// in the backend. Client has to be unsubscribed when it's not needed anymore.
+func (f *Feed) Subscribe(req FeedRequest) (*FeedClient, error) {
+	client := &FeedClient{
+		events:   make(chan ethereum.TransactionObserved, feedBufferSize),
+		overflow: make(chan struct{}),
+	}
+
+	f.registerMu.Lock()
+	defer f.registerMu.Unlock()
+
+	var added []feedKey
+	for _, address := range req.Addresses {
+		key := feedKey{network: req.Network, address: address}
+		if r, ok := f.registered[key]; ok && r.active() {
+			continue
+		}
+
+		remove, removed, err := req.Backend.AddHandler(address, f.handler(key))
+		if err != nil {
+			for _, key := range added {
+				f.release(key)
+			}
+
+			return nil, err
+		}
+
+		f.registered[key] = registration{remove: remove, removed: removed}
+		added = append(added, key)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, address := range req.Addresses {
+		key := feedKey{network: req.Network, address: address}
+		if f.clients[key] == nil {
+			f.clients[key] = make(map[*FeedClient]struct{})
+		}
+
+		f.clients[key][client] = struct{}{}
+	}
+
+	return client, nil
+}
+
+// Unsubscribe removes the client, handlers of the addresses
+// without the clients are removed from the backend.
+func (f *Feed) Unsubscribe(req FeedRequest, client *FeedClient) {
+	f.registerMu.Lock()
+	defer f.registerMu.Unlock()
+
+	var unused []feedKey
+
+	f.mu.Lock()
+	for _, address := range req.Addresses {
+		key := feedKey{network: req.Network, address: address}
+
+		delete(f.clients[key], client)
+		if len(f.clients[key]) == 0 {
+			delete(f.clients, key)
+			unused = append(unused, key)
+		}
+	}
+	f.mu.Unlock()
+
+	// handlers are removed without holding f.mu, removing waits for them
+	for _, key := range unused {
+		f.release(key)
+	}
+}
+
+// release removes the handler of the address, f.registerMu has to be locked.
+func (f *Feed) release(key feedKey) {
+	if r, ok := f.registered[key]; ok {
+		delete(f.registered, key)
+		r.remove()
+	}
+}
+
+func (f *Feed) handler(key feedKey) ethereum.EventHandler {
+	return func(ctx context.Context, event ethereum.Event) error {
+		observed, ok := event.(ethereum.TransactionObserved)
+		if !ok {
+			return nil
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		for client := range f.clients[key] {
+			select {
+			case client.events <- observed:
+			default:
+				client.once.Do(func() { close(client.overflow) })
+			}
+		}
+
+		return nil
+	}
+}
+
+// Stream sends the stored transactions after the last id and then the live ones,
+// until the context is done or sending fails. Ping is called when there is nothing
+// to send, it can be nil. Client has to be subscribed before, so nothing is missed
+// during the replay. Live events the client already got are skipped by comparing
+// them to the last replayed position of their address: handlers of the addresses
+// run independently, so live events of the different addresses are not ordered.
+func Stream(ctx context.Context, req FeedRequest, client *FeedClient, send func(FeedEvent) error, ping func() error) error {
+	// sent are the positions up to which events of the address were sent, by
+	// the lowercase address, the ones missing here were sent up to the last id
+	sent := make(map[string]Position)
+
+	if req.LastID != nil {
+		for _, event := range replay(req) {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			p, _ := TransactionPosition(event.Transaction)
+			if err := send(FeedEvent{ID: p.String(), TransactionObserved: event}); err != nil {
+				return err
+			}
+
+			sent[strings.ToLower(event.Address)] = p
+		}
+	}
+
+	ticker := time.NewTicker(feedPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-client.overflow:
+			return ErrSlowClient
+		case <-ticker.C:
+			if ping == nil {
+				continue
+			}
+
+			if err := ping(); err != nil {
+				return err
+			}
+		case event := <-client.events:
+			var id string
+			if p, ok := TransactionPosition(event.Transaction); ok {
+				last, replayed := sent[strings.ToLower(event.Address)]
+				if !replayed && req.LastID != nil {
+					last, replayed = *req.LastID, true
+				}
+
+				if replayed && !p.After(last) {
+					continue
+				}
+
+				id = p.String()
+			}
+
+			if err := send(FeedEvent{ID: id, TransactionObserved: event}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// replay returns stored transactions of the addresses after the last id, in the chain order.
+func replay(req FeedRequest) []ethereum.TransactionObserved {
+	type positioned struct {
+		Position
+		event ethereum.TransactionObserved
+	}
+
+	var events []positioned
+	for _, address := range req.Addresses {
+		for _, transaction := range req.Backend.GetTransactions(address) {
+			if p, ok := TransactionPosition(transaction); ok && p.After(*req.LastID) {
+				events = append(events, positioned{p, ethereum.TransactionObserved{Address: address, Transaction: transaction}})
+			}
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[j].After(events[i].Position)
+	})
+
+	replayed := make([]ethereum.TransactionObserved, len(events))
+	for i, event := range events {
+		replayed[i] = event.event
+	}
+
+	return replayed
+}
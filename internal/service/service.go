@@ -0,0 +1,139 @@
+// Package service is the part of the http, json rpc and grpc apis
+// that doesn't depend on the transport: querying of the stored
+// transactions and the live feed of the observed ones.
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"tw/internal/ethereum"
+	"tw/internal/hexutil"
+)
+
+const (
+	DefaultPageLimit = 50
+	MaxPageLimit     = 1000
+)
+
+// ErrInvalidFilter is returned when the filter or the feed request is invalid.
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// Backend is the parser exposed by the apis.
+type Backend interface {
+	GetCurrentBlock() int
+	SubscribeAddress(address string) error
+	AddHandler(address string, handler ethereum.EventHandler, opts ...ethereum.HandlerOption) (func(), <-chan struct{}, error)
+	Unsubscribe(address string) error
+	Subscriptions() []string
+	GetTransactions(address string) []ethereum.Transaction
+}
+
+var _ Backend = (*ethereum.JSONRPCParser)(nil)
+
+// Filter selects the stored transactions of the address. Negative
+// blocks mean no limit, use NewFilter to get the defaults.
+type Filter struct {
+	// From is the sender, empty means any.
+	From      string
+	FromBlock int64
+	ToBlock   int64
+	Offset    int
+	// Limit is the page size, at most MaxPageLimit.
+	Limit int
+}
+
+// NewFilter returns filter of the first page of all the transactions.
+func NewFilter() Filter {
+	return Filter{FromBlock: -1, ToBlock: -1, Limit: DefaultPageLimit}
+}
+
+// Validate checks the filter and normalizes the sender address.
+func (f *Filter) Validate() error {
+	if f.From != "" {
+		address, err := ethereum.NormalizeAddress(f.From)
+		if err != nil {
+			return fmt.Errorf("%w: from: %s", ErrInvalidFilter, err.Error())
+		}
+
+		f.From = address
+	}
+
+	if f.Offset < 0 {
+		return fmt.Errorf("%w: offset can't be negative, got %d", ErrInvalidFilter, f.Offset)
+	}
+
+	if f.Limit < 1 || f.Limit > MaxPageLimit {
+		return fmt.Errorf("%w: limit must be between 1 and %d, got %d", ErrInvalidFilter, MaxPageLimit, f.Limit)
+	}
+
+	return nil
+}
+
+// Matches checks whether the transaction passes the filter, offset and limit are not checked.
+func (f Filter) Matches(transaction ethereum.Transaction) bool {
+	if f.From != "" {
+		from, err := ethereum.NormalizeAddress(transaction.From)
+		if err != nil || from != f.From {
+			return false
+		}
+	}
+
+	if f.FromBlock < 0 && f.ToBlock < 0 {
+		return true
+	}
+
+	block, err := parseHexInt(transaction.BlockNumber)
+	if err != nil {
+		return false
+	}
+
+	return (f.FromBlock < 0 || block >= f.FromBlock) && (f.ToBlock < 0 || block <= f.ToBlock)
+}
+
+// Page is the page of the stored transactions.
+type Page struct {
+	Transactions []ethereum.Transaction
+	// Total is the number of transactions matching the filter.
+	Total int
+	// NextOffset is the offset of the next page, nil on the last page.
+	NextOffset *int
+}
+
+// GetPage returns the page of the address transactions matching the filter, oldest first.
+func GetPage(backend Backend, address string, filter Filter) Page {
+	var matching []ethereum.Transaction
+	for _, transaction := range backend.GetTransactions(address) {
+		if filter.Matches(transaction) {
+			matching = append(matching, transaction)
+		}
+	}
+
+	page := Page{
+		Transactions: []ethereum.Transaction{},
+		Total:        len(matching),
+	}
+
+	if filter.Offset < len(matching) {
+		end := min(filter.Offset+filter.Limit, len(matching))
+		page.Transactions = matching[filter.Offset:end]
+
+		if end < len(matching) {
+			page.NextOffset = &end
+		}
+	}
+
+	return page
+}
+
+func parseHexInt(s string) (int64, error) {
+	return hexutil.DecodeInt64(s)
+}
+
+// HealthChecker is implemented by the backends which know if they are
+// stuck or behind the head, see ethereum.JSONRPCParser.Health.
+type HealthChecker interface {
+	Health(config ethereum.HealthConfig) ethereum.Health
+}
+
+var _ HealthChecker = (*ethereum.JSONRPCParser)(nil)
@@ -0,0 +1,60 @@
+// Package tracing sets up OpenTelemetry tracing of the block processing. Spans
+// are created with Tracer, they are no-op until Setup sets the exporter.
+package tracing
+
+import (
+	"context"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// TracerName is the instrumentation name of the spans.
+	TracerName = "tw"
+	// ServiceName is the service.name of the exported spans.
+	ServiceName = "tw"
+)
+
+// Exporter receives the finished spans, any OpenTelemetry span
+// exporter (i.e. OTLP) can be used.
+type Exporter = sdktrace.SpanExporter
+
+// Tracer returns the tracer of the global provider, so spans go
+// to the exporter set by Setup, also when it is called later.
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// NewStdoutExporter writes every span as json to w.
+func NewStdoutExporter(w io.Writer) (Exporter, error) {
+	return stdouttrace.New(stdouttrace.WithWriter(w))
+}
+
+// Setup sets the global provider exporting spans to the exporter in batches.
+// Returned shutdown exports what is left and stops the exporter.
+func Setup(exporter Exporter) (shutdown func(ctx context.Context) error) {
+	return setup(sdktrace.WithBatcher(exporter))
+}
+
+// SetupSync is Setup which exports every span as soon as it ends,
+// so the spans are there right after the traced call returns.
+func SetupSync(exporter Exporter) (shutdown func(ctx context.Context) error) {
+	return setup(sdktrace.WithSyncer(exporter))
+}
+
+func setup(opt sdktrace.TracerProviderOption) func(ctx context.Context) error {
+	provider := sdktrace.NewTracerProvider(
+		opt,
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", ServiceName))),
+	)
+
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown
+}
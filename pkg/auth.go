@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"time"
+
+	"tw/internal/auth"
+	"tw/internal/ethereum"
+)
+
+// Authenticator authenticates every request done to the api endpoint.
+// All the authenticators below are redacting secrets when printed.
+type Authenticator = ethereum.RequestAuthenticator
+
+// StaticHeadersAuth sets given headers (i.e. api key) on every request.
+func StaticHeadersAuth(headers map[string]string) Authenticator {
+	return auth.NewStaticHeaders(headers)
+}
+
+// BasicAuth authenticates requests with http basic authentication.
+func BasicAuth(username, password string) Authenticator {
+	return auth.NewBasicAuth(username, password)
+}
+
+// JWTAuth authenticates requests with HS256 token signed with the secret,
+// new token is signed every rotation period.
+func JWTAuth(secret []byte, rotation time.Duration) (Authenticator, error) {
+	return auth.NewJWTAuth(secret, rotation)
+}
+
+// JWTAuthFromFile works like JWTAuth, but reads hex encoded secret from the file.
+func JWTAuthFromFile(path string, rotation time.Duration) (Authenticator, error) {
+	return auth.NewJWTAuthFromFile(path, rotation)
+}
+
+// BearerTokenFileAuth authenticates requests with bearer token read from the file,
+// token is read again when the file changes.
+func BearerTokenFileAuth(path string) Authenticator {
+	return auth.NewBearerTokenFile(path)
+}
@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+
+	"tw/internal/ethereum"
+)
+
+// Backfill looks for the transactions to the address in the blocks from..to (both
+// inclusive) and stores the ones that are not stored yet, so the history from before
+// the subscription can be filled. Options are the same as for NewParser, storage
+// should be set, otherwise transactions end up in the memory. Progress can be nil.
+// It returns the number of stored transactions. File storage is locked like by
+// Import, it returns ErrStorageLocked when the daemon has it open.
+func Backfill(ctx context.Context, address string, from, to int64, progress func(BackfillProgress), opts ...Option) (int, error) {
+	o, apiWrappers, err := newOptions(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	if o.storage != nil {
+		if err := lockStorage(o.storage); err != nil {
+			return 0, err
+		}
+	}
+
+	apiWrapper := o.apiWrapper(apiWrappers)
+	defer apiWrapper.Close()
+
+	storage := o.scopedStorage()
+
+	count, err := ethereum.Backfill(ctx, apiWrapper, o.httpClient, o.network, storage, address, from, to, progress)
+
+	if flusher, ok := storage.(ethereum.Flusher); ok {
+		err = errors.Join(err, flusher.Flush())
+	}
+
+	return count, err
+}
@@ -0,0 +1,403 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"tw/internal/auth"
+	"tw/internal/clogger"
+	"tw/internal/config"
+	"tw/internal/ethereum"
+	"tw/internal/file"
+	"tw/internal/memory"
+	"tw/internal/notify"
+	"tw/internal/tracing"
+)
+
+// Config describes networks, endpoints, watched addresses, storage
+// backend and notification sinks, see LoadConfig.
+type Config = config.Config
+
+// LoadConfig reads the TOML config file (empty path means defaults only),
+// applies TW_* environment overrides and validates the result. The returned
+// error lists all the problems at once.
+func LoadConfig(path string) (Config, error) {
+	cfg, err := config.Load(path, os.Environ())
+	if err != nil {
+		return cfg, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Deployment is the set of parsers described by the config, one
+// for each network, sharing the storage.
+type Deployment struct {
+	// Parsers by the network name.
+	Parsers map[string]*JSONRPCParser
+
+	// Notifiers are the webhook notifications, in the config order.
+	Notifiers []*notify.Notifier
+
+	// networks are the names in the config order
+	networks        []string
+	health          config.Health
+	allowedOrigins  []string
+	shutdownTracing func(ctx context.Context) error
+	storage         TransactionsStorage
+	logger          *slog.Logger
+}
+
+// NewDeployment creates parser for every network of the config, subscribes the
+// addresses and the notification sinks. Options are applied after the ones from
+// the config, i.e. to set the logger or http client.
+func NewDeployment(cfg Config, opts ...Option) (*Deployment, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	logger, err := newConfigLogger(cfg.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	// storage is opened last, the errors after it have to close it
+	storage, err := OpenConfigStorage(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// the daemon is the only writer of the file, import and backfill refuse to run beside it
+	if err := lockStorage(storage); err != nil {
+		if closer, ok := storage.(io.Closer); ok {
+			_ = closer.Close()
+		}
+
+		return nil, err
+	}
+
+	// logger of the config goes first, so that the options can replace it
+	opts = append([]Option{WithSlogLogger(logger)}, opts...)
+
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	d := &Deployment{
+		Parsers: make(map[string]*JSONRPCParser),
+		storage: storage,
+		logger:  o.logger,
+		health:  cfg.Health,
+
+		allowedOrigins: cfg.HTTP.AllowedOrigins,
+	}
+
+	if cfg.Tracing.Exporter == config.TracingStdout {
+		exporter, err := tracing.NewStdoutExporter(os.Stdout)
+		if err != nil {
+			_ = d.Shutdown(context.Background())
+
+			return nil, fmt.Errorf("tracing: %w", err)
+		}
+
+		d.shutdownTracing = tracing.Setup(exporter)
+	}
+
+	// handlers of the networks share the notifier, so that
+	// there is one outbox for each webhook
+	handlers := make([]func(network string) EventHandler, len(cfg.Notifications))
+	for i, notification := range cfg.Notifications {
+		handler, err := d.newConfigHandler(notification, o)
+		if err != nil {
+			_ = d.Shutdown(context.Background())
+
+			return nil, fmt.Errorf("notifications[%d]: %w", i, err)
+		}
+
+		handlers[i] = handler
+	}
+
+	for _, network := range cfg.Networks {
+		parser, err := newConfigParser(cfg, network, storage, handlers, opts)
+		if err != nil {
+			// parsers created so far don't have anything to finish yet
+			_ = d.Shutdown(context.Background())
+
+			return nil, fmt.Errorf("network %s: %w", network.Name, err)
+		}
+
+		d.Parsers[network.Name] = parser
+		d.networks = append(d.networks, network.Name)
+	}
+
+	return d, nil
+}
+
+// Start starts all the parsers and the notifiers.
+func (d *Deployment) Start() error {
+	var errs []error
+	for _, notifier := range d.Notifiers {
+		_ = notifier.Start()
+	}
+
+	for name, parser := range d.Parsers {
+		if err := parser.Start(); err != nil {
+			errs = append(errs, fmt.Errorf("start %s parser: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Shutdown shuts down all the parsers at once, then the notifiers, so
+// that the events handed over by the parsers are in the outboxes, closes
+// the storage and exports the rest of the spans.
+func (d *Deployment) Shutdown(ctx context.Context) error {
+	var (
+		errs []error
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+	)
+
+	for name, parser := range d.Parsers {
+		wg.Add(1)
+
+		go func(name string, parser *JSONRPCParser) {
+			defer wg.Done()
+
+			if err := parser.Shutdown(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("shutdown %s parser: %w", name, err))
+				mu.Unlock()
+			}
+		}(name, parser)
+	}
+
+	wg.Wait()
+
+	for _, notifier := range d.Notifiers {
+		if err := notifier.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown notifier: %w", err))
+		}
+	}
+
+	if closer, ok := d.storage.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("close storage: %w", err))
+		}
+	}
+
+	if d.shutdownTracing != nil {
+		if err := d.shutdownTracing(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("shutdown tracing: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ConfigOptions returns options of the network from the config, network doesn't
+// have to be in the config if it is one of the known ones. Storage option is not
+// included, storage described by the config is opened by NewDeployment.
+func ConfigOptions(cfg Config, network string) ([]Option, error) {
+	configNetwork := config.Network{Name: network}
+	for _, n := range cfg.Networks {
+		if strings.EqualFold(n.Name, network) {
+			configNetwork = n
+		}
+	}
+
+	resolved, err := configNetwork.Resolve()
+	if err != nil {
+		return nil, err
+	}
+
+	ethNetwork := Network{Name: resolved.Name, ChainID: resolved.ChainID}
+	if known, ok := ethereum.NetworkByName(resolved.Name); ok {
+		ethNetwork = known
+	}
+
+	authenticator, err := newConfigAuthenticator(resolved.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := ethereum.ParseOverflowPolicy(cfg.Queue.Policy)
+	if err != nil {
+		return nil, err
+	}
+
+	logger, err := newConfigLogger(cfg.Log)
+	if err != nil {
+		return nil, err
+	}
+
+	return []Option{
+		WithSlogLogger(logger),
+		WithNetwork(ethNetwork),
+		WithEndpoints(resolved.Endpoints...),
+		WithAuthenticator(authenticator),
+		WithPollInterval(time.Duration(cfg.PollInterval)),
+		WithConfirmations(cfg.Confirmations),
+		WithStartBlock(cfg.StartBlock),
+		WithRateLimit(RateLimitConfig{
+			RequestsPerSecond: cfg.RateLimit.RequestsPerSecond,
+			Burst:             cfg.RateLimit.Burst,
+			DailyBudget:       cfg.RateLimit.DailyBudget,
+			MonthlyBudget:     cfg.RateLimit.MonthlyBudget,
+		}),
+		WithSubscriberQueue(QueueConfig{
+			Size:     cfg.Queue.Size,
+			Policy:   policy,
+			SpillDir: cfg.Queue.SpillDir,
+		}),
+	}, nil
+}
+
+// OpenConfigStorage opens the storage described by the config. File
+// storage has to be closed, when it is not needed anymore.
+func OpenConfigStorage(cfg Config) (TransactionsStorage, error) {
+	if cfg.Storage.Backend == config.StorageFile {
+		fs, err := file.NewFileTransactionStorage(cfg.Storage.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		return fs, nil
+	}
+
+	return memory.NewMemoryTransactionStorage(), nil
+}
+
+// lockStorage locks the storage if it is shared by the processes.
+func lockStorage(storage TransactionsStorage) error {
+	if locker, ok := storage.(ethereum.Locker); ok {
+		return locker.Lock()
+	}
+
+	return nil
+}
+
+// newConfigParser creates parser for the network, config has to be valid.
+func newConfigParser(cfg Config, network config.Network, storage TransactionsStorage, handlers []func(network string) EventHandler, extra []Option) (*JSONRPCParser, error) {
+	opts, err := ConfigOptions(cfg, network.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	opts = append(append(opts, WithStorage(storage)), extra...)
+
+	parser, err := NewParser(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, address := range network.Addresses {
+		if err := parser.SubscribeAddress(address); err != nil {
+			_ = parser.Close()
+
+			return nil, fmt.Errorf("subscribe %s: %w", address, err)
+		}
+	}
+
+	for i, notification := range cfg.Notifications {
+		handler := handlers[i](network.Name)
+
+		for _, address := range notificationAddresses(notification, network) {
+			if err := parser.SubscribeFunc(address, handler); err != nil {
+				_ = parser.Close()
+
+				return nil, fmt.Errorf("notifications[%d]: subscribe %s: %w", i, address, err)
+			}
+		}
+	}
+
+	return parser, nil
+}
+
+// newConfigLogger creates the logger writing to stderr.
+func newConfigLogger(l config.Log) (*slog.Logger, error) {
+	level, err := clogger.ParseLevel(l.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	return clogger.New(os.Stderr, l.Format, level)
+}
+
+func newConfigAuthenticator(a config.Auth) (Authenticator, error) {
+	switch a.Type {
+	case config.AuthBasic:
+		return auth.NewBasicAuth(a.Username, a.Password), nil
+	case config.AuthBearerFile:
+		return auth.NewBearerTokenFile(a.Path), nil
+	case config.AuthJWTFile:
+		return auth.NewJWTAuthFromFile(a.Path, auth.DefaultJWTRotation)
+	default:
+		return nil, nil
+	}
+}
+
+// newConfigHandler creates the handlers of the notification for the networks,
+// webhook notifiers are added to the deployment, so they are started with it.
+func (d *Deployment) newConfigHandler(notification config.Notification, o options) (func(network string) EventHandler, error) {
+	if notification.Type == config.NotificationLog {
+		return func(network string) EventHandler { return notify.LogHandler(o.logger, network) }, nil
+	}
+
+	endpoint, err := ethereum.ParseHTTPURL(notification.URL)
+	if err != nil {
+		return nil, fmt.Errorf("webhook url: %w", err)
+	}
+
+	outbox := notify.NewMemoryOutbox()
+	if notification.OutboxDir != "" {
+		outbox, err = notify.OpenOutbox(notification.OutboxDir)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var notifierOpts []notify.NotifierOption
+	if notification.MaxAttempts > 0 {
+		notifierOpts = append(notifierOpts, notify.WithMaxAttempts(notification.MaxAttempts))
+	}
+
+	notifier := notify.NewNotifier(notify.NewWebhook(endpoint, notification.Secret, o.httpClient), outbox, o.logger, notifierOpts...)
+	d.Notifiers = append(d.Notifiers, notifier)
+
+	return notifier.Handler, nil
+}
+
+// notificationAddresses returns the network addresses the notification is interested in.
+func notificationAddresses(notification config.Notification, network config.Network) []string {
+	if len(notification.Addresses) == 0 {
+		return network.Addresses
+	}
+
+	var addresses []string
+	for _, address := range notification.Addresses {
+		normalized, _ := ethereum.NormalizeAddress(address)
+
+		for _, watched := range network.Addresses {
+			if watchedNormalized, _ := ethereum.NormalizeAddress(watched); watchedNormalized == normalized {
+				addresses = append(addresses, address)
+				break
+			}
+		}
+	}
+
+	return addresses
+}
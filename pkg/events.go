@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"time"
+
+	"tw/internal/ethereum"
+)
+
+// Event is emitted by the parser, use type switch to find out which one it is.
+// Events are available on the parser Events channel and are passed to the handlers.
+type Event = ethereum.Event
+type TransactionObserved = ethereum.TransactionObserved
+type TransactionConfirmed = ethereum.TransactionConfirmed
+type Reorg = ethereum.Reorg
+type BackfillProgress = ethereum.BackfillProgress
+type SourceError = ethereum.SourceError
+type Lagging = ethereum.Lagging
+
+type EventHandler = ethereum.EventHandler
+type HandlerOption = ethereum.HandlerOption
+type DeadLetter = ethereum.DeadLetter
+
+// EventsBufferSize is the size of the parser Events channel buffer,
+// events are dropped when the reader doesn't keep up.
+const EventsBufferSize = ethereum.EventsBufferSize
+
+// WithConcurrency sets how many events handler passed to SubscribeFunc can handle at
+// the same time. Events are handled in order only if concurrency is 1 (default).
+func WithConcurrency(concurrency int) HandlerOption {
+	return ethereum.WithConcurrency(concurrency)
+}
+
+// WithMaxAttempts sets how many times handler is called for the same event
+// before the event is moved to the dead letters. Default is 5.
+func WithMaxAttempts(maxAttempts int) HandlerOption {
+	return ethereum.WithMaxAttempts(maxAttempts)
+}
+
+// WithRetryBackoff sets the time to wait before the first retry, it is doubled
+// with each attempt up to the max backoff. Default is 1s and 1m.
+func WithRetryBackoff(backoff, maxBackoff time.Duration) HandlerOption {
+	return ethereum.WithRetryBackoff(backoff, maxBackoff)
+}
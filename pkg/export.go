@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"io"
+
+	"tw/internal/ethereum"
+	"tw/internal/export"
+)
+
+// ExportFormat is the format of the exported transactions.
+type ExportFormat = export.Format
+
+// ExportFilter selects the exported transactions by the blocks and their time.
+type ExportFilter = export.Filter
+
+// ExportStats are the numbers of the exported and the skipped transactions.
+type ExportStats = export.Stats
+
+// AddressLister is implemented by the storage which can list its addresses,
+// Export of all the addresses needs it.
+type AddressLister = ethereum.AddressLister
+
+const (
+	ExportCSV     = export.CSV
+	ExportJSONL   = export.JSONL
+	ExportParquet = export.Parquet
+)
+
+var (
+	// ErrUnknownColumn is returned by Export for the column which doesn't exist.
+	ErrUnknownColumn = export.ErrUnknownColumn
+	// ErrUnknownFormat is returned by Export for the format which isn't supported.
+	ErrUnknownFormat = export.ErrUnknownFormat
+	// ErrAddressesNotListed is returned by Export without the addresses, when
+	// the storage isn't AddressLister.
+	ErrAddressesNotListed = export.ErrAddressesNotListed
+)
+
+// ExportColumns returns the names of all the columns, in the default order.
+func ExportColumns() []string {
+	names := make([]string, 0, len(export.Columns))
+	for _, column := range export.Columns {
+		names = append(names, column.Name)
+	}
+
+	return names
+}
+
+// Export writes the stored transactions of the addresses (all of them, if there
+// are none) which match the filter to w. Columns are selected by the names, all
+// of them if there are none. Options are the same as for NewParser, only the
+// network and the storage are used. Transactions without the block timestamp
+// are skipped when the filter selects the time, the stats count them.
+func Export(w io.Writer, format ExportFormat, columns []string, addresses []string, filter ExportFilter, opts ...Option) (ExportStats, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	selected, err := export.ParseColumns(columns)
+	if err != nil {
+		return ExportStats{}, err
+	}
+
+	writer, err := export.NewWriter(format, w, selected)
+	if err != nil {
+		return ExportStats{}, err
+	}
+
+	if _, ok := o.storage.(AddressLister); len(addresses) == 0 && !ok && o.storage != nil {
+		return ExportStats{}, ErrAddressesNotListed
+	}
+
+	stats, err := export.Export(o.scopedStorage(), addresses, filter, writer)
+	if err != nil {
+		return stats, err
+	}
+
+	return stats, writer.Close()
+}
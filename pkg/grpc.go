@@ -0,0 +1,35 @@
+package pkg
+
+import (
+	"log/slog"
+
+	"google.golang.org/grpc"
+
+	"tw/internal/grpcapi"
+	"tw/internal/service"
+)
+
+// NewGRPCServer exposes the parsers over the gRPC Parser service, one for each
+// network. Requests with empty network go to the default network.
+func NewGRPCServer(parsers map[string]*JSONRPCParser, defaultNetwork string, logger *slog.Logger, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	backends := make(map[string]service.Backend, len(parsers))
+	for name, parser := range parsers {
+		backends[name] = parser
+	}
+
+	server, err := grpcapi.NewServer(backends, defaultNetwork, nil, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	grpcServer := grpc.NewServer(opts...)
+	server.Register(grpcServer)
+
+	return grpcServer, nil
+}
+
+// GRPCServer exposes the deployment parsers over gRPC, the first
+// network of the config is the default one, see NewGRPCServer.
+func (d *Deployment) GRPCServer(opts ...grpc.ServerOption) (*grpc.Server, error) {
+	return NewGRPCServer(d.Parsers, d.networks[0], d.logger, opts...)
+}
@@ -0,0 +1,53 @@
+package pkg
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"tw/internal/ethereum"
+	"tw/internal/httpapi"
+	"tw/internal/service"
+)
+
+// HealthConfig are the thresholds of the /healthz and /readyz endpoints.
+type HealthConfig = ethereum.HealthConfig
+
+// DefaultHealthConfig are the thresholds used by NewHTTPHandler.
+var DefaultHealthConfig = ethereum.DefaultHealthConfig
+
+// NewHTTPHandler exposes the parsers over the REST api, one for each network.
+// Requests without ?network= query parameter go to the default network.
+// OpenAPI document of the api is served at /openapi.json. Websockets can be
+// opened only by the browser pages of the api host.
+func NewHTTPHandler(parsers map[string]*JSONRPCParser, defaultNetwork string, logger *slog.Logger) (http.Handler, error) {
+	return newHTTPServer(parsers, defaultNetwork, logger, DefaultHealthConfig, nil)
+}
+
+// HTTPHandler exposes the deployment parsers over the REST api, the
+// first network of the config is the default one, see NewHTTPHandler.
+// Health thresholds and the websocket origins are the ones of the config.
+func (d *Deployment) HTTPHandler() (http.Handler, error) {
+	return newHTTPServer(d.Parsers, d.networks[0], d.logger, HealthConfig{
+		MaxLagBlocks: d.health.MaxLagBlocks,
+		MaxLagTime:   time.Duration(d.health.MaxLag),
+		MaxFailures:  d.health.MaxFailures,
+	}, d.allowedOrigins)
+}
+
+func newHTTPServer(parsers map[string]*JSONRPCParser, defaultNetwork string, logger *slog.Logger, health HealthConfig, allowedOrigins []string) (*httpapi.Server, error) {
+	backends := make(map[string]service.Backend, len(parsers))
+	for name, parser := range parsers {
+		backends[name] = parser
+	}
+
+	server, err := httpapi.NewServer(backends, defaultNetwork, logger)
+	if err != nil {
+		return nil, err
+	}
+
+	server.SetHealthConfig(health)
+	server.SetAllowedOrigins(allowedOrigins)
+
+	return server, nil
+}
@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"tw/internal/archive"
+	"tw/internal/ethereum"
+)
+
+// ImportProgress is reported by Import after every block.
+type ImportProgress = ethereum.ImportProgress
+
+// Import stores the transactions to the addresses from the archive files, in the
+// given order, like Backfill does it from the api. Files ending with .era1 are
+// era1 archives, the other ones are JSON Lines of eth_getBlockByNumber responses
+// with full transactions (gzipped if they end with .gz). Options are the same as
+// for NewParser, only the network and the storage are used, api isn't called.
+// Progress can be nil. It returns the number of stored transactions.
+//
+// Import is offline: transactions are written to the storage directly, event
+// handlers and notifications aren't called for them. File storage is locked
+// until it is closed, it returns ErrStorageLocked when the daemon has it open.
+func Import(ctx context.Context, paths []string, addresses []string, progress func(ImportProgress), opts ...Option) (int, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if o.storage != nil {
+		if err := lockStorage(o.storage); err != nil {
+			return 0, err
+		}
+	}
+
+	storage := o.scopedStorage()
+
+	count := 0
+
+	var err error
+	for _, path := range paths {
+		var stored int
+		stored, err = importFile(ctx, path, o.network, storage, addresses, progress)
+		count += stored
+
+		if err != nil {
+			err = fmt.Errorf("import %s: %w", path, err)
+			break
+		}
+	}
+
+	if flusher, ok := storage.(ethereum.Flusher); ok {
+		err = errors.Join(err, flusher.Flush())
+	}
+
+	return count, err
+}
+
+func importFile(ctx context.Context, path string, network Network, storage TransactionsStorage, addresses []string, progress func(ImportProgress)) (int, error) {
+	source, err := archive.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer source.Close()
+
+	return ethereum.Import(ctx, source, network, storage, addresses, progress)
+}
@@ -0,0 +1,305 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"tw/internal/clogger"
+	"tw/internal/ethereum"
+	"tw/internal/memory"
+	"tw/internal/ratelimit"
+)
+
+// ErrInvalidOption is returned by NewParser when option has invalid value.
+var ErrInvalidOption = errors.New("invalid option")
+
+// RateLimitConfig describes how the api requests are limited.
+type RateLimitConfig = ratelimit.Config
+
+// DefaultRateLimit is safe to use with the public endpoints.
+var DefaultRateLimit = ratelimit.DefaultConfig
+
+// Option configures the parser created by NewParser.
+type Option func(o *options)
+
+type options struct {
+	network       Network
+	endpoints     []string
+	authenticator Authenticator
+	httpClient    *http.Client
+	logger        *slog.Logger
+	storage       TransactionsStorage
+	rateLimit     RateLimitConfig
+	queue         QueueConfig
+	pollInterval  time.Duration
+	confirmations int64
+	startBlock    int64
+}
+
+func defaultOptions() options {
+	return options{
+		network:       Mainnet,
+		httpClient:    http.DefaultClient,
+		logger:        clogger.Logger,
+		rateLimit:     DefaultRateLimit,
+		queue:         QueueConfig{Policy: Block},
+		pollInterval:  5 * time.Second,
+		confirmations: 12,
+	}
+}
+
+// WithNetwork sets the network, default is Mainnet.
+func WithNetwork(network Network) Option {
+	return func(o *options) {
+		o.network = network
+	}
+}
+
+// WithEndpoints sets api endpoints, default is the public endpoint of the network.
+// The first one is used until it fails, then the next ones are tried in order.
+func WithEndpoints(endpoints ...string) Option {
+	return func(o *options) {
+		o.endpoints = endpoints
+	}
+}
+
+// WithAuthenticator authenticates requests to all the endpoints.
+func WithAuthenticator(authenticator Authenticator) Option {
+	return func(o *options) {
+		o.authenticator = authenticator
+	}
+}
+
+// WithHTTPClient sets http client used for the api requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(o *options) {
+		o.httpClient = httpClient
+	}
+}
+
+// WithLogger sets the logger, records of info and higher levels are written
+// through it as key=value text. Default is the console logger, see WithSlogLogger.
+func WithLogger(logger *log.Logger) Option {
+	return func(o *options) {
+		o.logger = clogger.FromLog(logger)
+	}
+}
+
+// WithSlogLogger sets the structured logger, default logs info and
+// higher levels as text to stderr.
+func WithSlogLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithStorage sets storage of the transactions, default is in memory storage.
+// Keys are scoped to the network, so storage can be shared by the parsers.
+func WithStorage(storage TransactionsStorage) Option {
+	return func(o *options) {
+		o.storage = storage
+	}
+}
+
+// WithRateLimit sets the limits of the api requests, default is DefaultRateLimit.
+func WithRateLimit(config RateLimitConfig) Option {
+	return func(o *options) {
+		o.rateLimit = config
+	}
+}
+
+// WithSubscriberQueue sets size and overflow policy of the subscribers queues.
+func WithSubscriberQueue(config QueueConfig) Option {
+	return func(o *options) {
+		o.queue = config
+	}
+}
+
+// WithPollInterval sets how often parser checks for the new block, default is 5s.
+func WithPollInterval(interval time.Duration) Option {
+	return func(o *options) {
+		o.pollInterval = interval
+	}
+}
+
+// WithConfirmations sets after how many blocks transaction is confirmed, default
+// is 12. Zero disables TransactionConfirmed and Reorg events.
+func WithConfirmations(confirmations int64) Option {
+	return func(o *options) {
+		o.confirmations = confirmations
+	}
+}
+
+// WithStartBlock makes parser start from the given block instead of the
+// current one, the blocks in between are backfilled.
+func WithStartBlock(block int64) Option {
+	return func(o *options) {
+		o.startBlock = block
+	}
+}
+
+// newOptions applies and validates the options, then it checks
+// that every endpoint serves the configured network.
+func newOptions(opts []Option) (options, []ethereum.ApiWrapper, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if err := o.validate(); err != nil {
+		return o, nil, err
+	}
+
+	apiWrappers := o.apiWrappers()
+	for i, apiWrapper := range apiWrappers {
+		if err := ethereum.VerifyChainID(apiWrapper, o.httpClient, o.network); err != nil {
+			return o, nil, fmt.Errorf("verify %s endpoint #%d: %w", o.network.Name, i+1, err)
+		}
+	}
+
+	return o, apiWrappers, nil
+}
+
+// validate returns all the problems with the options at once.
+func (o *options) validate() error {
+	var errs []error
+	invalid := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%w: "+format, append([]any{ErrInvalidOption}, args...)...))
+	}
+
+	if o.network.Name == "" {
+		invalid("network name can't be empty")
+	}
+
+	if o.network.ChainID <= 0 {
+		invalid("network %q chain id must be positive, got %d", o.network.Name, o.network.ChainID)
+	}
+
+	endpoints := o.apiEndpoints()
+	if len(endpoints) == 0 {
+		invalid("no endpoints and network %q has no default endpoint", o.network.Name)
+	}
+
+	for i, endpoint := range endpoints {
+		if _, err := ethereum.ParseHTTPURL(endpoint); err != nil {
+			invalid("endpoint #%d: %s", i+1, err.Error())
+		}
+	}
+
+	if o.httpClient == nil {
+		invalid("http client can't be nil")
+	}
+
+	if o.logger == nil {
+		invalid("logger can't be nil")
+	}
+
+	if o.pollInterval <= 0 {
+		invalid("poll interval must be positive, got %s", o.pollInterval)
+	}
+
+	if o.confirmations < 0 {
+		invalid("confirmations can't be negative, got %d", o.confirmations)
+	}
+
+	if o.startBlock < 0 {
+		invalid("start block can't be negative, got %d", o.startBlock)
+	}
+
+	if o.rateLimit.RequestsPerSecond < 0 {
+		invalid("rate limit requests per second can't be negative, got %v", o.rateLimit.RequestsPerSecond)
+	}
+
+	if o.rateLimit.RequestsPerSecond > 0 && o.rateLimit.Burst < 1 {
+		invalid("rate limit burst must be at least 1, got %d", o.rateLimit.Burst)
+	}
+
+	if o.queue.Size < 0 {
+		invalid("subscriber queue size can't be negative, got %d", o.queue.Size)
+	}
+
+	if o.queue.Policy.String() == "unknown" {
+		invalid("unknown subscriber queue overflow policy %d", o.queue.Policy)
+	}
+
+	return errors.Join(errs...)
+}
+
+// apiEndpoints returns configured endpoints, or the default one of the network.
+func (o *options) apiEndpoints() []string {
+	if len(o.endpoints) > 0 {
+		return o.endpoints
+	}
+
+	if o.network.DefaultEndpoint == "" {
+		return nil
+	}
+
+	return []string{o.network.DefaultEndpoint}
+}
+
+// apiWrappers creates api wrapper for every endpoint, options have to be valid.
+func (o *options) apiWrappers() []ethereum.ApiWrapper {
+	var apiWrappers []ethereum.ApiWrapper
+	for _, endpoint := range o.apiEndpoints() {
+		apiUrl, _ := ethereum.ParseHTTPURL(endpoint)
+
+		if o.authenticator != nil {
+			apiWrappers = append(apiWrappers, ethereum.NewAuthenticatedEthApiWrapper(apiUrl, o.authenticator))
+		} else {
+			apiWrappers = append(apiWrappers, ethereum.NewEthApiWrapper(apiUrl))
+		}
+	}
+
+	return apiWrappers
+}
+
+// apiWrapper combines api wrappers of the endpoints into the one.
+func (o *options) apiWrapper(apiWrappers []ethereum.ApiWrapper) *ratelimit.RateLimitedApiWrapper {
+	var apiWrapper ethereum.ApiWrapper = apiWrappers[0]
+	if len(apiWrappers) > 1 {
+		apiWrapper, _ = ethereum.NewFailoverApiWrapper(o.logger, apiWrappers...)
+	}
+
+	// public endpoints are throttling clients that are doing too many requests,
+	// so we are limiting them on our side before we get blocked
+	return ratelimit.NewRateLimitedApiWrapper(apiWrapper, o.rateLimit)
+}
+
+// scopedStorage returns the storage with the keys scoped to the network.
+func (o *options) scopedStorage() TransactionsStorage {
+	storage := o.storage
+	if storage == nil {
+		storage = memory.NewMemoryTransactionStorage()
+	}
+
+	return ethereum.NewNetworkScopedStorage(storage, o.network)
+}
+
+// build creates the parser, options have to be valid.
+func (o *options) build(apiWrappers []ethereum.ApiWrapper) *JSONRPCParser {
+	rateLimited := o.apiWrapper(apiWrappers)
+
+	observer := ethereum.NewJSONRpcBasedObserver(
+		o.httpClient,
+		o.logger,
+		rateLimited,
+		o.network,
+		ethereum.WithSubscriberQueue(o.queue),
+		ethereum.WithPollInterval(o.pollInterval),
+		ethereum.WithConfirmations(o.confirmations),
+		ethereum.WithStartBlock(o.startBlock),
+	)
+
+	return ethereum.NewJSONRPCParser(
+		observer,
+		o.scopedStorage(),
+		rateLimited,
+		o.httpClient,
+		o.logger,
+	)
+}
@@ -1,34 +1,91 @@
 package pkg
 
 import (
-	"net/http"
-	"net/url"
-
-	"tw/internal/clogger"
 	"tw/internal/ethereum"
 	"tw/internal/memory"
 )
 
-const cloudflareEthApiEndpoint = "https://cloudflare-eth.com"
-
 type Parser = ethereum.Parser
+type JSONRPCParser = ethereum.JSONRPCParser
 type Transaction = ethereum.Transaction
+type TransactionsStorage = ethereum.TransactionsStorage
+type Network = ethereum.Network
+
+var (
+	Mainnet  = ethereum.Mainnet
+	Sepolia  = ethereum.Sepolia
+	Polygon  = ethereum.Polygon
+	Arbitrum = ethereum.Arbitrum
+	Base     = ethereum.Base
+)
+
+var (
+	// ErrChainIDMismatch is returned when api endpoint serves different network than expected.
+	ErrChainIDMismatch = ethereum.ErrChainIDMismatch
+	// ErrInvalidAddress is returned by SubscribeAddress when address is not valid hex address.
+	ErrInvalidAddress = ethereum.ErrInvalidAddress
+	// ErrInvalidAddressChecksum is returned by SubscribeAddress when mixed case address
+	// doesn't match its EIP-55 checksum (most likely there is a typo).
+	ErrInvalidAddressChecksum = ethereum.ErrInvalidAddressChecksum
+	// ErrShutdownTimeout is returned by Shutdown when it didn't finish before the context was done.
+	ErrShutdownTimeout = ethereum.ErrShutdownTimeout
+	// ErrClosed is returned when parser is used after the shutdown.
+	ErrClosed = ethereum.ErrClosed
+	// ErrStorageLocked is returned by NewDeployment, Import and Backfill when
+	// another process (i.e. the running daemon) writes to the storage file.
+	ErrStorageLocked = ethereum.ErrStorageLocked
+)
+
+// NormalizeAddress validates address and returns its EIP-55 checksummed form.
+func NormalizeAddress(address string) (string, error) {
+	return ethereum.NormalizeAddress(address)
+}
+
+// NewDefaultParser creates parser for the Mainnet public endpoint with the
+// default options. Use NewParser to configure it. Like NewParser, it returns
+// ErrChainIDMismatch if the endpoint doesn't serve the Mainnet.
+func NewDefaultParser() (*JSONRPCParser, error) {
+	return NewParser()
+}
+
+// NewParser creates parser configured with the options. All the options are validated
+// up front, the returned error lists every invalid one (errors.Is ErrInvalidOption).
+// Every endpoint is checked to serve the configured network, otherwise it returns
+// ErrChainIDMismatch.
+func NewParser(opts ...Option) (*JSONRPCParser, error) {
+	o, apiWrappers, err := newOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return o.build(apiWrappers), nil
+}
+
+// NewAuthenticatedParser creates parser for the api endpoint which
+// requires credentials, i.e. paid rpc provider or own node.
+func NewAuthenticatedParser(endpoint string, authenticator Authenticator) (*JSONRPCParser, error) {
+	return NewNetworkParser(Mainnet, endpoint, authenticator, nil)
+}
+
+// NewMemoryStorage creates in memory storage, which can be shared between parsers.
+func NewMemoryStorage() TransactionsStorage {
+	return memory.NewMemoryTransactionStorage()
+}
+
+// NewNetworkParser creates parser for the given network. Empty endpoint means the
+// public endpoint of the network, authenticator and storage are optional. Storage
+// keys are scoped to the network, so one storage can be shared by parsers of different
+// networks. It returns ErrChainIDMismatch if the endpoint serves different network.
+func NewNetworkParser(network Network, endpoint string, authenticator Authenticator, storage TransactionsStorage) (*JSONRPCParser, error) {
+	opts := []Option{WithNetwork(network), WithAuthenticator(authenticator)}
+
+	if endpoint != "" {
+		opts = append(opts, WithEndpoints(endpoint))
+	}
+
+	if storage != nil {
+		opts = append(opts, WithStorage(storage))
+	}
 
-func NewDefaultParser() Parser {
-	apiUrl, _ := url.Parse(cloudflareEthApiEndpoint)
-	// Tbh. http client could be passed as parameter here as well, as probably
-	// only one will be used, but this is kind of refactored and I din't have time
-	// to add it here, sr 😅
-	apiWrapper := ethereum.NewEthApiWrapper(apiUrl)
-
-	observer := ethereum.NewJSONRpcBasedObserver(http.DefaultClient, clogger.ConsoleLogger, apiWrapper)
-	storage := memory.NewMemoryTransactionStorage()
-
-	return ethereum.NewJSONRPCParser(
-		observer,
-		storage,
-		apiWrapper,
-		http.DefaultClient,
-		clogger.ConsoleLogger,
-	)
+	return NewParser(opts...)
 }
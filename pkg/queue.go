@@ -0,0 +1,20 @@
+package pkg
+
+import "tw/internal/ethereum"
+
+// OverflowPolicy decides what happens when the queue of the subscribed address is full.
+type OverflowPolicy = ethereum.OverflowPolicy
+
+const (
+	// Block waits until subscriber catches up, it holds processing of the blocks.
+	Block = ethereum.Block
+	// DropOldest removes the oldest transaction from the queue.
+	DropOldest = ethereum.DropOldest
+	// DropNewest drops the transaction that doesn't fit into the queue.
+	DropNewest = ethereum.DropNewest
+	// SpillToDisk writes transactions that don't fit to the file and reads them back later.
+	SpillToDisk = ethereum.SpillToDisk
+)
+
+type QueueConfig = ethereum.QueueConfig
+type QueueStats = ethereum.QueueStats
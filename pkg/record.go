@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"net/http"
+
+	"tw/internal/rpcrecord"
+)
+
+// RecordingTransport writes the JSON-RPC requests and responses to the fixture
+// file, it has to be closed. Use it with WithHTTPClient.
+type RecordingTransport = rpcrecord.Recorder
+
+// ReplayTransport serves the responses recorded by the RecordingTransport.
+type ReplayTransport = rpcrecord.Replayer
+
+// ErrNotRecorded is returned by the ReplayTransport for the request which wasn't recorded.
+var ErrNotRecorded = rpcrecord.ErrNotRecorded
+
+// NewRecordingTransport appends the exchanges sent through the next
+// transport (nil means http.DefaultTransport) to the fixture file.
+func NewRecordingTransport(path string, next http.RoundTripper) (*RecordingTransport, error) {
+	return rpcrecord.NewRecorder(path, next)
+}
+
+// NewReplayTransport loads the fixture file written by the RecordingTransport.
+func NewReplayTransport(path string) (*ReplayTransport, error) {
+	return rpcrecord.NewReplayer(path)
+}
@@ -0,0 +1,23 @@
+package pkg
+
+import (
+	"context"
+	"io"
+
+	"tw/internal/tracing"
+)
+
+// TracingExporter receives the spans of the block processing: poll, fetch
+// block, match, persist and notify. Any OpenTelemetry span exporter can be used.
+type TracingExporter = tracing.Exporter
+
+// SetupTracing sets the global OpenTelemetry tracer provider exporting the spans
+// to the exporter. Returned shutdown exports what is left and stops the exporter.
+func SetupTracing(exporter TracingExporter) (shutdown func(ctx context.Context) error) {
+	return tracing.Setup(exporter)
+}
+
+// NewStdoutTracingExporter writes every span as json to w.
+func NewStdoutTracingExporter(w io.Writer) (TracingExporter, error) {
+	return tracing.NewStdoutExporter(w)
+}